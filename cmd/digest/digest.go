@@ -0,0 +1,27 @@
+package main
+
+import (
+	"time"
+
+	"github.com/bugfixes/celeste/internal/comms"
+	"github.com/bugfixes/celeste/internal/config"
+	bugLog "github.com/bugfixes/go-bugfixes/logs"
+)
+
+func main() {
+	bugLog.Local().Info("Starting Celeste Digest")
+
+	// Config
+	cfg, err := config.BuildConfig()
+	if err != nil {
+		_ = bugLog.Errorf("buildConfig: %v", err)
+		return
+	}
+
+	sent, err := comms.NewComms(cfg).SendDigests(time.Now())
+	if err != nil {
+		_ = bugLog.Errorf("sendDigests: %v", err)
+	}
+
+	bugLog.Local().Infof("sent %d digests", sent)
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"time"
@@ -12,6 +13,9 @@ import (
 	"github.com/bugfixes/celeste/internal/config"
 	"github.com/bugfixes/celeste/internal/frontend"
 	"github.com/bugfixes/celeste/internal/handler"
+	"github.com/bugfixes/celeste/internal/otlp"
+	"github.com/bugfixes/celeste/internal/queue"
+	"github.com/bugfixes/celeste/internal/sentry"
 	"github.com/bugfixes/celeste/internal/ticketing"
 	bugLog "github.com/bugfixes/go-bugfixes/logs"
 	bugfixes "github.com/bugfixes/go-bugfixes/middleware"
@@ -35,17 +39,35 @@ func main() {
 		Config: cfg,
 	}
 
-	if err := route(c); err != nil {
+	// Queue, the local queue has nothing outside the process to work it, so it gets a worker here
+	q, err := queue.NewQueue(cfg)
+	if err != nil {
+		_ = bugLog.Errorf("newQueue: %v", err)
+		return
+	}
+	if cfg.QueueBackend == config.QueueLocal {
+		go queue.NewWorker(cfg, q, bug.NewBug(cfg).ProcessMessage).Run(context.Background())
+	}
+
+	if err := route(c, q); err != nil {
 		_ = bugLog.Errorf("route: %v", err)
 		return
 	}
 }
 
-func route(c handler.Celeste) error {
-	r := mux.NewRouter()
+func route(c handler.Celeste, q queue.Queue) error {
+	logs := bug.NewLog(c.Config)
+	logs.Queue = q
+
+	root := mux.NewRouter()
+	root.Use(middleware.RequestID)
+	root.Use(bugfixes.BugFixes)
+
+	// a tail is held open for as long as the client wants it, so it is the one route without the timeout
+	root.HandleFunc("/log", logs.QueryHandler).Methods(http.MethodGet).MatcherFunc(bug.WantsTail)
+
+	r := root.NewRoute().Subrouter()
 	r.Use(middleware.Timeout(60 * time.Second))
-	r.Use(middleware.RequestID)
-	r.Use(bugfixes.BugFixes)
 
 	// Auth
 	s := r.PathPrefix("/auth").Subrouter()
@@ -54,6 +76,8 @@ func route(c handler.Celeste) error {
 	s.HandleFunc("/logout/{provider}", auth.NewAuth(c.Config).LogoutHandler)
 
 	// Account
+	r.HandleFunc("/account/retention", account.NewHTTPRequest(c.Config).RetentionHandler).Methods(http.MethodGet, http.MethodPut)
+	r.HandleFunc("/account/rules", account.NewHTTPRequest(c.Config).RulesHandler).Methods(http.MethodGet, http.MethodPut)
 	r.PathPrefix("/account").HandlerFunc(account.NewHTTPRequest(c.Config).CreateHandler).Methods(http.MethodPost)
 	r.PathPrefix("/account").HandlerFunc(account.NewHTTPRequest(c.Config).DeleteHandler).Methods(http.MethodDelete)
 	r.PathPrefix("/account/login").HandlerFunc(account.NewHTTPRequest(c.Config).LoginHandler).Methods(http.MethodPost)
@@ -63,12 +87,39 @@ func route(c handler.Celeste) error {
 	// s = r.PathPrefix("/agent").Subrouter()
 
 	// Logs
-	r.PathPrefix("/log").HandlerFunc(bug.NewLog(c.Config).LogHandler).Methods(http.MethodPost)
+	r.HandleFunc("/log/batch", logs.BatchHandler).Methods(http.MethodPost)
+	r.PathPrefix("/log").HandlerFunc(logs.LogHandler).Methods(http.MethodPost)
+	r.HandleFunc("/log", logs.QueryHandler).Methods(http.MethodGet)
 
 	// Bug
-	r.PathPrefix("/bug").HandlerFunc(bug.NewBug(c.Config).BugHandler).Methods(http.MethodPost)
+	bugs := bug.NewBug(c.Config)
+	bugs.Queue = q
+	r.HandleFunc("/bug/batch", bugs.BatchHandler).Methods(http.MethodPost)
+	r.PathPrefix("/bug").HandlerFunc(bugs.BugHandler).Methods(http.MethodPost)
+	r.HandleFunc("/bug", bug.NewBug(c.Config).ListHandler).Methods(http.MethodGet)
+	r.HandleFunc("/bug/{bugId}/rules", bug.NewBug(c.Config).RulesHandler).Methods(http.MethodGet)
+	r.HandleFunc("/bug/{bugId}/notifications", bug.NewBug(c.Config).NotificationsHandler).Methods(http.MethodGet)
+	r.HandleFunc("/bug/{bugId}", bug.NewBug(c.Config).FetchHandler).Methods(http.MethodGet)
+	r.HandleFunc("/bug/{bugId}", bug.NewBug(c.Config).StatusHandler).Methods(http.MethodPatch)
+
+	// Sentry SDKs
+	sentryIngest := sentry.NewSentry(c.Config)
+	sentryIngest.Bugs = bugs
+	sentryIngest.Logs = logs
+	r.HandleFunc("/api/{projectId}/store/", sentryIngest.StoreHandler).Methods(http.MethodPost)
+	r.HandleFunc("/api/{projectId}/envelope/", sentryIngest.EnvelopeHandler).Methods(http.MethodPost)
+
+	// OpenTelemetry
+	otlpReceiver := otlp.NewReceiver(c.Config)
+	otlpReceiver.Bugs = bugs
+	otlpReceiver.Logs = logs
+	r.HandleFunc("/v1/logs", otlpReceiver.LogsHandler).Methods(http.MethodPost)
+	r.HandleFunc("/v1/traces", otlpReceiver.TracesHandler).Methods(http.MethodPost)
 
 	// Comms
+	slackActions := comms.NewSlackActions(c.Config)
+	slackActions.Bugs = bugs
+	r.HandleFunc("/comms/slack/actions", slackActions.ActionHandler).Methods(http.MethodPost)
 	r.PathPrefix("/comms").HandlerFunc(comms.NewCommunication(c.Config).CreateCommsHandler).Methods(http.MethodPost)
 	r.PathPrefix("/comms").HandlerFunc(comms.NewCommunication(c.Config).AttachCommsHandler).Methods(http.MethodPut)
 	r.PathPrefix("/comms").HandlerFunc(comms.NewCommunication(c.Config).DetachCommsHandler).Methods(http.MethodPatch)
@@ -87,7 +138,7 @@ func route(c handler.Celeste) error {
 	r.PathPrefix("/probe").HandlerFunc(probe.HTTP).Methods(http.MethodGet)
 
 	bugLog.Local().Infof("listening on port: %d\n", c.Config.Local.Port)
-	err := http.ListenAndServe(fmt.Sprintf(":%d", c.Config.Local.Port), r)
+	err := http.ListenAndServe(fmt.Sprintf(":%d", c.Config.Local.Port), root)
 	if err != nil {
 		return bugLog.Errorf("port: %v", err)
 	}
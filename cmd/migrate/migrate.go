@@ -0,0 +1,26 @@
+package main
+
+import (
+	"github.com/bugfixes/celeste/internal/bug"
+	"github.com/bugfixes/celeste/internal/config"
+	bugLog "github.com/bugfixes/go-bugfixes/logs"
+)
+
+func main() {
+	bugLog.Local().Info("Starting Celeste Migration")
+
+	// Config
+	cfg, err := config.BuildConfig()
+	if err != nil {
+		_ = bugLog.Errorf("buildConfig: %v", err)
+		return
+	}
+
+	migrated, err := bug.NewBugStorage(cfg).Migrate()
+	if err != nil {
+		_ = bugLog.Errorf("migrate: %v", err)
+		return
+	}
+
+	bugLog.Local().Infof("migrated %d bugs", migrated)
+}
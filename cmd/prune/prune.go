@@ -0,0 +1,28 @@
+package main
+
+import (
+	"time"
+
+	"github.com/bugfixes/celeste/internal/config"
+	"github.com/bugfixes/celeste/internal/database"
+	bugLog "github.com/bugfixes/go-bugfixes/logs"
+)
+
+func main() {
+	bugLog.Local().Info("Starting Celeste Log Prune")
+
+	// Config
+	cfg, err := config.BuildConfig()
+	if err != nil {
+		_ = bugLog.Errorf("buildConfig: %v", err)
+		return
+	}
+
+	pruned, err := database.NewLogStorage(*database.New(cfg)).Prune(time.Now())
+	if err != nil {
+		_ = bugLog.Errorf("prune: %v", err)
+		return
+	}
+
+	bugLog.Local().Infof("pruned %d logs", pruned)
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/bugfixes/celeste/internal/bug"
+	"github.com/bugfixes/celeste/internal/config"
+	"github.com/bugfixes/celeste/internal/queue"
+	bugLog "github.com/bugfixes/go-bugfixes/logs"
+)
+
+func main() {
+	bugLog.Local().Info("Starting Celeste Worker")
+
+	// Config
+	cfg, err := config.BuildConfig()
+	if err != nil {
+		_ = bugLog.Errorf("buildConfig: %v", err)
+		return
+	}
+
+	q, err := queue.NewQueue(cfg)
+	if err != nil {
+		_ = bugLog.Errorf("newQueue: %v", err)
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	queue.NewWorker(cfg, q, bug.NewBug(cfg).ProcessMessage).Run(ctx)
+	bugLog.Local().Info("Stopped Celeste Worker")
+}
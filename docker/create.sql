@@ -26,9 +26,15 @@ INSERT INTO permission (
 CREATE TABLE IF NOT EXISTS account (
     id SERIAL,
     identifier VARCHAR(100),
+    name VARCHAR(100),
+    email VARCHAR(255),
     account_key VARCHAR(100) NOT NULL,
+    account_secret VARCHAR(100),
     parent_id INT NULL,
     account_group INT NULL,
+    date_created VARCHAR(100),
+    retention TEXT NULL,
+    rules TEXT NULL,
     PRIMARY KEY(id)
 );
 
@@ -49,6 +55,7 @@ CREATE TABLE IF NOT EXISTS frontend_versions (
 
 CREATE TABLE IF NOT EXISTS agent (
     id SERIAL,
+    identifier VARCHAR(100),
     account_id INT NOT NULL,
     name VARCHAR(100),
     key UUID,
@@ -82,8 +89,116 @@ CREATE TABLE IF NOT EXISTS comms_details (
     agent_id INT NOT NULL,
     system VARCHAR(100),
     details JSON,
+    last_digest TIMESTAMP,
     PRIMARY KEY (id),
     CONSTRAINT fk_agent_id FOREIGN KEY (agent_id) REFERENCES agent(id)
 );
 
+CREATE TABLE IF NOT EXISTS comms_notification (
+    id SERIAL,
+    ref VARCHAR(100),
+    agent_id INT,
+    bug_id VARCHAR(100) NOT NULL,
+    channel VARCHAR(200),
+    kind VARCHAR(20),
+    rule VARCHAR(200),
+    state VARCHAR(200),
+    sent TIMESTAMP NOT NULL,
+    follows INT,
+    PRIMARY KEY (id)
+);
+CREATE INDEX idx_comms_notification_bug ON comms_notification(bug_id, sent);
+CREATE UNIQUE INDEX idx_comms_notification_ref ON comms_notification(ref);
+CREATE UNIQUE INDEX idx_comms_notification_follows ON comms_notification(bug_id, channel, kind, follows);
+
+CREATE TABLE IF NOT EXISTS comms_digest (
+    id SERIAL,
+    agent_id INT NOT NULL,
+    bug_id VARCHAR(100),
+    level VARCHAR(100),
+    file TEXT,
+    line VARCHAR(100),
+    link TEXT,
+    times_reported INT NOT NULL DEFAULT 0,
+    regressed BOOLEAN NOT NULL DEFAULT FALSE,
+    reported TIMESTAMP NOT NULL,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_comms_digest_agent_id FOREIGN KEY (agent_id) REFERENCES agent(id)
+);
+
+CREATE TABLE IF NOT EXISTS bug (
+    id VARCHAR(100),
+    agent_id VARCHAR(100) NOT NULL,
+    environment VARCHAR(100),
+    level VARCHAR(100),
+    file TEXT,
+    line VARCHAR(100),
+    file_line_hash TEXT,
+    hash TEXT,
+    fingerprint TEXT,
+    full_details JSON,
+    times_reported INT NOT NULL DEFAULT 1,
+    first_reported TIMESTAMP,
+    last_reported TIMESTAMP,
+    remote_link TEXT,
+    ticket_system VARCHAR(100),
+    status VARCHAR(20) NOT NULL DEFAULT 'open',
+    status_changed TIMESTAMP,
+    snooze_until TIMESTAMP,
+    snooze_until_times INT NOT NULL DEFAULT 0,
+    first_release VARCHAR(100),
+    last_release VARCHAR(100),
+    last_commit VARCHAR(100),
+    last_spike TIMESTAMP,
+    assignee VARCHAR(200),
+    PRIMARY KEY (id)
+);
+CREATE INDEX idx_bug_fingerprint ON bug(agent_id, fingerprint);
+CREATE UNIQUE INDEX idx_bug_agent_environment_fingerprint ON bug(agent_id, environment, fingerprint);
 
+CREATE TABLE IF NOT EXISTS bug_occurrence (
+    bug_id VARCHAR(100) NOT NULL,
+    day VARCHAR(10) NOT NULL,
+    occurrences INT NOT NULL DEFAULT 0,
+    PRIMARY KEY (bug_id, day),
+    CONSTRAINT fk_bug_id FOREIGN KEY (bug_id) REFERENCES bug(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS bug_rate (
+    bug_id VARCHAR(100) NOT NULL,
+    span VARCHAR(10) NOT NULL,
+    bucket VARCHAR(20) NOT NULL,
+    occurrences INT NOT NULL DEFAULT 0,
+    PRIMARY KEY (bug_id, span, bucket),
+    CONSTRAINT fk_bug_rate_bug_id FOREIGN KEY (bug_id) REFERENCES bug(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS bug_release (
+    bug_id VARCHAR(100) NOT NULL,
+    release VARCHAR(100) NOT NULL,
+    first_seen TIMESTAMP NOT NULL,
+    PRIMARY KEY (bug_id, release),
+    CONSTRAINT fk_bug_release_bug_id FOREIGN KEY (bug_id) REFERENCES bug(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS log (
+    id VARCHAR(100),
+    agent_id VARCHAR(100) NOT NULL,
+    environment VARCHAR(100),
+    level VARCHAR(100),
+    line VARCHAR(100),
+    file TEXT,
+    stack TEXT,
+    frames TEXT NULL,
+    log_fmt TEXT,
+    fields JSONB NULL,
+    entry TEXT,
+    logged TIMESTAMP,
+    expires TIMESTAMP NULL,
+    received TIMESTAMP,
+    PRIMARY KEY (id)
+);
+CREATE INDEX idx_log_agent ON log(agent_id, logged);
+CREATE INDEX idx_log_received ON log(agent_id, received);
+CREATE INDEX idx_log_expires ON log(expires);
+CREATE INDEX idx_log_fields ON log USING GIN (fields);
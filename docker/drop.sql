@@ -4,6 +4,14 @@ DROP TABLE account_permission;
 
 DROP TABLE frontend_versions;
 
+DROP TABLE bug_occurrence;
+DROP TABLE bug_release;
+DROP TABLE bug_rate;
+DROP TABLE bug;
+DROP TABLE log;
+
+DROP TABLE comms_notification;
+DROP TABLE comms_digest;
 DROP TABLE comms_details;
 DROP TABLE ticketing_details;
 DROP TABLE ticket;
@@ -75,6 +75,7 @@ func (r Request) Create() (Response, error) {
 	}
 
 	type Data struct {
+		ID     string
 		Key    string
 		Secret string
 	}
@@ -88,6 +89,7 @@ func (r Request) Create() (Response, error) {
 		Body: &Body{
 			Operation: "celeste_account_create",
 			Data: Data{
+				ID:     id.String(),
 				Key:    key.String(),
 				Secret: secret.String(),
 			},
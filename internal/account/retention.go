@@ -0,0 +1,79 @@
+package account
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/bugfixes/celeste/internal/database"
+	bugLog "github.com/bugfixes/go-bugfixes/logs"
+)
+
+// requestAccount checks the account id and secret sent in the headers
+func (r Request) requestAccount(hr *http.Request) (database.AccountRecord, error) {
+	id := hr.Header.Get("x-account-id")
+	secret := hr.Header.Get("x-account-auth")
+	if id == "" || secret == "" {
+		return database.AccountRecord{}, bugLog.Errorf("requestAccount: missing credentials")
+	}
+
+	ar, err := database.NewAccountStorage(*database.New(r.Config)).Fetch(id)
+	if err != nil {
+		return ar, bugLog.Errorf("requestAccount fetch: %+v", err)
+	}
+	if ar.AccountCredentials.Secret != secret {
+		return ar, bugLog.Errorf("requestAccount: invalid secret")
+	}
+
+	return ar, nil
+}
+
+func retentionResponse(w http.ResponseWriter, retention database.Retention) {
+	if retention == nil {
+		retention = database.Retention{}
+	}
+
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(struct {
+		Operation string             `json:"operation"`
+		Data      database.Retention `json:"data"`
+	}{
+		Operation: "celeste_account_retention",
+		Data:      retention,
+	}); err != nil {
+		bugLog.Debugf("retentionResponse encode: %+v", err)
+	}
+}
+
+// RetentionHandler shows the account's log retention on a GET, and replaces it on a PUT,
+// the body is the days to keep each level for, e.g. {"debug": 3, "error": 90, "default": 30}
+func (r Request) RetentionHandler(w http.ResponseWriter, hr *http.Request) {
+	ar, err := r.requestAccount(hr)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid account: %v", err), http.StatusForbidden)
+		return
+	}
+
+	if hr.Method != http.MethodPut {
+		retentionResponse(w, ar.Retention)
+		return
+	}
+
+	retention := database.Retention{}
+	if err := json.NewDecoder(hr.Body).Decode(&retention); err != nil {
+		http.Error(w, fmt.Sprintf("failed to decode retention: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := retention.Validate(); err != nil {
+		http.Error(w, fmt.Sprintf("invalid retention: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := database.NewAccountStorage(*database.New(r.Config)).UpdateRetention(ar.ID, retention); err != nil {
+		http.Error(w, fmt.Sprintf("failed to update retention: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	retentionResponse(w, retention)
+}
@@ -0,0 +1,66 @@
+package account
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/bugfixes/celeste/internal/database"
+	bugLog "github.com/bugfixes/go-bugfixes/logs"
+	"github.com/google/uuid"
+)
+
+func rulesResponse(w http.ResponseWriter, rules database.Rules) {
+	if rules == nil {
+		rules = database.Rules{}
+	}
+
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(struct {
+		Operation string         `json:"operation"`
+		Data      database.Rules `json:"data"`
+	}{
+		Operation: "celeste_account_rules",
+		Data:      rules,
+	}); err != nil {
+		bugLog.Debugf("rulesResponse encode: %+v", err)
+	}
+}
+
+// RulesHandler shows the account's alert rules on a GET, and replaces them on a PUT,
+// the body is the rules in the order they're checked, rules without an id are given one
+func (r Request) RulesHandler(w http.ResponseWriter, hr *http.Request) {
+	ar, err := r.requestAccount(hr)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid account: %v", err), http.StatusForbidden)
+		return
+	}
+
+	if hr.Method != http.MethodPut {
+		rulesResponse(w, ar.Rules)
+		return
+	}
+
+	rules := database.Rules{}
+	if err := json.NewDecoder(hr.Body).Decode(&rules); err != nil {
+		http.Error(w, fmt.Sprintf("failed to decode rules: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := rules.Validate(); err != nil {
+		http.Error(w, fmt.Sprintf("invalid rules: %v", err), http.StatusBadRequest)
+		return
+	}
+	for i := range rules {
+		if rules[i].ID == "" {
+			rules[i].ID = uuid.New().String()
+		}
+	}
+
+	if err := database.NewAccountStorage(*database.New(r.Config)).UpdateRules(ar.ID, rules); err != nil {
+		http.Error(w, fmt.Sprintf("failed to update rules: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	rulesResponse(w, rules)
+}
@@ -2,13 +2,11 @@ package agent
 
 import (
 	"context"
-	"fmt"
 
 	"github.com/bugfixes/celeste/internal/account"
 	"github.com/bugfixes/celeste/internal/config"
 	bugLog "github.com/bugfixes/go-bugfixes/logs"
 	"github.com/google/uuid"
-	"github.com/jackc/pgx/v4"
 )
 
 type Credentials struct {
@@ -20,6 +18,8 @@ type Agent struct {
 	ID   int
 	UUID string `json:"id"`
 	Name string `json:"name"`
+	// Identifier is the agent's own id, the one rules name it by, UUID is taken over by the key for what it reports
+	Identifier string `json:"-"`
 
 	Credentials
 	account.Account
@@ -43,34 +43,25 @@ func NewAgent(c config.Config) *AgentClient {
 	}
 }
 
-func (ac AgentClient) getConnection() (*pgx.Conn, error) {
-	conn, err := pgx.Connect(
-		ac.Context,
-		fmt.Sprintf(
-			"postgres://%s:%s@%s:%s/%s",
-			ac.Config.RDS.Username,
-			ac.Config.RDS.Password,
-			ac.Config.RDS.Hostname,
-			ac.Config.RDS.Port,
-			ac.Config.RDS.Database))
-	if err != nil {
-		return nil, bugLog.Errorf("getConnnection: %+v", err)
+func (ac AgentClient) Find(a *Agent) error {
+	if err := NewAgentStorage(ac.Config).Find(a); err != nil {
+		return bugLog.Errorf("find: %+v", err)
 	}
 
-	return conn, nil
+	return nil
 }
 
-func (ac AgentClient) Find(a *Agent) error {
-	conn, err := ac.getConnection()
-	if err != nil {
-		return bugLog.Errorf("find: %+v", err)
+func (ac AgentClient) FindByKey(a *Agent) error {
+	if err := NewAgentStorage(ac.Config).FindByKey(a); err != nil {
+		return bugLog.Errorf("findByKey: %+v", err)
 	}
 
-	if err := conn.QueryRow(ac.Context,
-		"SELECT id FROM agent WHERE key = $1 AND secret = $2 LIMIT 1",
-		a.Key,
-		a.Secret).Scan(&a.ID); err != nil {
-		return bugLog.Errorf("find: %+v", err)
+	return nil
+}
+
+func (ac AgentClient) Store(a Agent) error {
+	if err := NewAgentStorage(ac.Config).Store(a); err != nil {
+		return bugLog.Errorf("store: %+v", err)
 	}
 
 	return nil
@@ -0,0 +1,120 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bugfixes/celeste/internal/config"
+	bugLog "github.com/bugfixes/go-bugfixes/logs"
+	"github.com/jackc/pgx/v4"
+)
+
+//go:generate mockery --name=AgentStorage
+type AgentStorage interface {
+	Store(a Agent) error
+	Find(a *Agent) error
+	FindByKey(a *Agent) error
+}
+
+type PostgresAgentStorage struct {
+	Config  config.Config
+	Context context.Context
+}
+
+func NewAgentStorage(c config.Config) AgentStorage {
+	if c.StorageBackend == config.StorageMemory {
+		return NewMemoryAgentStorage(c)
+	}
+
+	return NewPostgresAgentStorage(c)
+}
+
+func NewPostgresAgentStorage(c config.Config) *PostgresAgentStorage {
+	return &PostgresAgentStorage{
+		Config:  c,
+		Context: context.Background(),
+	}
+}
+
+func (s PostgresAgentStorage) getConnection() (*pgx.Conn, error) {
+	conn, err := pgx.Connect(
+		s.Context,
+		fmt.Sprintf(
+			"postgres://%s:%s@%s:%s/%s",
+			s.Config.RDS.Username,
+			s.Config.RDS.Password,
+			s.Config.RDS.Hostname,
+			s.Config.RDS.Port,
+			s.Config.RDS.Database))
+	if err != nil {
+		return nil, bugLog.Errorf("getConnnection: %+v", err)
+	}
+
+	return conn, nil
+}
+
+func (s PostgresAgentStorage) Store(a Agent) error {
+	conn, err := s.getConnection()
+	if err != nil {
+		return bugLog.Errorf("store: %+v", err)
+	}
+	defer func() {
+		if err := conn.Close(s.Context); err != nil {
+			bugLog.Debugf("close: %+v", err)
+		}
+	}()
+
+	if _, err := conn.Exec(s.Context,
+		"INSERT INTO agent (identifier, account_id, name, key, secret) VALUES ($1, (SELECT id FROM account WHERE identifier = $2 LIMIT 1), $3, $4, $5)",
+		a.UUID,
+		a.Account.ID,
+		a.Name,
+		a.Credentials.Key,
+		a.Credentials.Secret); err != nil {
+		return bugLog.Errorf("store: %+v", err)
+	}
+
+	return nil
+}
+
+func (s PostgresAgentStorage) Find(a *Agent) error {
+	conn, err := s.getConnection()
+	if err != nil {
+		return bugLog.Errorf("find: %+v", err)
+	}
+	defer func() {
+		if err := conn.Close(s.Context); err != nil {
+			bugLog.Debugf("close: %+v", err)
+		}
+	}()
+
+	if err := conn.QueryRow(s.Context,
+		"SELECT agent.id, COALESCE(agent.identifier, ''), COALESCE(account.identifier, '') FROM agent LEFT JOIN account ON account.id = agent.account_id WHERE agent.key = $1 AND agent.secret = $2 LIMIT 1",
+		a.Key,
+		a.Secret).Scan(&a.ID, &a.Identifier, &a.Account.ID); err != nil {
+		return bugLog.Errorf("find: %+v", err)
+	}
+
+	return nil
+}
+
+// FindByKey finds the agent from its key alone, for the Sentry DSN, whose public key is all most SDKs send
+func (s PostgresAgentStorage) FindByKey(a *Agent) error {
+	conn, err := s.getConnection()
+	if err != nil {
+		return bugLog.Errorf("findByKey: %+v", err)
+	}
+	defer func() {
+		if err := conn.Close(s.Context); err != nil {
+			bugLog.Debugf("close: %+v", err)
+		}
+	}()
+
+	if err := conn.QueryRow(s.Context,
+		"SELECT agent.id, agent.secret, COALESCE(agent.identifier, ''), COALESCE(account.identifier, '') FROM agent LEFT JOIN account ON account.id = agent.account_id WHERE agent.key = $1 LIMIT 1",
+		a.Key).Scan(&a.ID, &a.Secret, &a.Identifier, &a.Account.ID); err != nil {
+		return bugLog.Errorf("findByKey: %+v", err)
+	}
+
+	return nil
+}
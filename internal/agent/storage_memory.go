@@ -0,0 +1,83 @@
+package agent
+
+import (
+	"sync"
+
+	"github.com/bugfixes/celeste/internal/config"
+	bugLog "github.com/bugfixes/go-bugfixes/logs"
+)
+
+type agentMemory struct {
+	sync.RWMutex
+
+	agents []Agent
+}
+
+// sharedAgentMemory is kept for the life of the process so every MemoryAgentStorage sees the same agents
+var sharedAgentMemory = &agentMemory{}
+
+// ResetMemory empties the agents every MemoryAgentStorage shares
+func ResetMemory() {
+	sharedAgentMemory.Lock()
+	defer sharedAgentMemory.Unlock()
+
+	sharedAgentMemory.agents = nil
+}
+
+type MemoryAgentStorage struct {
+	Config config.Config
+
+	memory *agentMemory
+}
+
+func NewMemoryAgentStorage(c config.Config) *MemoryAgentStorage {
+	return &MemoryAgentStorage{
+		Config: c,
+		memory: sharedAgentMemory,
+	}
+}
+
+func (s MemoryAgentStorage) Store(a Agent) error {
+	s.memory.Lock()
+	defer s.memory.Unlock()
+
+	if a.ID == 0 {
+		a.ID = len(s.memory.agents) + 1
+	}
+	s.memory.agents = append(s.memory.agents, a)
+
+	return nil
+}
+
+func (s MemoryAgentStorage) Find(a *Agent) error {
+	s.memory.RLock()
+	defer s.memory.RUnlock()
+
+	for _, stored := range s.memory.agents {
+		if stored.Key == a.Key && stored.Secret == a.Secret {
+			a.ID = stored.ID
+			a.Identifier = stored.UUID
+			a.Account.ID = stored.Account.ID
+			return nil
+		}
+	}
+
+	return bugLog.Errorf("find: no rows in result set")
+}
+
+func (s MemoryAgentStorage) FindByKey(a *Agent) error {
+	s.memory.RLock()
+	defer s.memory.RUnlock()
+
+	for _, stored := range s.memory.agents {
+		if stored.Key == a.Key {
+			a.ID = stored.ID
+			a.Secret = stored.Secret
+			a.Identifier = stored.UUID
+			a.Account.ID = stored.Account.ID
+			return nil
+		}
+	}
+
+	return bugLog.Errorf("findByKey: no rows in result set")
+}
@@ -0,0 +1,70 @@
+package bug
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bugfixes/celeste/internal/comms"
+	"github.com/bugfixes/celeste/internal/ticketing"
+	bugLog "github.com/bugfixes/go-bugfixes/logs"
+)
+
+// snoozeFor is how long the snooze button snoozes a bug for
+const snoozeFor = 24 * time.Hour
+
+// BugAction does what a button on the bug's message asks, the bug is changed first, then its remote ticket,
+// resolving or ignoring closes the ticket, assigning gives it to whoever pressed the button,
+// a ticket that can't be changed is only said so, the bug has already changed
+func (p ProcessBug) BugAction(action comms.BugAction) (string, error) {
+	s := NewBugStorage(p.Config)
+	bri, err := s.Get(action.Agent.Key, action.BugID)
+	if err != nil {
+		return "", bugLog.Errorf("bugAction get: %+v", err)
+	}
+
+	now := time.Now()
+	ticket := ticketing.Ticket{
+		Agent:        action.Agent,
+		RemoteLink:   bri.RemoteLink,
+		RemoteSystem: bri.TicketSystem,
+	}
+	done := ""
+	switch action.Action {
+	case comms.ActionResolve:
+		err = bri.ChangeStatus(StatusChange{Status: StatusResolved}, now)
+		ticket.State = ticketing.TicketClosed
+		done = "resolved the bug"
+	case comms.ActionIgnore:
+		err = bri.ChangeStatus(StatusChange{Status: StatusIgnored}, now)
+		ticket.State = ticketing.TicketClosed
+		done = "ignored the bug"
+	case comms.ActionSnooze:
+		err = bri.ChangeStatus(StatusChange{Status: StatusSnoozed, SnoozeUntil: now.Add(snoozeFor)}, now)
+		done = fmt.Sprintf("snoozed the bug for %.0f hours", snoozeFor.Hours())
+	case comms.ActionAssign:
+		if action.Email == "" {
+			return "", bugLog.Errorf("bugAction assign: %s has no email", action.User)
+		}
+		bri.Assignee = action.Email
+		ticket.Assignee = action.Email
+		done = "took the bug on"
+	default:
+		return "", bugLog.Errorf("bugAction: unknown action %s", action.Action)
+	}
+	if err != nil {
+		return "", bugLog.Errorf("bugAction changeStatus: %+v", err)
+	}
+	if err := s.UpdateStatus(bri); err != nil {
+		return "", bugLog.Errorf("bugAction updateStatus: %+v", err)
+	}
+
+	if bri.RemoteLink == "" || (ticket.State == "" && ticket.Assignee == "") {
+		return done, nil
+	}
+	if err := ticketing.NewTicketing(p.Config).TransitionTicket(&ticket); err != nil {
+		bugLog.Debugf("bugAction transitionTicket: %+v", err)
+		return fmt.Sprintf("%s, but its %s ticket couldn't be updated", done, bri.TicketSystem), nil
+	}
+
+	return fmt.Sprintf("%s and updated its %s ticket", done, bri.TicketSystem), nil
+}
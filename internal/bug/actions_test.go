@@ -0,0 +1,115 @@
+package bug_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bugfixes/celeste/internal/agent"
+	bug "github.com/bugfixes/celeste/internal/bug"
+	"github.com/bugfixes/celeste/internal/comms"
+	"github.com/bugfixes/celeste/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcessBug_BugAction(t *testing.T) {
+	t.Cleanup(resetMemory)
+
+	c := config.Config{
+		Storage: config.Storage{
+			StorageBackend: config.StorageMemory,
+		},
+	}
+	a := agent.Agent{
+		Credentials: agent.Credentials{
+			Key:    "actions",
+			Secret: "actions",
+		},
+	}
+
+	tests := []struct {
+		name     string
+		action   comms.BugAction
+		done     string
+		status   string
+		assignee string
+		snoozed  bool
+		err      bool
+	}{
+		{
+			name:   "resolve",
+			action: comms.BugAction{Action: comms.ActionResolve},
+			done:   "resolved the bug",
+			status: bug.StatusResolved,
+		},
+		{
+			name:   "ignore",
+			action: comms.BugAction{Action: comms.ActionIgnore},
+			done:   "ignored the bug",
+			status: bug.StatusIgnored,
+		},
+		{
+			name:    "snooze",
+			action:  comms.BugAction{Action: comms.ActionSnooze},
+			done:    "snoozed the bug for 24 hours",
+			status:  bug.StatusSnoozed,
+			snoozed: true,
+		},
+		{
+			name:     "assign",
+			action:   comms.BugAction{Action: comms.ActionAssign, User: "bob", Email: "bob@bugfix.es"},
+			done:     "took the bug on",
+			status:   bug.StatusOpen,
+			assignee: "bob@bugfix.es",
+		},
+		{
+			name:   "assign without an email",
+			action: comms.BugAction{Action: comms.ActionAssign, User: "bob"},
+			status: bug.StatusOpen,
+			err:    true,
+		},
+		{
+			name:   "unknown action",
+			action: comms.BugAction{Action: "delete"},
+			status: bug.StatusOpen,
+			err:    true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := bug.NewBugStorage(c)
+			id := "action-" + test.name
+			if err := s.Insert(bug.BugRecord{ID: id, AgentID: a.Key}); err != nil {
+				t.Fatalf("insert err: %+v", err)
+			}
+
+			test.action.Agent = a
+			test.action.BugID = id
+			done, err := bug.NewBug(c).BugAction(test.action)
+			if passed := assert.Equal(t, test.err, err != nil); !passed {
+				t.Errorf("bugAction err: %+v", err)
+			}
+			if passed := assert.Equal(t, test.done, done); !passed {
+				t.Errorf("bugAction expect: %v, got: %v", test.done, done)
+			}
+
+			bri, err := s.Get(a.Key, id)
+			if passed := assert.Nil(t, err); !passed {
+				t.Fatalf("get err: %+v", err)
+			}
+			if passed := assert.Equal(t, test.status, bri.CurrentStatus()); !passed {
+				t.Errorf("bugAction status expect: %v, got: %v", test.status, bri.CurrentStatus())
+			}
+			if passed := assert.Equal(t, test.assignee, bri.Assignee); !passed {
+				t.Errorf("bugAction assignee expect: %v, got: %v", test.assignee, bri.Assignee)
+			}
+			if passed := assert.Equal(t, test.snoozed, bri.SnoozeUntil.After(time.Now())); !passed {
+				t.Errorf("bugAction snoozeUntil got: %v", bri.SnoozeUntil)
+			}
+		})
+	}
+
+	if _, err := bug.NewBug(c).BugAction(comms.BugAction{Agent: a, BugID: "missing", Action: comms.ActionResolve}); err == nil {
+		t.Errorf("bugAction unknown bug expected an err")
+	}
+}
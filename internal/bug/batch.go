@@ -0,0 +1,166 @@
+package bug
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	bugLog "github.com/bugfixes/go-bugfixes/logs"
+)
+
+const (
+	// MaxBatchItems is the most bugs or logs a single batch can carry
+	MaxBatchItems = 1000
+	// MaxBatchBytes is the largest batch body that will be read
+	MaxBatchBytes = 5 << 20
+)
+
+type BatchResult struct {
+	Index  int    `json:"index"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type BatchResponse struct {
+	Accepted int           `json:"accepted"`
+	Failed   int           `json:"failed"`
+	Results  []BatchResult `json:"results"`
+}
+
+func (br *BatchResponse) add(index int, status string, err error) {
+	result := BatchResult{
+		Index:  index,
+		Status: status,
+	}
+	if err != nil {
+		result.Status = "failed"
+		result.Error = err.Error()
+		br.Failed++
+	} else {
+		br.Accepted++
+	}
+
+	br.Results = append(br.Results, result)
+}
+
+// decodeBatch splits a body into its items, either a JSON array or one JSON document per line,
+// the items are not decoded so one bad item can fail on its own
+func decodeBatch(w http.ResponseWriter, r *http.Request) ([]json.RawMessage, error) {
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, MaxBatchBytes))
+	if err != nil {
+		return nil, bugLog.Errorf("decodeBatch read: %+v", err)
+	}
+
+	items := []json.RawMessage{}
+	body = bytes.TrimSpace(body)
+	if bytes.HasPrefix(body, []byte("[")) {
+		if err := json.Unmarshal(body, &items); err != nil {
+			return nil, bugLog.Errorf("decodeBatch array: %+v", err)
+		}
+	} else {
+		for _, line := range bytes.Split(body, []byte("\n")) {
+			line = bytes.TrimSpace(line)
+			if len(line) == 0 {
+				continue
+			}
+			items = append(items, json.RawMessage(line))
+		}
+	}
+
+	if len(items) == 0 {
+		return nil, bugLog.Errorf("decodeBatch: batch is empty")
+	}
+	if len(items) > MaxBatchItems {
+		return nil, bugLog.Errorf("decodeBatch: %d items is more than %d", len(items), MaxBatchItems)
+	}
+
+	return items, nil
+}
+
+// BatchHandler queues every bug of the batch on its own, the results say which ones made it
+func (p ProcessBug) BatchHandler(w http.ResponseWriter, r *http.Request) {
+	defer func() {
+		if err := r.Body.Close(); err != nil {
+			bugLog.Debugf("batchHandler body close: %+v", err)
+		}
+	}()
+
+	a, err := RequestAgent(p.Config, r)
+	if err != nil {
+		errorReportStatus(w, http.StatusForbidden, "batchHandler invalid agent", err)
+		return
+	}
+
+	items, err := decodeBatch(w, r)
+	if err != nil {
+		errorReport(w, "batchHandler decodeBatch", err)
+		return
+	}
+
+	resp := BatchResponse{
+		Results: []BatchResult{},
+	}
+	for i, item := range items {
+		bug := Bug{}
+		if err := json.Unmarshal(item, &bug); err != nil {
+			resp.add(i, "", bugLog.Errorf("decode: %+v", err))
+			continue
+		}
+		if bug.Bug == "" && bug.Raw == "" {
+			resp.add(i, "", bugLog.Errorf("bug has no content"))
+			continue
+		}
+
+		bug.Agent.Key = a.Key
+		resp.add(i, "queued", p.Enqueue(bug))
+	}
+
+	jsonResponse(w, "celeste_bug_batch", resp)
+}
+
+// BatchHandler stores every log of the batch on its own, the results say which ones made it
+func (l ProcessLog) BatchHandler(w http.ResponseWriter, r *http.Request) {
+	defer func() {
+		if err := r.Body.Close(); err != nil {
+			bugLog.Debugf("batchHandler body close: %+v", err)
+		}
+	}()
+
+	a, err := RequestAgent(l.Config, r)
+	if err != nil {
+		errorReportStatus(w, http.StatusForbidden, "batchHandler invalid agent", err)
+		return
+	}
+
+	items, err := decodeBatch(w, r)
+	if err != nil {
+		errorReport(w, "batchHandler decodeBatch", err)
+		return
+	}
+
+	resp := BatchResponse{
+		Results: []BatchResult{},
+	}
+	retention := l.Retention(a.Account.ID)
+	for i, item := range items {
+		log := Log{}
+		if err := json.Unmarshal(item, &log); err != nil {
+			resp.add(i, "", bugLog.Errorf("decode: %+v", err))
+			continue
+		}
+		log.Agent.Credentials = a.Credentials
+		log.Agent.Account = a.Account
+		log.Agent.Identifier = a.Identifier
+		log.Retention = retention
+		log.Verified = true
+
+		if err := l.GenerateLogInfo(&log, a.Key); err != nil {
+			resp.add(i, "", err)
+			continue
+		}
+		resp.add(i, "stored", l.StoreLog(&log))
+	}
+
+	jsonResponse(w, "celeste_log_batch", resp)
+}
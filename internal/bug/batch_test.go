@@ -0,0 +1,97 @@
+package bug_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bugfixes/celeste/internal/agent"
+	bug "github.com/bugfixes/celeste/internal/bug"
+	"github.com/bugfixes/celeste/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcessBug_BatchHandler(t *testing.T) {
+	t.Cleanup(resetMemory)
+
+	cfg := config.Config{
+		Storage: config.Storage{
+			StorageBackend: config.StorageMemory,
+		},
+		Queues: config.Queues{
+			QueueBackend: config.QueueLocal,
+		},
+	}
+	if err := agent.NewAgent(cfg).Store(agent.Agent{
+		Credentials: agent.Credentials{
+			Key:    "batch-key",
+			Secret: "batch-secret",
+		},
+	}); err != nil {
+		t.Errorf("store agent err: %+v", err)
+	}
+
+	tests := []struct {
+		name     string
+		request  string
+		status   int
+		accepted int
+		failed   int
+	}{
+		{
+			name:     "array",
+			request:  `[{"bug": "tester"}, {"raw": "bob"}]`,
+			status:   http.StatusOK,
+			accepted: 2,
+		},
+		{
+			name:     "ndjson with bad items",
+			request:  "{\"bug\": \"tester\"}\n\n{\"level\": \"error\"}\n{broken\n",
+			status:   http.StatusOK,
+			accepted: 1,
+			failed:   2,
+		},
+		{
+			name:    "broken array",
+			request: `[{"bug": "tester"},`,
+			status:  http.StatusBadRequest,
+		},
+		{
+			name:    "empty",
+			request: "  \n",
+			status:  http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/bug/batch", strings.NewReader(test.request))
+			r.Header.Set("X-API-KEY", "batch-key")
+			r.Header.Set("X-API-SECRET", "batch-secret")
+			w := httptest.NewRecorder()
+
+			bug.NewBug(cfg).BatchHandler(w, r)
+			if passed := assert.Equal(t, test.status, w.Code); !passed {
+				t.Errorf("batch status expect: %v, got: %v", test.status, w.Code)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+
+			resp := struct {
+				Data bug.BatchResponse `json:"data"`
+			}{}
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Errorf("batch decode err: %+v", err)
+			}
+			if passed := assert.Equal(t, test.accepted, resp.Data.Accepted); !passed {
+				t.Errorf("batch accepted expect: %v, got: %v", test.accepted, resp.Data.Accepted)
+			}
+			if passed := assert.Equal(t, test.failed, resp.Data.Failed); !passed {
+				t.Errorf("batch failed expect: %v, got: %v", test.failed, resp.Data.Failed)
+			}
+		})
+	}
+}
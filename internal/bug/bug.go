@@ -12,6 +12,7 @@ import (
 type Bug struct {
 	agent.Agent
 
+	Environment   string `json:"environment"`
 	File          string `json:"file"`
 	Line          string `json:"line"`
 	LineNumber    int    `json:"line_number"`
@@ -22,11 +23,33 @@ type Bug struct {
 	Level         string `json:"level"`
 	LevelNumber   int    `json:"level_number"`
 	Hash          string `json:"hash"`
+	Fingerprint   string `json:"fingerprint"`
 	Identifier    string `json:"identifier"`
 	TimesReported int    `json:"times_reported"`
+	Status        string `json:"status"`
+
+	Release string            `json:"release"`
+	Commit  string            `json:"commit"`
+	Tags    map[string]string `json:"tags"`
+
+	// LogID is the log the bug was promoted from, when it came in as a log
+	LogID string `json:"log_id,omitempty"`
+
+	// Muted bugs are counted but get no ticket and no comms
+	Muted     bool `json:"-"`
+	Regressed bool `json:"-"`
+	// NewInRelease is set when the bug hadn't been seen in the release it was reported from
+	NewInRelease bool `json:"-"`
 
 	RemoteLink   string `json:"-"`
 	TicketSystem string `json:"-"`
+	// State is the bug's NotifyState
+	State string `json:"-"`
+
+	// Occurrences, Minutes and Hours are the reports a day, minute and hour, for the alert rules and the spike check
+	Occurrences map[string]int `json:"-"`
+	Minutes     map[string]int `json:"-"`
+	Hours       map[string]int `json:"-"`
 
 	FirstReported time.Time
 	LastReported  time.Time
@@ -96,26 +119,55 @@ func ConvertLevelFromString(s string) int {
 	return lvl
 }
 
-func (b *Bug) ReportedTimes(c config.Config) error {
+// ReportedTimes counts the report on the stored bug, what was counted is what MoveStatus then moves on
+func (b *Bug) ReportedTimes(c config.Config) (BugRecord, error) {
 	bugInfo, err := NewBugStorage(c).FindAndStore(BugRecord{
-		ID:      b.Identifier,
-		AgentID: b.Agent.UUID,
-		Hash:    b.Hash,
-		Full: struct {
-			Pretty string
-			Raw    string
-		}{
-			Pretty: b.Bug,
-			Raw:    b.Raw,
+		ID:          b.Identifier,
+		AgentID:     b.Agent.UUID,
+		Hash:        b.Hash,
+		Fingerprint: b.Fingerprint,
+		Full: FullDetails{
+			Pretty:  b.Bug,
+			Raw:     b.Raw,
+			Tags:    b.Tags,
+			Release: b.Release,
+			Commit:  b.Commit,
+			LogID:   b.LogID,
 		},
-		Level: b.Level,
+		Environment: b.Environment,
+		Level:       b.Level,
+		File:        b.File,
+		Line:        b.Line,
+		LastRelease: b.Release,
+		LastCommit:  b.Commit,
 	})
 	if err != nil {
-		return bugLog.Errorf("bug reported times failed find: %+v", err)
+		return BugRecord{}, bugLog.Errorf("bug reported times failed find: %+v", err)
 	}
+	b.Identifier = bugInfo.ID
 	b.TimesReported = bugInfo.TimesReportedNumber
 	b.LastReported = bugInfo.LastReportedTime
 	b.FirstReported = bugInfo.FirstReportedTime
+	b.NewInRelease = bugInfo.NewRelease
+	b.Occurrences = bugInfo.Occurrences
+	b.Minutes = bugInfo.Minutes
+	b.Hours = bugInfo.Hours
+
+	return bugInfo, nil
+}
+
+// MoveStatus moves the bug on now another report of it has been counted, a resolved bug has regressed
+// and a snooze that has run out ends, it only looks at the counted record, so a retry moves it the same way
+func (b *Bug) MoveStatus(c config.Config, bugInfo BugRecord) error {
+	if bugInfo.Occurred(time.Now()) {
+		if err := NewBugStorage(c).UpdateStatus(bugInfo); err != nil {
+			return bugLog.Errorf("bug moveStatus updateStatus: %+v", err)
+		}
+	}
+	b.Status = bugInfo.CurrentStatus()
+	b.Muted = bugInfo.Muted()
+	b.Regressed = bugInfo.Regressed
+	b.State = bugInfo.NotifyState()
 
 	return nil
 }
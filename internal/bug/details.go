@@ -0,0 +1,76 @@
+package bug
+
+import (
+	"sort"
+	"time"
+)
+
+type OccurrenceStats struct {
+	Total     int    `json:"total"`
+	Days      int    `json:"days"`
+	Today     int    `json:"today"`
+	LastWeek  int    `json:"last_week"`
+	PeakDay   string `json:"peak_day"`
+	PeakCount int    `json:"peak_count"`
+}
+
+type LinkedTicket struct {
+	System string `json:"system"`
+	Link   string `json:"link"`
+}
+
+// BugDetails is a single bug as the read API returns it
+type BugDetails struct {
+	BugRecord
+
+	Stats  OccurrenceStats `json:"stats"`
+	Ticket *LinkedTicket   `json:"ticket,omitempty"`
+}
+
+func NewBugDetails(bri BugRecord, now time.Time) BugDetails {
+	d := BugDetails{
+		BugRecord: bri,
+		Stats:     NewOccurrenceStats(bri, now),
+	}
+	if bri.RemoteLink != "" {
+		d.Ticket = &LinkedTicket{
+			System: bri.TicketSystem,
+			Link:   bri.RemoteLink,
+		}
+	}
+
+	return d
+}
+
+// NewOccurrenceStats sums up the daily histogram, the last week includes today
+func NewOccurrenceStats(bri BugRecord, now time.Time) OccurrenceStats {
+	stats := OccurrenceStats{
+		Total: bri.TimesReportedNumber,
+		Days:  len(bri.Occurrences),
+	}
+
+	today := now.UTC().Format(OccurrenceDayFormat)
+	weekStart := now.UTC().AddDate(0, 0, -6).Format(OccurrenceDayFormat)
+
+	days := make([]string, 0, len(bri.Occurrences))
+	for day := range bri.Occurrences {
+		days = append(days, day)
+	}
+	sort.Strings(days)
+
+	for _, day := range days {
+		count := bri.Occurrences[day]
+		if day == today {
+			stats.Today = count
+		}
+		if day >= weekStart && day <= today {
+			stats.LastWeek += count
+		}
+		if count > stats.PeakCount {
+			stats.PeakDay = day
+			stats.PeakCount = count
+		}
+	}
+
+	return stats
+}
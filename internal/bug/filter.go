@@ -0,0 +1,166 @@
+package bug
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	bugLog "github.com/bugfixes/go-bugfixes/logs"
+)
+
+const (
+	defaultListLimit = 25
+	maxListLimit     = 100
+)
+
+// ErrBugNotFound is returned as is, not wrapped, so handlers can tell a missing bug from a broken one
+var ErrBugNotFound = errors.New("bug not found")
+
+// ErrBugExists is what Store gives when the first report of the bug has already been stored by another worker
+var ErrBugExists = errors.New("bug already stored")
+
+type BugFilter struct {
+	AgentID string
+
+	Level            string
+	Environment      string
+	File             string
+	Status           string
+	FirstSeenFrom    time.Time
+	FirstSeenTo      time.Time
+	LastSeenFrom     time.Time
+	LastSeenTo       time.Time
+	MinTimesReported int
+	MaxTimesReported int
+
+	Limit  int
+	Cursor string
+}
+
+// ParseBugFilter builds the filter from the query string of a list request
+// nolint: gocyclo
+func ParseBugFilter(agentID string, q url.Values) (BugFilter, error) {
+	f := BugFilter{
+		AgentID:     agentID,
+		Level:       q.Get("level"),
+		Environment: q.Get("environment"),
+		File:        q.Get("file"),
+		Status:      q.Get("status"),
+		Cursor:      q.Get("cursor"),
+		Limit:       defaultListLimit,
+	}
+
+	times := map[string]*time.Time{
+		"first_seen_from": &f.FirstSeenFrom,
+		"first_seen_to":   &f.FirstSeenTo,
+		"last_seen_from":  &f.LastSeenFrom,
+		"last_seen_to":    &f.LastSeenTo,
+	}
+	for name, t := range times {
+		if v := q.Get(name); v != "" {
+			pt, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return f, bugLog.Errorf("parseBugFilter %s: %+v", name, err)
+			}
+			*t = pt
+		}
+	}
+
+	ints := map[string]*int{
+		"times_reported_min": &f.MinTimesReported,
+		"times_reported_max": &f.MaxTimesReported,
+		"limit":              &f.Limit,
+	}
+	for name, i := range ints {
+		if v := q.Get(name); v != "" {
+			pi, err := strconv.Atoi(v)
+			if err != nil {
+				return f, bugLog.Errorf("parseBugFilter %s: %+v", name, err)
+			}
+			*i = pi
+		}
+	}
+
+	if f.Limit <= 0 {
+		f.Limit = defaultListLimit
+	}
+	if f.Limit > maxListLimit {
+		f.Limit = maxListLimit
+	}
+
+	return f, nil
+}
+
+// Matches is the whole filter, backends that can't express part of it in their query use this for the rest
+// nolint: gocyclo
+func (f BugFilter) Matches(r BugRecord) bool {
+	if f.AgentID != "" && r.AgentID != f.AgentID {
+		return false
+	}
+	if f.Level != "" && r.Level != f.Level {
+		return false
+	}
+	if f.Environment != "" && r.Environment != f.Environment {
+		return false
+	}
+	if f.File != "" && r.File != f.File {
+		return false
+	}
+	if f.Status != "" && r.CurrentStatus() != f.Status {
+		return false
+	}
+	if !f.FirstSeenFrom.IsZero() && r.FirstReportedTime.Before(f.FirstSeenFrom) {
+		return false
+	}
+	if !f.FirstSeenTo.IsZero() && r.FirstReportedTime.After(f.FirstSeenTo) {
+		return false
+	}
+	if !f.LastSeenFrom.IsZero() && r.LastReportedTime.Before(f.LastSeenFrom) {
+		return false
+	}
+	if !f.LastSeenTo.IsZero() && r.LastReportedTime.After(f.LastSeenTo) {
+		return false
+	}
+	if f.MinTimesReported > 0 && r.TimesReportedNumber < f.MinTimesReported {
+		return false
+	}
+	if f.MaxTimesReported > 0 && r.TimesReportedNumber > f.MaxTimesReported {
+		return false
+	}
+
+	return true
+}
+
+// sortByLastReported puts the most recently reported bugs first, id keeps the order stable between pages
+func sortByLastReported(brs []BugRecord) {
+	sort.Slice(brs, func(i, j int) bool {
+		if brs[i].LastReportedTime.Equal(brs[j].LastReportedTime) {
+			return brs[i].ID < brs[j].ID
+		}
+		return brs[i].LastReportedTime.After(brs[j].LastReportedTime)
+	})
+}
+
+func encodeOffsetCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(offset)))
+}
+
+func decodeOffsetCursor(cursor string) (int, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, bugLog.Errorf("decodeOffsetCursor decode: %+v", err)
+	}
+	offset, err := strconv.Atoi(string(b))
+	if err != nil {
+		return 0, bugLog.Errorf("decodeOffsetCursor atoi: %+v", err)
+	}
+
+	return offset, nil
+}
@@ -0,0 +1,210 @@
+package bug
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/bugfixes/celeste/internal/database"
+)
+
+// FingerprintFrames is how many in-app frames, counted from the top of the stack, make up a fingerprint
+const FingerprintFrames = 5
+
+// Frame is the stored frame, so parsed stacks can be kept with the log as they are
+type Frame = database.StackFrame
+
+var (
+	goFileLine   = regexp.MustCompile(`^\s+(.+\.go):(\d+)(?: \+0x[0-9a-f]+)?$`)
+	pythonLine   = regexp.MustCompile(`^\s*File "(.+)", line (\d+), in (.+)$`)
+	javaLine     = regexp.MustCompile(`^\s*at ([\w$.<>/]+)\(([^:)]*)(?::(\d+))?\)$`)
+	jsLine       = regexp.MustCompile(`^\s*at (?:(?:async )?(.+?) \()?(.+?):(\d+):\d+\)?$`)
+	jsGeckoLine  = regexp.MustCompile(`^(.*)@(.+):(\d+):\d+$`)
+	volatileTime = regexp.MustCompile(`\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(?:\.\d+)?(?:Z|[+-]\d{2}:?\d{2})?`)
+	volatileUUID = regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`)
+	volatileAddr = regexp.MustCompile(`0x[0-9a-fA-F]+`)
+	volatileHex  = regexp.MustCompile(`\b[0-9a-fA-F]{16,}\b`)
+	volatileGo   = regexp.MustCompile(`goroutine \d+`)
+	volatileNum  = regexp.MustCompile(`\b\d+\b`)
+)
+
+// Normalise strips the tokens that change between two reports of the same bug,
+// addresses, uuids, timestamps, goroutine ids and plain numbers
+func Normalise(s string) string {
+	s = volatileTime.ReplaceAllString(s, "<time>")
+	s = volatileUUID.ReplaceAllString(s, "<uuid>")
+	s = volatileAddr.ReplaceAllString(s, "<addr>")
+	s = volatileHex.ReplaceAllString(s, "<hex>")
+	s = volatileGo.ReplaceAllString(s, "goroutine <n>")
+	s = volatileNum.ReplaceAllString(s, "<n>")
+
+	return strings.TrimSpace(s)
+}
+
+// ParseStack pulls the frames out of a Go, Python, JavaScript or Java stack trace,
+// the most recent call is always the first frame
+// nolint: gocyclo
+func ParseStack(raw string) []Frame {
+	frames := []Frame{}
+	reverse := false
+
+	lines := strings.Split(strings.ReplaceAll(raw, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		if m := pythonLine.FindStringSubmatch(line); m != nil {
+			reverse = true
+			frames = append(frames, newFrame(m[3], m[1], m[2]))
+			continue
+		}
+		if m := javaLine.FindStringSubmatch(line); m != nil {
+			frames = append(frames, newFrame(m[1], m[2], m[3]))
+			continue
+		}
+		if m := jsLine.FindStringSubmatch(line); m != nil {
+			frames = append(frames, newFrame(m[1], m[2], m[3]))
+			continue
+		}
+		if m := goFileLine.FindStringSubmatch(line); m != nil {
+			function := ""
+			if i > 0 {
+				function = goFunction(lines[i-1])
+			}
+			frames = append(frames, newFrame(function, m[1], m[2]))
+			continue
+		}
+		if m := jsGeckoLine.FindStringSubmatch(line); m != nil {
+			frames = append(frames, newFrame(m[1], m[2], m[3]))
+		}
+	}
+
+	if reverse {
+		for i, j := 0, len(frames)-1; i < j; i, j = i+1, j-1 {
+			frames[i], frames[j] = frames[j], frames[i]
+		}
+	}
+
+	return frames
+}
+
+// goFunction strips the arguments and goroutine notes from a go function line
+func goFunction(line string) string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "created by ")
+	if i := strings.Index(line, " in goroutine "); i != -1 {
+		line = line[:i]
+	}
+	if !strings.HasSuffix(line, ")") {
+		return line
+	}
+
+	depth := 0
+	for i := len(line) - 1; i >= 0; i-- {
+		switch line[i] {
+		case ')':
+			depth++
+		case '(':
+			depth--
+			if depth == 0 {
+				return line[:i]
+			}
+		}
+	}
+
+	return line
+}
+
+func newFrame(function, file, line string) Frame {
+	l, _ := strconv.Atoi(line)
+	function = strings.TrimSpace(function)
+
+	return Frame{
+		Function: function,
+		File:     file,
+		Line:     l,
+		InApp:    inApp(function, file),
+	}
+}
+
+var (
+	vendorPaths = []string{
+		"/usr/local/go/src/",
+		"/go/src/runtime/",
+		"/pkg/mod/",
+		"/vendor/",
+		"site-packages",
+		"dist-packages",
+		"/lib/python",
+		"node_modules",
+		"node:",
+		"<frozen ",
+		"Native Method",
+		"Unknown Source",
+	}
+	vendorFunctions = []string{
+		"runtime.",
+		"runtime/debug.",
+		"testing.",
+		"net/http.",
+		"java.",
+		"javax.",
+		"jdk.",
+		"sun.",
+		"com.sun.",
+		"kotlin.",
+	}
+)
+
+// inApp is false for library frames, a trimmed standard library frame is internal/poll/fd.go in internal/poll,
+// an app's own internal/ files are still in app, their functions start with the app's module
+func inApp(function, file string) bool {
+	if strings.HasPrefix(file, "internal/") && strings.HasPrefix(function, "internal/") {
+		return false
+	}
+	for _, p := range vendorPaths {
+		if strings.Contains(file, p) {
+			return false
+		}
+	}
+	for _, p := range vendorFunctions {
+		if strings.HasPrefix(function, p) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func frameFile(file string) string {
+	if i := strings.LastIndexAny(file, `/\`); i != -1 {
+		return file[i+1:]
+	}
+	return file
+}
+
+// GenerateFingerprint hashes the top in-app frames of the stack in raw,
+// if there are no frames to go on it falls back to the normalised raw text
+func GenerateFingerprint(raw string) string {
+	frames := ParseStack(raw)
+
+	appFrames := []Frame{}
+	for _, f := range frames {
+		if f.InApp {
+			appFrames = append(appFrames, f)
+		}
+	}
+	if len(appFrames) == 0 {
+		appFrames = frames
+	}
+	if len(appFrames) == 0 {
+		return GenerateHash(Normalise(raw))
+	}
+	if len(appFrames) > FingerprintFrames {
+		appFrames = appFrames[:FingerprintFrames]
+	}
+
+	parts := make([]string, 0, len(appFrames))
+	for _, f := range appFrames {
+		parts = append(parts, Normalise(f.Function)+"|"+frameFile(f.File))
+	}
+
+	return GenerateHash(strings.Join(parts, "\n"))
+}
@@ -0,0 +1,167 @@
+package bug_test
+
+import (
+	"testing"
+
+	bug "github.com/bugfixes/celeste/internal/bug"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	goPanicA = `panic: runtime error: index out of range [5] with length 3
+
+goroutine 18 [running]:
+github.com/bugfixes/tester/internal/thing.(*Thing).Do(0xc000123456, {0x1, 0x2})
+	/home/runner/tester/internal/thing/thing.go:42 +0x1d
+github.com/bugfixes/tester/internal/thing.Run(...)
+	/home/runner/tester/internal/thing/run.go:12
+runtime.goexit()
+	/usr/local/go/src/runtime/asm_amd64.s:1581 +0x1
+created by main.main in goroutine 1
+	/home/runner/tester/main.go:20 +0x3a`
+	goPanicB = `panic: runtime error: index out of range [9] with length 1
+
+goroutine 7 [running]:
+github.com/bugfixes/tester/internal/thing.(*Thing).Do(0xc000999999, {0x3, 0x4})
+	/app/tester/internal/thing/thing.go:42 +0x2f
+github.com/bugfixes/tester/internal/thing.Run(...)
+	/app/tester/internal/thing/run.go:12
+runtime.goexit()
+	/usr/local/go/src/runtime/asm_amd64.s:1581 +0x1
+created by main.main in goroutine 1
+	/app/tester/main.go:20 +0x3a`
+	goPanicOther = `panic: nil pointer dereference
+
+goroutine 1 [running]:
+main.other()
+	/app/tester/main.go:99 +0x1d`
+	pythonTrace = `Traceback (most recent call last):
+  File "/usr/lib/python3.9/site-packages/flask/app.py", line 2073, in wsgi_app
+    response = self.full_dispatch_request()
+  File "/app/views.py", line 12, in index
+    return load(42)
+  File "/app/loader.py", line 7, in load
+    raise KeyError(id)
+KeyError: 42`
+	javascriptTrace = `TypeError: Cannot read properties of undefined (reading 'id')
+    at getUser (/app/src/users.js:10:15)
+    at async handler (/app/src/routes.js:22:5)
+    at Layer.handle (/app/node_modules/express/lib/router/layer.js:95:5)`
+	goTrimmed = `goroutine 1 [running]:
+internal/poll.(*FD).Read(0xc000124000, {0xc000200000, 0x1000, 0x1000})
+	internal/poll/fd_unix.go:167 +0x25a
+github.com/bugfixes/tester/internal/thing.Read(...)
+	internal/thing/read.go:15 +0x1d`
+	javaTrace = `java.lang.NullPointerException: user was null
+	at com.example.users.UserService.find(UserService.java:31)
+	at com.example.Main.main(Main.java:8)
+	at java.base/java.lang.Thread.run(Thread.java:833)`
+)
+
+func TestParseStack(t *testing.T) {
+	tests := []struct {
+		name    string
+		request string
+		expect  []bug.Frame
+	}{
+		{
+			name:    "go",
+			request: goPanicOther,
+			expect: []bug.Frame{
+				{Function: "main.other", File: "/app/tester/main.go", Line: 99, InApp: true},
+			},
+		},
+		{
+			name:    "go trimmed paths",
+			request: goTrimmed,
+			expect: []bug.Frame{
+				{Function: "internal/poll.(*FD).Read", File: "internal/poll/fd_unix.go", Line: 167, InApp: false},
+				{Function: "github.com/bugfixes/tester/internal/thing.Read", File: "internal/thing/read.go", Line: 15, InApp: true},
+			},
+		},
+		{
+			name:    "python",
+			request: pythonTrace,
+			expect: []bug.Frame{
+				{Function: "load", File: "/app/loader.py", Line: 7, InApp: true},
+				{Function: "index", File: "/app/views.py", Line: 12, InApp: true},
+				{Function: "wsgi_app", File: "/usr/lib/python3.9/site-packages/flask/app.py", Line: 2073, InApp: false},
+			},
+		},
+		{
+			name:    "javascript",
+			request: javascriptTrace,
+			expect: []bug.Frame{
+				{Function: "getUser", File: "/app/src/users.js", Line: 10, InApp: true},
+				{Function: "handler", File: "/app/src/routes.js", Line: 22, InApp: true},
+				{Function: "Layer.handle", File: "/app/node_modules/express/lib/router/layer.js", Line: 95, InApp: false},
+			},
+		},
+		{
+			name:    "java",
+			request: javaTrace,
+			expect: []bug.Frame{
+				{Function: "com.example.users.UserService.find", File: "UserService.java", Line: 31, InApp: true},
+				{Function: "com.example.Main.main", File: "Main.java", Line: 8, InApp: true},
+				{Function: "java.base/java.lang.Thread.run", File: "Thread.java", Line: 833, InApp: false},
+			},
+		},
+		{
+			name:    "no stack",
+			request: "tester",
+			expect:  []bug.Frame{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp := bug.ParseStack(test.request)
+			if passed := assert.Equal(t, test.expect, resp); !passed {
+				t.Errorf("parse expect: %v, got: %v", test.expect, resp)
+			}
+		})
+	}
+}
+
+func TestGenerateFingerprint(t *testing.T) {
+	tests := []struct {
+		name   string
+		a      string
+		b      string
+		expect bool
+	}{
+		{
+			name:   "same panic different goroutine and addresses",
+			a:      goPanicA,
+			b:      goPanicB,
+			expect: true,
+		},
+		{
+			name:   "different panic",
+			a:      goPanicA,
+			b:      goPanicOther,
+			expect: false,
+		},
+		{
+			name:   "message with volatile tokens",
+			a:      "user 123e4567-e89b-12d3-a456-426614174000 failed at 2021-04-01T10:00:00Z after 3 tries",
+			b:      "user 00000000-1111-2222-3333-444444444444 failed at 2022-05-02T11:11:11Z after 9 tries",
+			expect: true,
+		},
+		{
+			name:   "different message",
+			a:      "tester",
+			b:      "bob",
+			expect: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp := bug.GenerateFingerprint(test.a) == bug.GenerateFingerprint(test.b)
+			if passed := assert.Equal(t, test.expect, resp); !passed {
+				t.Errorf("fingerprint expect: %v, got: %v", test.expect, resp)
+			}
+		})
+	}
+}
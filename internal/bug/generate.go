@@ -25,6 +25,12 @@ func (b *Bug) GenerateHash() error {
 	return nil
 }
 
+func (b *Bug) GenerateFingerprint() error {
+	b.Fingerprint = GenerateFingerprint(b.Raw)
+
+	return nil
+}
+
 func (l *Log) GenerateIdentifier() error {
 	ident, err := GenerateIdentifier()
 	if err != nil {
@@ -39,6 +45,15 @@ func GenerateHash(data string) string {
 	return fmt.Sprintf("%x", sha256.Sum256([]byte(data)))
 }
 
+// bugNamespace keeps the ids made by BugRecordID apart from any other name based uuid
+var bugNamespace = uuid.MustParse("6f1c1f0e-2d55-4c8e-9a0b-5f3e8c7d2a41")
+
+// BugRecordID is the id the first report of a bug is stored under, the same agent, environment and fingerprint
+// always give the same id, so two first reports at once can only store one record
+func BugRecordID(agentID, environment, fingerprint string) string {
+	return uuid.NewSHA1(bugNamespace, []byte(agentID+"\n"+environment+"\n"+fingerprint)).String()
+}
+
 func GenerateIdentifier() (string, error) {
 	ident, err := uuid.NewUUID()
 	if err != nil {
@@ -0,0 +1,239 @@
+package bug
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bugfixes/celeste/internal/database"
+	bugLog "github.com/bugfixes/go-bugfixes/logs"
+	"github.com/gorilla/mux"
+)
+
+// TailInterval is how often a tail looks for new logs
+var TailInterval = time.Second
+
+// tailHeartbeat keeps quiet tails from being closed by proxies in between
+const tailHeartbeat = 15 * time.Second
+
+// WantsTail is whether the request is for a tail rather than a page, it is a mux matcher so the tail
+// can be routed around the timeout the other requests get
+func WantsTail(r *http.Request, _ *mux.RouteMatch) bool {
+	if v := r.URL.Query().Get("tail"); v == "true" || v == "1" {
+		return true
+	}
+
+	return strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
+
+// QueryHandler lists the agent's logs, newest first, or tails them when asked for an event stream
+func (l ProcessLog) QueryHandler(w http.ResponseWriter, r *http.Request) {
+	a, err := RequestAgent(l.Config, r)
+	if err != nil {
+		errorReportStatus(w, http.StatusForbidden, "queryHandler invalid agent", err)
+		return
+	}
+
+	filter, err := database.ParseLogFilter(a.UUID, r.URL.Query())
+	if err != nil {
+		errorReport(w, "queryHandler parseLogFilter", err)
+		return
+	}
+
+	if WantsTail(r, nil) {
+		l.tail(w, r, filter)
+		return
+	}
+
+	lrs, cursor, err := database.NewLogStorage(*database.New(l.Config)).Query(filter)
+	if err != nil {
+		errorReportStatus(w, http.StatusInternalServerError, "queryHandler query", err)
+		return
+	}
+
+	for i := range lrs {
+		lrs[i] = ReadableLog(lrs[i])
+	}
+
+	jsonResponse(w, "celeste_log_list", struct {
+		Logs   []database.LogRecord `json:"logs"`
+		Cursor string               `json:"cursor,omitempty"`
+	}{
+		Logs:   lrs,
+		Cursor: cursor,
+	})
+}
+
+// newLogs is every log matching the filter received at or after since, in the order they were received,
+// a log's own time is the sender's and a late one would land behind the tail
+func (l ProcessLog) newLogs(storage database.LogStorage, filter database.LogFilter, since time.Time) ([]database.LogRecord, error) {
+	filter.From = time.Time{}
+	filter.To = time.Time{}
+	filter.Received = since
+	filter.Cursor = ""
+
+	lrs := []database.LogRecord{}
+	for {
+		page, cursor, err := storage.Query(filter)
+		if err != nil {
+			return lrs, bugLog.Errorf("processLog newLogs query: %+v", err)
+		}
+		lrs = append(lrs, page...)
+		if cursor == "" {
+			break
+		}
+		filter.Cursor = cursor
+	}
+
+	sort.SliceStable(lrs, func(i, j int) bool {
+		if lrs[i].Received.Equal(lrs[j].Received) {
+			return lrs[i].ID < lrs[j].ID
+		}
+		return lrs[i].Received.Before(lrs[j].Received)
+	})
+
+	return lrs, nil
+}
+
+// tailEventID carries when the log was received as well as its id, a client reconnecting with it as the
+// Last-Event-ID picks up where it left off
+func tailEventID(lr database.LogRecord) string {
+	return fmt.Sprintf("%d-%s", lr.Received.UnixNano(), lr.ID)
+}
+
+func parseTailEventID(eventID string) (time.Time, string, error) {
+	parts := strings.SplitN(eventID, "-", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return time.Time{}, "", bugLog.Errorf("parseTailEventID: invalid id %s", eventID)
+	}
+	n, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return time.Time{}, "", bugLog.Errorf("parseTailEventID time: %+v", err)
+	}
+
+	return time.Unix(0, n), parts[1], nil
+}
+
+// tailSince is where the tail starts, after the Last-Event-ID of a reconnect, then since, then the filter's from,
+// otherwise now
+func tailSince(r *http.Request, filter database.LogFilter) (time.Time, map[string]bool, error) {
+	seen := map[string]bool{}
+	if eventID := r.Header.Get("Last-Event-ID"); eventID != "" {
+		since, id, err := parseTailEventID(eventID)
+		if err != nil {
+			return since, seen, bugLog.Errorf("tailSince lastEventID: %+v", err)
+		}
+		seen[id] = true
+		return since, seen, nil
+	}
+	if v := r.URL.Query().Get("since"); v != "" {
+		since, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return since, seen, bugLog.Errorf("tailSince since: %+v", err)
+		}
+		return since, seen, nil
+	}
+	if !filter.From.IsZero() {
+		return filter.From, seen, nil
+	}
+
+	return time.Now(), seen, nil
+}
+
+func writeEvent(w http.ResponseWriter, event, id string, data interface{}) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return bugLog.Errorf("writeEvent marshal: %+v", err)
+	}
+	if id != "" {
+		if _, err := fmt.Fprintf(w, "id: %s\n", id); err != nil {
+			return bugLog.Errorf("writeEvent id: %+v", err)
+		}
+	}
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, b); err != nil {
+		return bugLog.Errorf("writeEvent data: %+v", err)
+	}
+
+	return nil
+}
+
+// tail streams the agent's logs as server-sent events until the client goes away,
+// the logs seen at the newest time are remembered so the next look doesn't send them again
+// nolint: gocyclo
+func (l ProcessLog) tail(w http.ResponseWriter, r *http.Request, filter database.LogFilter) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		errorReportStatus(w, http.StatusInternalServerError, "tail", bugLog.Errorf("streaming not supported"))
+		return
+	}
+
+	since, seen, err := tailSince(r, filter)
+	if err != nil {
+		errorReport(w, "tail since", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	lastWrite := time.Now()
+
+	storage := database.NewLogStorage(*database.New(l.Config))
+	ticker := time.NewTicker(TailInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+		}
+
+		lrs, err := l.newLogs(storage, filter, since)
+		if err != nil {
+			if err := writeEvent(w, "error", "", err.Error()); err != nil {
+				bugLog.Debugf("tail error event: %+v", err)
+			}
+			flusher.Flush()
+			return
+		}
+
+		sent := false
+		for _, lr := range lrs {
+			if seen[lr.ID] {
+				continue
+			}
+			if err := writeEvent(w, "log", tailEventID(lr), ReadableLog(lr)); err != nil {
+				bugLog.Debugf("tail log event: %+v", err)
+				return
+			}
+			sent = true
+
+			if lr.Received.After(since) {
+				since = lr.Received
+				seen = map[string]bool{}
+			}
+			seen[lr.ID] = true
+		}
+
+		if !sent && time.Since(lastWrite) < tailHeartbeat {
+			continue
+		}
+		if !sent {
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				bugLog.Debugf("tail heartbeat: %+v", err)
+				return
+			}
+		}
+		lastWrite = time.Now()
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,249 @@
+package bug_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bugfixes/celeste/internal/agent"
+	bug "github.com/bugfixes/celeste/internal/bug"
+	"github.com/bugfixes/celeste/internal/config"
+	"github.com/bugfixes/celeste/internal/database"
+	"github.com/stretchr/testify/assert"
+)
+
+func logQueryConfig(t *testing.T, key string) config.Config {
+	t.Cleanup(resetMemory)
+
+	cfg := config.Config{
+		Storage: config.Storage{
+			StorageBackend: config.StorageMemory,
+		},
+	}
+	if err := agent.NewAgent(cfg).Store(agent.Agent{
+		Credentials: agent.Credentials{
+			Key:    key,
+			Secret: key + "-secret",
+		},
+	}); err != nil {
+		t.Errorf("store agent err: %+v", err)
+	}
+
+	return cfg
+}
+
+func TestProcessLog_QueryHandler(t *testing.T) {
+	cfg := logQueryConfig(t, "query-key")
+
+	now := time.Now()
+	logs := database.NewLogStorage(*database.New(cfg))
+	for _, lr := range []database.LogRecord{
+		{ID: "q1", AgentID: "query-key", Environment: "staging", Level: "info", Entry: "started", LoggedTime: now.Add(-3 * time.Hour)},
+		{ID: "q2", AgentID: "query-key", Level: "error", Entry: "Disk full", LoggedTime: now.Add(-2 * time.Hour), Fields: map[string]string{"user_id": "42"}},
+		{ID: "q3", AgentID: "query-key", Level: "error", Entry: "timeout", LoggedTime: now.Add(-time.Hour), Fields: map[string]string{"user_id": "7"}},
+		{ID: "q4", AgentID: "other-key", Level: "error", Entry: "disk full", LoggedTime: now},
+	} {
+		if err := logs.Store(lr); err != nil {
+			t.Errorf("store log err: %+v", err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		query  string
+		expect []string
+		cursor bool
+	}{
+		{
+			name:   "everything for the agent",
+			query:  "",
+			expect: []string{"q3", "q2", "q1"},
+		},
+		{
+			name:   "level",
+			query:  "level=error",
+			expect: []string{"q3", "q2"},
+		},
+		{
+			name:   "search",
+			query:  "q=disk",
+			expect: []string{"q2"},
+		},
+		{
+			name:   "time range",
+			query:  "from=" + now.Add(-150*time.Minute).UTC().Format(time.RFC3339),
+			expect: []string{"q3", "q2"},
+		},
+		{
+			name:   "environment",
+			query:  "environment=staging",
+			expect: []string{"q1"},
+		},
+		{
+			name:   "field",
+			query:  "field.user_id=42",
+			expect: []string{"q2"},
+		},
+		{
+			name:   "paged",
+			query:  "limit=2",
+			expect: []string{"q3", "q2"},
+			cursor: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/log?"+test.query, nil)
+			r.Header.Set("X-API-KEY", "query-key")
+			r.Header.Set("X-API-SECRET", "query-key-secret")
+			w := httptest.NewRecorder()
+
+			bug.NewLog(cfg).QueryHandler(w, r)
+			if passed := assert.Equal(t, http.StatusOK, w.Code); !passed {
+				t.Fatalf("query status: %v, body: %s", w.Code, w.Body.String())
+			}
+
+			resp := struct {
+				Data struct {
+					Logs   []database.LogRecord `json:"logs"`
+					Cursor string               `json:"cursor"`
+				} `json:"data"`
+			}{}
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("decode err: %+v", err)
+			}
+
+			ids := []string{}
+			for _, lr := range resp.Data.Logs {
+				ids = append(ids, lr.ID)
+			}
+			if passed := assert.Equal(t, test.expect, ids); !passed {
+				t.Errorf("query expect: %v, got: %v", test.expect, ids)
+			}
+			if passed := assert.Equal(t, test.cursor, resp.Data.Cursor != ""); !passed {
+				t.Errorf("query cursor got: %v", resp.Data.Cursor)
+			}
+		})
+	}
+}
+
+func TestProcessLog_QueryHandlerTail(t *testing.T) {
+	cfg := logQueryConfig(t, "tail-key")
+	bug.TailInterval = 10 * time.Millisecond
+
+	srv := httptest.NewServer(http.HandlerFunc(bug.NewLog(cfg).QueryHandler))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	r, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/log?level=error", nil)
+	if err != nil {
+		t.Fatalf("request err: %+v", err)
+	}
+	r.Header.Set("Accept", "text/event-stream")
+	r.Header.Set("X-API-KEY", "tail-key")
+	r.Header.Set("X-API-SECRET", "tail-key-secret")
+
+	resp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		t.Fatalf("tail err: %+v", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			t.Errorf("tail body close: %+v", err)
+		}
+	}()
+	if passed := assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type")); !passed {
+		t.Fatalf("tail content type: %v", resp.Header.Get("Content-Type"))
+	}
+
+	// t2 was logged before the tail started, but it is only now being received
+	logs := database.NewLogStorage(*database.New(cfg))
+	for _, lr := range []database.LogRecord{
+		{ID: "t1", AgentID: "tail-key", Level: "info", Entry: "ignored", LoggedTime: time.Now(), Received: time.Now()},
+		{ID: "t2", AgentID: "tail-key", Level: "error", Entry: "tailed", LoggedTime: time.Now().Add(-time.Hour), Received: time.Now()},
+	} {
+		if err := logs.Store(lr); err != nil {
+			t.Errorf("store log err: %+v", err)
+		}
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+
+		lr := database.LogRecord{}
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &lr); err != nil {
+			t.Fatalf("tail unmarshal err: %+v", err)
+		}
+		if passed := assert.Equal(t, "t2", lr.ID); !passed {
+			t.Errorf("tail expect: t2, got: %v", lr.ID)
+		}
+		return
+	}
+	t.Errorf("tail ended without a log: %+v", scanner.Err())
+}
+
+func TestProcessLog_QueryHandlerTailResume(t *testing.T) {
+	cfg := logQueryConfig(t, "resume-key")
+	bug.TailInterval = 10 * time.Millisecond
+
+	received := time.Now().Add(-time.Minute)
+	logs := database.NewLogStorage(*database.New(cfg))
+	for _, lr := range []database.LogRecord{
+		{ID: "r1", AgentID: "resume-key", Level: "info", Entry: "sent", LoggedTime: received, Received: received},
+		{ID: "r2", AgentID: "resume-key", Level: "info", Entry: "missed", LoggedTime: received.Add(-time.Hour), Received: received.Add(time.Second)},
+	} {
+		if err := logs.Store(lr); err != nil {
+			t.Errorf("store log err: %+v", err)
+		}
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(bug.NewLog(cfg).QueryHandler))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	r, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/log?tail=true", nil)
+	if err != nil {
+		t.Fatalf("request err: %+v", err)
+	}
+	r.Header.Set("Last-Event-ID", fmt.Sprintf("%d-r1", received.UnixNano()))
+	r.Header.Set("X-API-KEY", "resume-key")
+	r.Header.Set("X-API-SECRET", "resume-key-secret")
+
+	resp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		t.Fatalf("tail err: %+v", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			t.Errorf("tail body close: %+v", err)
+		}
+	}()
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "id: ") {
+			continue
+		}
+
+		expect := fmt.Sprintf("id: %d-r2", received.Add(time.Second).UnixNano())
+		if passed := assert.Equal(t, expect, line); !passed {
+			t.Errorf("tail resume expect: %s, got: %s", expect, line)
+		}
+		return
+	}
+	t.Errorf("tail ended without a log: %+v", scanner.Err())
+}
@@ -0,0 +1,99 @@
+package bug_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bugfixes/celeste/internal/account"
+	"github.com/bugfixes/celeste/internal/agent"
+	bug "github.com/bugfixes/celeste/internal/bug"
+	"github.com/bugfixes/celeste/internal/config"
+	"github.com/bugfixes/celeste/internal/database"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcessLog_StoreLogRetention(t *testing.T) {
+	t.Cleanup(resetMemory)
+
+	cfg := config.Config{
+		Storage: config.Storage{
+			StorageBackend: config.StorageMemory,
+		},
+	}
+	db := *database.New(cfg)
+	if err := database.NewAccountStorage(db).Insert(database.AccountRecord{
+		ID: "retention-account",
+		Retention: database.Retention{
+			"debug":                   3,
+			database.RetentionDefault: 30,
+			"error":                   0,
+		},
+	}); err != nil {
+		t.Fatalf("insert account err: %+v", err)
+	}
+	if err := agent.NewAgent(cfg).Store(agent.Agent{
+		Credentials: agent.Credentials{
+			Key:    "retention-key",
+			Secret: "retention-secret",
+		},
+		Account: account.Account{
+			ID: "retention-account",
+		},
+	}); err != nil {
+		t.Fatalf("store agent err: %+v", err)
+	}
+
+	tests := []struct {
+		name   string
+		level  string
+		expect time.Duration
+	}{
+		{name: "own level", level: "debug", expect: 3 * 24 * time.Hour},
+		{name: "default", level: "info", expect: 30 * 24 * time.Hour},
+		{name: "kept forever", level: "error"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/log", strings.NewReader(`{"level": "`+test.level+`", "log": "`+test.name+`"}`))
+			r.Header.Set("X-API-KEY", "retention-key")
+			r.Header.Set("X-API-SECRET", "retention-secret")
+			w := httptest.NewRecorder()
+
+			bug.NewLog(cfg).LogHandler(w, r)
+			if passed := assert.Equal(t, http.StatusCreated, w.Code); !passed {
+				t.Fatalf("log status: %v, body: %s", w.Code, w.Body.String())
+			}
+
+			lrs, _, err := database.NewLogStorage(db).Query(database.LogFilter{
+				AgentID: "retention-key",
+				Search:  test.name,
+			})
+			if err != nil || len(lrs) != 1 {
+				t.Fatalf("query err: %+v, logs: %v", err, lrs)
+			}
+
+			lr := lrs[0]
+			if test.expect == 0 {
+				if passed := assert.True(t, lr.Expires.IsZero()); !passed {
+					t.Errorf("expires expect forever, got: %v", lr.Expires)
+				}
+				return
+			}
+			if passed := assert.WithinDuration(t, lr.LoggedTime.Add(test.expect), lr.Expires, time.Second); !passed {
+				t.Errorf("expires expect: %v, got: %v", test.expect, lr.Expires.Sub(lr.LoggedTime))
+			}
+		})
+	}
+
+	pruned, err := database.NewLogStorage(db).Prune(time.Now().Add(10 * 24 * time.Hour))
+	if passed := assert.Nil(t, err); !passed {
+		t.Errorf("prune err: %+v", err)
+	}
+	if passed := assert.Equal(t, 1, pruned); !passed {
+		t.Errorf("prune expect: 1, got: %v", pruned)
+	}
+}
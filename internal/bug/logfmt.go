@@ -0,0 +1,161 @@
+package bug
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FormatLogFmt writes fields as a logfmt line sorted by key, so the same fields always give the same line
+func FormatLogFmt(fields map[string]string) string {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		v := fields[k]
+		if v == "" || strings.ContainsAny(v, " =\"") {
+			v = strconv.Quote(v)
+		}
+		parts = append(parts, fmt.Sprintf("%s=%s", k, v))
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// ParseLogFmt reads a logfmt line, values can be quoted with the usual escapes,
+// a key on its own is taken as "true"
+// nolint: gocyclo
+func ParseLogFmt(line string) map[string]string {
+	fields := map[string]string{}
+
+	i := 0
+	for i < len(line) {
+		for i < len(line) && (line[i] == ' ' || line[i] == '\t') {
+			i++
+		}
+		start := i
+		for i < len(line) && line[i] != '=' && line[i] != ' ' && line[i] != '\t' {
+			i++
+		}
+		key := line[start:i]
+		if i >= len(line) || line[i] != '=' {
+			if key != "" {
+				fields[key] = "true"
+			}
+			continue
+		}
+		i++
+
+		value := ""
+		if i < len(line) && line[i] == '"' {
+			end := i + 1
+			for end < len(line) && line[end] != '"' {
+				if line[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(line) {
+				end = len(line) - 1
+			}
+			quoted := line[i : end+1]
+			if unquoted, err := strconv.Unquote(quoted); err == nil {
+				value = unquoted
+			} else {
+				value = strings.Trim(quoted, `"`)
+			}
+			i = end + 1
+		} else {
+			start = i
+			for i < len(line) && line[i] != ' ' && line[i] != '\t' {
+				i++
+			}
+			value = line[start:i]
+		}
+
+		if key != "" {
+			fields[key] = value
+		}
+	}
+
+	return fields
+}
+
+// parseJSONFields flattens a JSON object, nested keys are joined with a dot,
+// anything that isn't a string is kept as its JSON
+func parseJSONFields(line string) (map[string]string, bool) {
+	if !strings.HasPrefix(line, "{") {
+		return nil, false
+	}
+
+	obj := map[string]interface{}{}
+	d := json.NewDecoder(strings.NewReader(line))
+	d.UseNumber()
+	if err := d.Decode(&obj); err != nil {
+		return nil, false
+	}
+
+	fields := map[string]string{}
+	flattenJSON(fields, "", obj)
+
+	return fields, true
+}
+
+func flattenJSON(fields map[string]string, prefix string, obj map[string]interface{}) {
+	for k, v := range obj {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+
+		switch value := v.(type) {
+		case map[string]interface{}:
+			flattenJSON(fields, key, value)
+		case string:
+			fields[key] = value
+		case json.Number:
+			fields[key] = value.String()
+		case nil:
+			fields[key] = ""
+		default:
+			b, err := json.Marshal(value)
+			if err != nil {
+				continue
+			}
+			fields[key] = string(b)
+		}
+	}
+}
+
+// ParseFields pulls the key/values out of a log, the log_fmt can be logfmt or a JSON object,
+// an entry that is itself a JSON object adds its fields too, the log_fmt wins when both have a key
+func ParseFields(logFmt, entry string) map[string]string {
+	fields := map[string]string{}
+
+	if entryFields, ok := parseJSONFields(strings.TrimSpace(entry)); ok {
+		for k, v := range entryFields {
+			fields[k] = v
+		}
+	}
+
+	logFmt = strings.TrimSpace(logFmt)
+	if logFmt == "" {
+		return fields
+	}
+
+	parsed, ok := parseJSONFields(logFmt)
+	if !ok {
+		parsed = ParseLogFmt(logFmt)
+	}
+	for k, v := range parsed {
+		fields[k] = v
+	}
+
+	return fields
+}
@@ -0,0 +1,70 @@
+package bug_test
+
+import (
+	"testing"
+
+	bug "github.com/bugfixes/celeste/internal/bug"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFields(t *testing.T) {
+	tests := []struct {
+		name   string
+		logFmt string
+		entry  string
+		expect map[string]string
+	}{
+		{
+			name:   "logfmt",
+			logFmt: `level=error user_id=42 msg="disk is full" retry`,
+			expect: map[string]string{
+				"level":   "error",
+				"user_id": "42",
+				"msg":     "disk is full",
+				"retry":   "true",
+			},
+		},
+		{
+			name:   "escaped quote",
+			logFmt: `msg="say \"hi\"" empty=""`,
+			expect: map[string]string{
+				"msg":   `say "hi"`,
+				"empty": "",
+			},
+		},
+		{
+			name:   "json",
+			logFmt: `{"user_id": 42, "request": {"id": "abc", "ok": true}, "tags": ["a"], "none": null}`,
+			expect: map[string]string{
+				"user_id":    "42",
+				"request.id": "abc",
+				"request.ok": "true",
+				"tags":       `["a"]`,
+				"none":       "",
+			},
+		},
+		{
+			name:   "json entry with logfmt",
+			logFmt: "user_id=7",
+			entry:  `{"user_id": 42, "request_id": "abc"}`,
+			expect: map[string]string{
+				"user_id":    "7",
+				"request_id": "abc",
+			},
+		},
+		{
+			name:   "plain entry",
+			entry:  "something broke",
+			expect: map[string]string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := bug.ParseFields(test.logFmt, test.entry)
+			if passed := assert.Equal(t, test.expect, got); !passed {
+				t.Errorf("parse fields expect: %v, got: %v", test.expect, got)
+			}
+		})
+	}
+}
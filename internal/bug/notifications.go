@@ -0,0 +1,36 @@
+package bug
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/bugfixes/celeste/internal/comms"
+	"github.com/gorilla/mux"
+)
+
+// NotificationsHandler is the bug's ledger, what has been sent for it on which channel, newest first
+func (p ProcessBug) NotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	a, err := RequestAgent(p.Config, r)
+	if err != nil {
+		errorReportStatus(w, http.StatusForbidden, "notificationsHandler invalid agent", err)
+		return
+	}
+
+	bri, err := NewBugStorage(p.Config).Get(a.UUID, mux.Vars(r)["bugId"])
+	if err != nil {
+		if errors.Is(err, ErrBugNotFound) {
+			errorReportStatus(w, http.StatusNotFound, "notificationsHandler unknown bug", err)
+			return
+		}
+		errorReportStatus(w, http.StatusInternalServerError, "notificationsHandler get", err)
+		return
+	}
+
+	ledger, err := comms.NewCommsStorage(p.Config).Notifications(bri.ID)
+	if err != nil {
+		errorReportStatus(w, http.StatusInternalServerError, "notificationsHandler notifications", err)
+		return
+	}
+
+	jsonResponse(w, "celeste_bug_notifications", ledger)
+}
@@ -6,6 +6,8 @@ import (
 	"net/http"
 
 	"github.com/aws/aws-lambda-go/events"
+	agent "github.com/bugfixes/celeste/internal/agent"
+	"github.com/bugfixes/celeste/internal/config"
 	bugLog "github.com/bugfixes/go-bugfixes/logs"
 )
 
@@ -17,9 +19,13 @@ type Process interface {
 }
 
 func errorReport(w http.ResponseWriter, textError string, wrappedError error) {
+	errorReportStatus(w, http.StatusBadRequest, textError, wrappedError)
+}
+
+func errorReportStatus(w http.ResponseWriter, status int, textError string, wrappedError error) {
 	bugLog.Debugf("processFile errorReport: %+v", wrappedError)
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusBadRequest)
+	w.WriteHeader(status)
 	if err := json.NewEncoder(w).Encode(struct {
 		Error     string
 		FullError string
@@ -30,3 +36,39 @@ func errorReport(w http.ResponseWriter, textError string, wrappedError error) {
 		bugLog.Debugf("processFile errorReport json: %+v", err)
 	}
 }
+
+// jsonResponse writes the operation and its data the way every read endpoint answers
+func jsonResponse(w http.ResponseWriter, operation string, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(struct {
+		Operation string      `json:"operation"`
+		Data      interface{} `json:"data"`
+	}{
+		Operation: operation,
+		Data:      data,
+	}); err != nil {
+		bugLog.Debugf("processFile jsonResponse json: %+v", err)
+	}
+}
+
+// RequestAgent checks the key and secret of the request belong to an agent,
+// what the agent reported is stored against its key
+func RequestAgent(c config.Config, r *http.Request) (agent.Agent, error) {
+	a := agent.Agent{
+		Credentials: agent.Credentials{
+			Key:    r.Header.Get("X-API-KEY"),
+			Secret: r.Header.Get("X-API-SECRET"),
+		},
+	}
+	if a.Key == "" || a.Secret == "" {
+		return a, bugLog.Errorf("requestAgent: missing credentials")
+	}
+
+	if err := agent.NewAgent(c).Find(&a); err != nil {
+		return a, bugLog.Errorf("requestAgent find: %+v", err)
+	}
+	a.UUID = a.Key
+
+	return a, nil
+}
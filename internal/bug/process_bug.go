@@ -2,24 +2,60 @@ package bug
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
+	"github.com/bugfixes/celeste/internal/agent"
 	"github.com/bugfixes/celeste/internal/comms"
 	"github.com/bugfixes/celeste/internal/config"
 	"github.com/bugfixes/celeste/internal/logic"
+	"github.com/bugfixes/celeste/internal/queue"
 	"github.com/bugfixes/celeste/internal/ticketing"
 	bugLog "github.com/bugfixes/go-bugfixes/logs"
+	"github.com/gorilla/mux"
 )
 
 type ProcessBug struct {
 	Config config.Config
+	Queue  queue.Queue
 
 	CommsChannel string
 }
 
+// BugJob is a bug on the queue, the stages already done are kept so a retry carries on from where it failed
+type BugJob struct {
+	// Bug only carries the agent's key, the worker looks the rest of the agent up so its secret isn't left on the queue
+	Bug Bug `json:"bug"`
+
+	Stored      bool `json:"stored"`
+	StatusMoved bool `json:"status_moved"`
+	Ticketed    bool `json:"ticketed"`
+	CommsSent   bool `json:"comms_sent"`
+
+	// Record is the bug as it was counted, the status is moved on from it
+	Record *BugRecord `json:"record,omitempty"`
+
+	Muted        bool   `json:"muted"`
+	Regressed    bool   `json:"regressed"`
+	NewInRelease bool   `json:"new_in_release"`
+	RemoteLink   string `json:"remote_link"`
+	TicketSystem string `json:"ticket_system"`
+	State        string `json:"state"`
+
+	Occurrences map[string]int `json:"occurrences"`
+	Minutes     map[string]int `json:"minutes"`
+	Hours       map[string]int `json:"hours"`
+
+	// Spike is set once this report has claimed the bug's spike, so a retry still sends it
+	SpikeChecked bool         `json:"spike_checked"`
+	Spike        *logic.Spike `json:"spike,omitempty"`
+	SpikeSent    bool         `json:"spike_sent"`
+}
+
 func NewBug(c config.Config) ProcessBug {
 	return ProcessBug{
 		Config: c,
@@ -39,25 +75,195 @@ func (p ProcessBug) Fetch() (Response, error) {
 	return Response{}, nil
 }
 
-func (p ProcessBug) GenerateBugInfo(bug *Bug, agentID string) error {
+func (p ProcessBug) queue() (queue.Queue, error) {
+	if p.Queue != nil {
+		return p.Queue, nil
+	}
+
+	return queue.NewQueue(p.Config)
+}
+
+func (p ProcessBug) Enqueue(bug Bug) error {
+	bug.Agent = agent.Agent{
+		UUID: bug.Agent.UUID,
+		Credentials: agent.Credentials{
+			Key: bug.Agent.Key,
+		},
+	}
+	body, err := json.Marshal(BugJob{
+		Bug: bug,
+	})
+	if err != nil {
+		return bugLog.Errorf("bug enqueue marshal: %+v", err)
+	}
+
+	q, err := p.queue()
+	if err != nil {
+		return bugLog.Errorf("bug enqueue queue: %+v", err)
+	}
+	if err := q.Send(queue.Message{
+		Body: body,
+	}); err != nil {
+		return bugLog.Errorf("bug enqueue send: %+v", err)
+	}
+
+	return nil
+}
+
+// ProcessMessage is the worker side of BugHandler
+func (p ProcessBug) ProcessMessage(m *queue.Message) error {
+	job := BugJob{}
+	if err := json.Unmarshal(m.Body, &job); err != nil {
+		return bugLog.Errorf("bug processMessage unmarshal: %+v", err)
+	}
+
+	processErr := p.ProcessJob(&job)
+
+	body, err := json.Marshal(job)
+	if err != nil {
+		return bugLog.Errorf("bug processMessage marshal: %+v", err)
+	}
+	m.Body = body
+
+	return processErr
+}
+
+// ProcessJob counts the bug, tickets it and sends the comms, skipping whatever an earlier attempt finished
+func (p ProcessBug) ProcessJob(job *BugJob) error {
+	bug := &job.Bug
+	a := agent.Agent{
+		Credentials: agent.Credentials{
+			Key: bug.Agent.Key,
+		},
+	}
+	if err := agent.NewAgent(p.Config).FindByKey(&a); err != nil {
+		return bugLog.Errorf("bug processJob findByKey: %+v", err)
+	}
+	bug.Agent.Secret = a.Secret
+	bug.Agent.Account.ID = a.Account.ID
+	bug.Agent.Identifier = a.Identifier
+	bug.Muted = job.Muted
+	bug.Regressed = job.Regressed
+	bug.NewInRelease = job.NewInRelease
+	bug.RemoteLink = job.RemoteLink
+	bug.TicketSystem = job.TicketSystem
+	bug.State = job.State
+	bug.Occurrences = job.Occurrences
+	bug.Minutes = job.Minutes
+	bug.Hours = job.Hours
+
+	if !job.Stored {
+		bri, err := p.GenerateBugInfo(bug, bug.Agent.Key)
+		if err != nil {
+			return bugLog.Errorf("bug processJob generateBugInfo: %+v", err)
+		}
+		// the count is done, whatever fails after it mustn't count the report again
+		job.Stored = true
+		bri.Full = nil
+		job.Record = &bri
+		job.NewInRelease = bug.NewInRelease
+		job.Occurrences = bug.Occurrences
+		job.Minutes = bug.Minutes
+		job.Hours = bug.Hours
+	}
+
+	if !job.StatusMoved && job.Record != nil {
+		if err := bug.MoveStatus(p.Config, *job.Record); err != nil {
+			return bugLog.Errorf("bug processJob moveStatus: %+v", err)
+		}
+		job.StatusMoved = true
+		job.Muted = bug.Muted
+		job.Regressed = bug.Regressed
+		job.State = bug.State
+	}
+
+	if bug.Muted {
+		return nil
+	}
+
+	l := logic.NewLogic(p.Config)
+	lb := logic.LogicBug{
+		LastReported:  bug.LastReported,
+		FirstReported: bug.FirstReported,
+		TimesReported: bug.TimesReported,
+		Occurrences:   bug.Occurrences,
+		Minutes:       bug.Minutes,
+		Hours:         bug.Hours,
+		AccountID:     bug.Agent.Account.ID,
+		AgentID:       bug.Agent.Identifier,
+		Level:         bug.Level,
+		File:          bug.File,
+		Environment:   bug.Environment,
+		Muted:         bug.Muted,
+		Regressed:     bug.Regressed,
+	}
+
+	if !job.Ticketed && l.ShouldWeTicket(lb) {
+		if err := p.GenerateTicket(bug); err != nil {
+			return bugLog.Errorf("bug processJob generateTicket: %+v", err)
+		}
+		if err := p.LinkTicket(bug); err != nil {
+			return bugLog.Errorf("bug processJob linkTicket: %+v", err)
+		}
+		job.Ticketed = true
+		job.RemoteLink = bug.RemoteLink
+		job.TicketSystem = bug.TicketSystem
+	}
+
+	if !job.SpikeChecked {
+		now := time.Now()
+		if spike, ok := l.Spike(lb, now); ok {
+			marked, err := NewBugStorage(p.Config).MarkSpike(bug.Identifier, now, p.Config.Spike.Cooldown)
+			if err != nil {
+				return bugLog.Errorf("bug processJob markSpike: %+v", err)
+			}
+			if marked {
+				job.Spike = &spike
+			}
+		}
+		job.SpikeChecked = true
+	}
+	if job.Spike != nil && !job.SpikeSent {
+		if err := p.GenerateSpikeComms(bug, *job.Spike); err != nil {
+			return bugLog.Errorf("bug processJob generateSpikeComms: %+v", err)
+		}
+		job.SpikeSent = true
+	}
+
+	if d := l.Decision(lb); d.Report && !job.CommsSent {
+		if err := p.GenerateComms(bug, d); err != nil {
+			return bugLog.Errorf("bug processJob generateComms: %+v", err)
+		}
+		job.CommsSent = true
+	}
+
+	return nil
+}
+
+// GenerateBugInfo works out what the bug is and counts it, the record it was counted on is returned for MoveStatus
+func (p ProcessBug) GenerateBugInfo(bug *Bug, agentID string) (BugRecord, error) {
 	bug.Agent.UUID = agentID
 	if bug.Line == "" && bug.LineNumber != 0 {
 		bug.Line = strconv.Itoa(bug.LineNumber)
 	}
 
 	if err := bug.GenerateHash(); err != nil {
-		return bugLog.Errorf("generateBugInfo generateHash: %+v", err)
+		return BugRecord{}, bugLog.Errorf("generateBugInfo generateHash: %+v", err)
+	}
+	if err := bug.GenerateFingerprint(); err != nil {
+		return BugRecord{}, bugLog.Errorf("generateBugInfo generateFingerprint: %+v", err)
 	}
 	if err := bug.GenerateIdentifier(); err != nil {
-		return bugLog.Errorf("generateBugInfo generateIdentifier: %+v", err)
+		return BugRecord{}, bugLog.Errorf("generateBugInfo generateIdentifier: %+v", err)
 	}
-	if err := bug.ReportedTimes(p.Config); err != nil {
-		return bugLog.Errorf("generateBugInfo reportedTimes: %+v", err)
+	bri, err := bug.ReportedTimes(p.Config)
+	if err != nil {
+		return BugRecord{}, bugLog.Errorf("generateBugInfo reportedTimes: %+v", err)
 	}
 
 	bug.LevelNumber = ConvertLevelFromString(bug.Level)
 
-	return nil
+	return bri, nil
 }
 
 func (p ProcessBug) GenerateTicket(bug *Bug) error {
@@ -70,6 +276,10 @@ func (p ProcessBug) GenerateTicket(bug *Bug) error {
 		Line:          bug.Line,
 		File:          bug.File,
 		TimesReported: bug.TimesReported,
+		Frames:        ParseStack(bug.Raw),
+		Ref:           bug.Commit,
+		Release:       bug.Release,
+		NewInRelease:  bug.NewInRelease,
 	}
 
 	if err := ticketing.NewTicketing(p.Config).CreateTicket(&ticket); err != nil {
@@ -81,19 +291,74 @@ func (p ProcessBug) GenerateTicket(bug *Bug) error {
 	return nil
 }
 
-func (p ProcessBug) GenerateComms(bug *Bug) error {
-	if err := comms.NewComms(p.Config).SendComms(comms.CommsPackage{
+// LinkTicket keeps the ticket made for the bug on the stored bug, so the read API can point at it
+func (p ProcessBug) LinkTicket(bug *Bug) error {
+	if bug.RemoteLink == "" {
+		return nil
+	}
+
+	if err := NewBugStorage(p.Config).LinkTicket(BugRecord{
+		ID:           bug.Identifier,
+		RemoteLink:   bug.RemoteLink,
+		TicketSystem: bug.TicketSystem,
+	}); err != nil {
+		return bugLog.Errorf("bug linkTicket: %+v", err)
+	}
+
+	return nil
+}
+
+// commsPackage is the bug's details, what the comms template lays out
+func commsPackage(bug *Bug, kind string) comms.CommsPackage {
+	title := bug.Bug
+	if title == "" {
+		title = bug.Raw
+	}
+
+	return comms.CommsPackage{
 		Agent:        bug.Agent,
-		Message:      "tester message",
+		Kind:         kind,
 		Link:         bug.RemoteLink,
 		TicketSystem: bug.TicketSystem,
-	}); err != nil {
+
+		BugID:         bug.Identifier,
+		BugTitle:      title,
+		Level:         bug.Level,
+		Environment:   bug.Environment,
+		File:          bug.File,
+		Line:          bug.Line,
+		TimesReported: bug.TimesReported,
+		Regressed:     bug.Regressed,
+		FirstSeen:     bug.FirstReported,
+		LastSeen:      bug.LastReported,
+	}
+}
+
+// GenerateComms sends the bug, the rule that decided to is kept with it in the ledger, and sets its cooldown
+func (p ProcessBug) GenerateComms(bug *Bug, d logic.Decision) error {
+	cp := commsPackage(bug, comms.KindTicket)
+	cp.Rule = d.Rule
+	cp.State = bug.State
+	cp.Cooldown = d.Cooldown
+	if err := comms.NewComms(p.Config).SendComms(cp); err != nil {
 		return bugLog.Errorf("bug generateComms: %+v", err)
 	}
 
 	return nil
 }
 
+// GenerateSpikeComms sends the spike on its own, apart from the comms for the ticket
+func (p ProcessBug) GenerateSpikeComms(bug *Bug, spike logic.Spike) error {
+	cp := commsPackage(bug, comms.KindSpike)
+	cp.Message = spike.String()
+	if err := comms.NewComms(p.Config).SendComms(cp); err != nil {
+		return bugLog.Errorf("bug generateSpikeComms: %+v", err)
+	}
+
+	return nil
+}
+
+// BugHandler checks the bug can be processed and queues it, the worker does the rest
 func (p ProcessBug) BugHandler(w http.ResponseWriter, r *http.Request) {
 	bug := Bug{}
 	defer func() {
@@ -103,35 +368,124 @@ func (p ProcessBug) BugHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}()
 
+	a, err := RequestAgent(p.Config, r)
+	if err != nil {
+		errorReportStatus(w, http.StatusForbidden, "bugHandler invalid agent", err)
+		return
+	}
+
 	if err := json.NewDecoder(r.Body).Decode(&bug); err != nil {
 		errorReport(w, "bugHandler decode", err)
 		return
 	}
+	if bug.Bug == "" && bug.Raw == "" {
+		errorReport(w, "bugHandler validate", bugLog.Errorf("bug has no content"))
+		return
+	}
+
+	bug.Agent.Key = a.Key
 
-	if err := p.GenerateBugInfo(&bug, r.Header.Get("X-API-KEY")); err != nil {
-		errorReport(w, "bugHandler generateBugInfo", err)
+	if err := p.Enqueue(bug); err != nil {
+		errorReportStatus(w, http.StatusInternalServerError, "bugHandler enqueue", err)
 		return
 	}
 
-	bug.Agent.Key = r.Header.Get("X-API-KEY")
-	bug.Agent.Secret = r.Header.Get("X-API-SECRET")
+	w.WriteHeader(http.StatusAccepted)
+}
 
-	if err := p.GenerateTicket(&bug); err != nil {
-		errorReport(w, "bugHandler generateTicket", err)
+func (p ProcessBug) ListHandler(w http.ResponseWriter, r *http.Request) {
+	a, err := RequestAgent(p.Config, r)
+	if err != nil {
+		errorReportStatus(w, http.StatusForbidden, "listHandler invalid agent", err)
 		return
 	}
 
-	l := logic.NewLogic(p.Config)
-	if l.ShouldWeReport(logic.LogicBug{
-		LastReported:  bug.LastReported,
-		FirstReported: bug.FirstReported,
-		TimesReported: bug.TimesReported,
-	}) {
-		if err := p.GenerateComms(&bug); err != nil {
-			errorReport(w, "logHandler generateComms", err)
+	filter, err := ParseBugFilter(a.UUID, r.URL.Query())
+	if err != nil {
+		errorReport(w, "listHandler parseBugFilter", err)
+		return
+	}
+
+	brs, cursor, err := NewBugStorage(p.Config).List(filter)
+	if err != nil {
+		errorReportStatus(w, http.StatusInternalServerError, "listHandler list", err)
+		return
+	}
+
+	now := time.Now()
+	bugs := make([]BugDetails, 0, len(brs))
+	for _, bri := range brs {
+		bugs = append(bugs, NewBugDetails(bri, now))
+	}
+
+	jsonResponse(w, "celeste_bug_list", struct {
+		Bugs   []BugDetails `json:"bugs"`
+		Cursor string       `json:"cursor,omitempty"`
+	}{
+		Bugs:   bugs,
+		Cursor: cursor,
+	})
+}
+
+func (p ProcessBug) FetchHandler(w http.ResponseWriter, r *http.Request) {
+	a, err := RequestAgent(p.Config, r)
+	if err != nil {
+		errorReportStatus(w, http.StatusForbidden, "fetchHandler invalid agent", err)
+		return
+	}
+
+	bri, err := NewBugStorage(p.Config).Get(a.UUID, mux.Vars(r)["bugId"])
+	if err != nil {
+		if errors.Is(err, ErrBugNotFound) {
+			errorReportStatus(w, http.StatusNotFound, "fetchHandler unknown bug", err)
+			return
+		}
+		errorReportStatus(w, http.StatusInternalServerError, "fetchHandler get", err)
+		return
+	}
+
+	jsonResponse(w, "celeste_bug", NewBugDetails(bri, time.Now()))
+}
+
+func (p ProcessBug) StatusHandler(w http.ResponseWriter, r *http.Request) {
+	defer func() {
+		if err := r.Body.Close(); err != nil {
+			errorReport(w, "statusHandler body close", err)
+		}
+	}()
+
+	a, err := RequestAgent(p.Config, r)
+	if err != nil {
+		errorReportStatus(w, http.StatusForbidden, "statusHandler invalid agent", err)
+		return
+	}
+
+	change := StatusChange{}
+	if err := json.NewDecoder(r.Body).Decode(&change); err != nil {
+		errorReport(w, "statusHandler decode", err)
+		return
+	}
+
+	s := NewBugStorage(p.Config)
+	bri, err := s.Get(a.UUID, mux.Vars(r)["bugId"])
+	if err != nil {
+		if errors.Is(err, ErrBugNotFound) {
+			errorReportStatus(w, http.StatusNotFound, "statusHandler unknown bug", err)
 			return
 		}
+		errorReportStatus(w, http.StatusInternalServerError, "statusHandler get", err)
+		return
+	}
+
+	now := time.Now()
+	if err := bri.ChangeStatus(change, now); err != nil {
+		errorReport(w, "statusHandler changeStatus", err)
+		return
+	}
+	if err := s.UpdateStatus(bri); err != nil {
+		errorReportStatus(w, http.StatusInternalServerError, "statusHandler updateStatus", err)
+		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
+	jsonResponse(w, "celeste_bug_status", NewBugDetails(bri, now))
 }
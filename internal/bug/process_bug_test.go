@@ -1,43 +1,58 @@
 package bug_test
 
 import (
+	"strings"
 	"testing"
 
-	"github.com/aws/aws-lambda-go/events"
+	"github.com/bugfixes/celeste/internal/agent"
 	bug "github.com/bugfixes/celeste/internal/bug"
+	"github.com/bugfixes/celeste/internal/config"
+	"github.com/bugfixes/celeste/internal/queue"
+	"github.com/bugfixes/celeste/internal/queue/mocks"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
-func TestProcessFile(t *testing.T) {
-	tests := []struct {
-		name    string
-		request events.APIGatewayProxyRequest
-		expect  bug.Response
-		err     error
-	}{
-		{
-			name: "nothing to file",
-			request: events.APIGatewayProxyRequest{
-				Headers: map[string]string{
-					"tester": "bob",
-				},
-			},
-			expect: bug.Response{},
+func TestProcessBug_EnqueueKeepsSecretOffQueue(t *testing.T) {
+	t.Cleanup(resetMemory)
+
+	cfg := config.Config{
+		Storage: config.Storage{
+			StorageBackend: config.StorageMemory,
+		},
+	}
+
+	var sent queue.Message
+	q := &mocks.Queue{}
+	q.On("Send", mock.Anything).Run(func(args mock.Arguments) {
+		sent = args.Get(0).(queue.Message)
+	}).Return(nil).Once()
+
+	p := bug.NewBug(cfg)
+	p.Queue = q
+	b := bug.Bug{
+		Bug: "tester",
+	}
+	b.Agent = agent.Agent{
+		Credentials: agent.Credentials{
+			Key:    "enqueue-key",
+			Secret: "enqueue-secret",
 		},
 	}
+	if err := p.Enqueue(b); err != nil {
+		t.Fatalf("enqueue err: %+v", err)
+	}
+	q.AssertExpectations(t)
+
+	if passed := assert.Contains(t, string(sent.Body), "enqueue-key"); !passed {
+		t.Errorf("job has no agent key: %s", sent.Body)
+	}
+	if passed := assert.False(t, strings.Contains(string(sent.Body), "enqueue-secret")); !passed {
+		t.Errorf("job carries the agent's secret: %s", sent.Body)
+	}
 
-	for _, test := range tests {
-		t.Run(test.name, func(t *testing.T) {
-			resp, err := bug.ProcessBug{}.Parse(test.request)
-			if passed := assert.IsType(t, test.err, err); !passed {
-				t.Errorf("lookup err: %+v", err)
-			}
-			if passed := assert.Equal(t, test.expect, resp); !passed {
-				t.Errorf("lookup expect: %v, got: %v", test.expect, resp)
-			}
-			if passed := assert.Equal(t, test.err, err); !passed {
-				t.Errorf("lookup err failed - expected: %v, got: %v", test.err, err)
-			}
-		})
+	// the worker can't go on without finding the agent again
+	if err := p.ProcessMessage(&sent); err == nil {
+		t.Errorf("processMessage expected an error for an unknown agent")
 	}
 }
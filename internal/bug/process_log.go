@@ -2,34 +2,51 @@ package bug
 
 import (
 	"encoding/json"
-	"fmt"
+	"errors"
 	"net/http"
 	"time"
 
 	agent "github.com/bugfixes/celeste/internal/agent"
 	"github.com/bugfixes/celeste/internal/config"
 	"github.com/bugfixes/celeste/internal/database"
+	"github.com/bugfixes/celeste/internal/queue"
 	bugLog "github.com/bugfixes/go-bugfixes/logs"
 )
 
 type ProcessLog struct {
 	Config config.Config
+	Queue  queue.Queue
 }
 
 type Log struct {
 	agent.Agent
 
-	Line        string `json:"line"`
-	Level       string `json:"level"`
-	LevelNumber int    `json:"level_number"`
-	File        string `json:"file"`
-	Log         string `json:"log"`
-	Identifier  string `json:"identifier"`
-	Stack       []byte `json:"stack"`
-	LogFmt      string `json:"log_fmt"`
+	Line        string     `json:"line"`
+	Environment string     `json:"environment"`
+	Level       string     `json:"level"`
+	LevelNumber int        `json:"level_number"`
+	File        string     `json:"file"`
+	Log         string     `json:"log"`
+	Identifier  string     `json:"identifier"`
+	Stack       StackTrace `json:"stack"`
+	LogFmt      string     `json:"log_fmt"`
+
+	// Retention is the account's, it is looked up on store when it isn't already known
+	Retention database.Retention `json:"-"`
+	// Logged is when the sender says it logged, stored as the log's time when set, expiry still counts from the store
+	Logged time.Time `json:"-"`
+	// Verified is only set once the request's credentials have been checked, nothing else is stored
+	Verified bool `json:"-"`
 }
 
 func NewLog(c config.Config) ProcessLog {
+	// a config that didn't come from BuildConfig hasn't had its pattern compiled
+	if c.Promotion.Regexp == nil && c.Promotion.Pattern != "" {
+		if err := c.Promotion.Compile(); err != nil {
+			bugLog.Debugf("newLog promotion: %+v", err)
+		}
+	}
+
 	return ProcessLog{
 		Config: c,
 	}
@@ -43,10 +60,23 @@ func (l ProcessLog) LogHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}()
 
+	a, err := RequestAgent(l.Config, r)
+	if err != nil {
+		errorReportStatus(w, http.StatusForbidden, "logHandler invalid agent", err)
+		return
+	}
+
 	if err := json.NewDecoder(r.Body).Decode(&log); err != nil {
 		errorReport(w, "logHandler decode", err)
 		return
 	}
+	// the agent comes from the headers, never from the body
+	log.Agent = agent.Agent{
+		Credentials: a.Credentials,
+		Account:     a.Account,
+		Identifier:  a.Identifier,
+	}
+	log.Verified = true
 
 	if err := l.GenerateLogInfo(&log, r.Header.Get("X-API-KEY")); err != nil {
 		errorReport(w, "logHandler generateLogInfo", err)
@@ -71,21 +101,66 @@ func (l ProcessLog) GenerateLogInfo(log *Log, agentID string) error {
 	return nil
 }
 
+// Retention is the account's log retention, an account that can't be found keeps its logs forever
+func (l ProcessLog) Retention(accountID string) database.Retention {
+	if accountID == "" {
+		return database.Retention{}
+	}
+
+	ar, err := database.NewAccountStorage(*database.New(l.Config)).Fetch(accountID)
+	if err != nil {
+		bugLog.Debugf("processLog retention fetch: %+v", err)
+		return database.Retention{}
+	}
+	if ar.Retention == nil {
+		return database.Retention{}
+	}
+
+	return ar.Retention
+}
+
 func (l ProcessLog) StoreLog(log *Log) error {
-	if err := database.NewLogStorage(*database.New(l.Config)).Store(database.LogRecord{
-		ID:         log.Identifier,
-		Level:      log.Level,
-		LoggedTime: time.Now(),
-		Line:       log.Line,
-		File:       log.File,
-		Logged:     time.Now().Format(l.Config.DateFormat),
-		Stack:      fmt.Sprintf("%x", log.Stack),
-		LogFmt:     log.LogFmt,
-		Entry:      log.Log,
-		AgentID:    log.Agent.UUID,
-	}); err != nil {
+	if !log.Verified {
+		return bugLog.Errorf("processLog storeLog: the log's agent hasn't been checked")
+	}
+	if log.Retention == nil {
+		log.Retention = l.Retention(log.Agent.Account.ID)
+	}
+
+	now := time.Now()
+	logged := now
+	if !log.Logged.IsZero() {
+		logged = log.Logged
+	}
+	lr := database.LogRecord{
+		ID:          log.Identifier,
+		Environment: log.Environment,
+		Level:       log.Level,
+		LoggedTime:  logged,
+		Expires:     log.Retention.Expires(log.Level, now),
+		Line:        log.Line,
+		File:        log.File,
+		Logged:      logged.Format(l.Config.DateFormat),
+		Stack:       string(log.Stack),
+		Frames:      ParseStack(string(log.Stack)),
+		LogFmt:      log.LogFmt,
+		Fields:      ParseFields(log.LogFmt, log.Log),
+		Entry:       log.Log,
+		AgentID:     log.Agent.UUID,
+		Received:    now,
+	}
+	if err := database.NewLogStorage(*database.New(l.Config)).Store(lr); err != nil {
+		// already stored, and promoted, the first time it was sent
+		if errors.Is(err, database.ErrLogExists) {
+			return nil
+		}
 		return bugLog.Errorf("processLog storeLog: %+v", err)
 	}
 
+	// the log is stored, failing it now would only get it sent, and stored, again
+	if err := l.Promote(*log, lr); err != nil {
+		_ = bugLog.Errorf("processLog storeLog promote: %+v", err)
+	}
+
 	return nil
 }
@@ -0,0 +1,78 @@
+package bug
+
+import (
+	"strings"
+
+	"github.com/bugfixes/celeste/internal/database"
+	bugLog "github.com/bugfixes/go-bugfixes/logs"
+)
+
+// PromoteNone turns off promotion by level, only the pattern promotes
+const PromoteNone = "none"
+
+// ShouldPromote is whether the log is also a bug under the promotion config,
+// an unknown level is never promoted on level alone
+func (l ProcessLog) ShouldPromote(lr database.LogRecord) (bool, error) {
+	if lvl := l.Config.Promotion.Level; lvl != "" && lvl != PromoteNone {
+		logLevel := ConvertLevelFromString(lr.Level)
+		if logLevel != GetLevelUnknown() && logLevel >= ConvertLevelFromString(lvl) {
+			return true, nil
+		}
+	}
+
+	if l.Config.Promotion.Pattern == "" {
+		return false, nil
+	}
+	if l.Config.Promotion.Regexp == nil {
+		return false, bugLog.Errorf("processLog shouldPromote: pattern not compiled")
+	}
+
+	return l.Config.Promotion.Regexp.MatchString(lr.Entry), nil
+}
+
+// PromotedBug is the bug a log becomes, the stack is the raw bug when the log has one
+func PromotedBug(log Log, lr database.LogRecord) Bug {
+	b := Bug{
+		Agent:       log.Agent,
+		Environment: lr.Environment,
+		Bug:         lr.Entry,
+		Raw:         lr.Entry,
+		Level:       lr.Level,
+		File:        lr.File,
+		Line:        lr.Line,
+		LogID:       lr.ID,
+	}
+	if stack := strings.TrimSpace(string(log.Stack)); stack != "" {
+		b.Raw = stack
+	}
+	if b.Agent.Key == "" {
+		b.Agent.Key = log.Agent.UUID
+	}
+
+	return b
+}
+
+// Promote queues the stored log as a bug when the promotion config says so,
+// only logs whose agent's credentials were checked are promoted
+func (l ProcessLog) Promote(log Log, lr database.LogRecord) error {
+	if !log.Verified || log.Agent.Key == "" {
+		return nil
+	}
+
+	promote, err := l.ShouldPromote(lr)
+	if err != nil {
+		return bugLog.Errorf("processLog promote: %+v", err)
+	}
+	if !promote {
+		return nil
+	}
+
+	if err := (ProcessBug{
+		Config: l.Config,
+		Queue:  l.Queue,
+	}).Enqueue(PromotedBug(log, lr)); err != nil {
+		return bugLog.Errorf("processLog promote enqueue: %+v", err)
+	}
+
+	return nil
+}
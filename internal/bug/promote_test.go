@@ -0,0 +1,170 @@
+package bug_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bugfixes/celeste/internal/agent"
+	bug "github.com/bugfixes/celeste/internal/bug"
+	"github.com/bugfixes/celeste/internal/config"
+	"github.com/bugfixes/celeste/internal/database"
+	"github.com/bugfixes/celeste/internal/queue"
+	"github.com/bugfixes/celeste/internal/queue/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestProcessLog_ShouldPromote(t *testing.T) {
+	tests := []struct {
+		name      string
+		promotion config.Promotion
+		request   database.LogRecord
+		expect    bool
+		err       bool
+	}{
+		{
+			name:      "at the level",
+			promotion: config.Promotion{Level: "error"},
+			request:   database.LogRecord{Level: "error"},
+			expect:    true,
+		},
+		{
+			name:      "above the level",
+			promotion: config.Promotion{Level: "error"},
+			request:   database.LogRecord{Level: "crash"},
+			expect:    true,
+		},
+		{
+			name:      "below the level",
+			promotion: config.Promotion{Level: "error"},
+			request:   database.LogRecord{Level: "info"},
+			expect:    false,
+		},
+		{
+			name:      "unknown level",
+			promotion: config.Promotion{Level: "error"},
+			request:   database.LogRecord{Level: "bob"},
+			expect:    false,
+		},
+		{
+			name:      "pattern",
+			promotion: config.Promotion{Level: bug.PromoteNone, Pattern: `(?i)timeout`},
+			request:   database.LogRecord{Level: "error", Entry: "upstream Timeout after 30s"},
+			expect:    true,
+		},
+		{
+			name:      "pattern not matched",
+			promotion: config.Promotion{Level: bug.PromoteNone, Pattern: `(?i)timeout`},
+			request:   database.LogRecord{Level: "error", Entry: "disk full"},
+			expect:    false,
+		},
+		{
+			name:      "invalid pattern",
+			promotion: config.Promotion{Level: bug.PromoteNone, Pattern: `(timeout`},
+			request:   database.LogRecord{Level: "error", Entry: "timeout"},
+			expect:    false,
+			err:       true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp, err := bug.NewLog(config.Config{Promotion: test.promotion}).ShouldPromote(test.request)
+			if passed := assert.Equal(t, test.err, err != nil); !passed {
+				t.Errorf("shouldPromote err: %+v", err)
+			}
+			if passed := assert.Equal(t, test.expect, resp); !passed {
+				t.Errorf("shouldPromote expect: %v, got: %v", test.expect, resp)
+			}
+		})
+	}
+}
+
+func TestProcessLog_StoreLogPromotes(t *testing.T) {
+	t.Cleanup(resetMemory)
+
+	q := &mocks.Queue{}
+	q.On("Send", mock.MatchedBy(func(m queue.Message) bool {
+		job := bug.BugJob{}
+		if err := json.Unmarshal(m.Body, &job); err != nil {
+			return false
+		}
+		return job.Bug.LogID == "promoted-log" &&
+			job.Bug.Agent.Key == "promote-key" &&
+			job.Bug.Raw == "main.go:12 panic"
+	})).Return(nil).Once()
+
+	l := bug.NewLog(config.Config{
+		Storage: config.Storage{
+			StorageBackend: config.StorageMemory,
+		},
+		Promotion: config.Promotion{
+			Level: "error",
+		},
+	})
+	l.Queue = q
+
+	for _, log := range []bug.Log{
+		{Identifier: "promoted-log", Level: "error", Log: "it broke", Stack: []byte("main.go:12 panic")},
+		{Identifier: "debug-log", Level: "debug", Log: "all fine"},
+	} {
+		log.Verified = true
+		log.Agent = agent.Agent{
+			UUID: "promote-key",
+			Credentials: agent.Credentials{
+				Key:    "promote-key",
+				Secret: "promote-secret",
+			},
+		}
+		if err := l.StoreLog(&log); err != nil {
+			t.Errorf("storeLog err: %+v", err)
+		}
+	}
+
+	q.AssertExpectations(t)
+}
+
+func TestProcessLog_LogHandlerNeedsAgent(t *testing.T) {
+	t.Cleanup(resetMemory)
+
+	cfg := config.Config{
+		Storage: config.Storage{
+			StorageBackend: config.StorageMemory,
+		},
+		Promotion: config.Promotion{
+			Level: "error",
+		},
+	}
+	if err := agent.NewAgent(cfg).Store(agent.Agent{
+		Credentials: agent.Credentials{
+			Key:    "promote-key",
+			Secret: "promote-secret",
+		},
+	}); err != nil {
+		t.Fatalf("store agent err: %+v", err)
+	}
+
+	q := &mocks.Queue{}
+	l := bug.NewLog(cfg)
+	l.Queue = q
+
+	// credentials in the body aren't checked, so the log is neither stored nor promoted
+	r := httptest.NewRequest(http.MethodPost, "/log", strings.NewReader(`{"key": "promote-key", "secret": "promote-secret", "level": "error", "log": "it broke"}`))
+	w := httptest.NewRecorder()
+	l.LogHandler(w, r)
+	if passed := assert.Equal(t, http.StatusForbidden, w.Code); !passed {
+		t.Errorf("log status: %v, body: %s", w.Code, w.Body.String())
+	}
+
+	q.AssertNotCalled(t, "Send", mock.Anything)
+	lrs, _, err := database.NewLogStorage(*database.New(cfg)).Query(database.LogFilter{AgentID: "promote-key"})
+	if passed := assert.Nil(t, err); !passed {
+		t.Fatalf("query err: %+v", err)
+	}
+	if passed := assert.Empty(t, lrs); !passed {
+		t.Errorf("logs stored expect none, got: %v", lrs)
+	}
+}
@@ -0,0 +1,51 @@
+package bug
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/bugfixes/celeste/internal/logic"
+	"github.com/gorilla/mux"
+)
+
+// LogicBug is the stored bug as the rules see it, the record's agent is its key so the agent's identifier is given
+func (r BugRecord) LogicBug(accountID, agentID string) logic.LogicBug {
+	return logic.LogicBug{
+		LastReported:  r.LastReportedTime,
+		FirstReported: r.FirstReportedTime,
+		TimesReported: r.TimesReportedNumber,
+		Occurrences:   r.Occurrences,
+		Minutes:       r.Minutes,
+		Hours:         r.Hours,
+		AccountID:     accountID,
+		AgentID:       agentID,
+		Level:         r.Level,
+		File:          r.File,
+		Environment:   r.Environment,
+		Muted:         r.Muted(),
+		Regressed:     r.Regressed,
+	}
+}
+
+// RulesHandler is a dry run of the alert rules over a stored bug, it explains which rule would decide and why
+func (p ProcessBug) RulesHandler(w http.ResponseWriter, r *http.Request) {
+	a, err := RequestAgent(p.Config, r)
+	if err != nil {
+		errorReportStatus(w, http.StatusForbidden, "rulesHandler invalid agent", err)
+		return
+	}
+
+	bri, err := NewBugStorage(p.Config).Get(a.UUID, mux.Vars(r)["bugId"])
+	if err != nil {
+		if errors.Is(err, ErrBugNotFound) {
+			errorReportStatus(w, http.StatusNotFound, "rulesHandler unknown bug", err)
+			return
+		}
+		errorReportStatus(w, http.StatusInternalServerError, "rulesHandler get", err)
+		return
+	}
+
+	l := logic.NewLogic(p.Config)
+	jsonResponse(w, "celeste_bug_rules", l.Decide(l.Rules(a.Account.ID, a.Identifier), bri.LogicBug(a.Account.ID, a.Identifier), time.Now()))
+}
@@ -0,0 +1,74 @@
+package bug_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bugfixes/celeste/internal/account"
+	"github.com/bugfixes/celeste/internal/agent"
+	bug "github.com/bugfixes/celeste/internal/bug"
+	"github.com/bugfixes/celeste/internal/config"
+	"github.com/bugfixes/celeste/internal/database"
+	"github.com/bugfixes/celeste/internal/logic"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcessBug_RulesHandlerAgent(t *testing.T) {
+	t.Cleanup(resetMemory)
+
+	c := config.Config{
+		Storage: config.Storage{
+			StorageBackend: config.StorageMemory,
+		},
+	}
+	if err := agent.NewAgent(c).Store(agent.Agent{
+		UUID: "rules-agent",
+		Credentials: agent.Credentials{
+			Key:    "rules-key",
+			Secret: "rules-secret",
+		},
+		Account: account.Account{
+			ID: "rules-account",
+		},
+	}); err != nil {
+		t.Fatalf("store agent err: %+v", err)
+	}
+	if err := database.NewAccountStorage(*database.New(c)).Insert(database.AccountRecord{
+		ID: "rules-account",
+		Rules: database.Rules{
+			{ID: "by-key", AgentID: "rules-key", Action: database.RuleIgnore},
+			{ID: "by-agent", AgentID: "rules-agent", Action: database.RuleIgnore},
+		},
+	}); err != nil {
+		t.Fatalf("insert account err: %+v", err)
+	}
+
+	bri, err := bug.NewBugStorage(c).FindAndStore(bug.BugRecord{
+		AgentID:     "rules-key",
+		Fingerprint: "rules",
+		Level:       "error",
+	})
+	if err != nil {
+		t.Fatalf("findAndStore err: %+v", err)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/bug/"+bri.ID+"/rules", nil)
+	r = mux.SetURLVars(r, map[string]string{"bugId": bri.ID})
+	r.Header.Set("X-API-KEY", "rules-key")
+	r.Header.Set("X-API-SECRET", "rules-secret")
+	w := httptest.NewRecorder()
+	bug.NewBug(c).RulesHandler(w, r)
+
+	resp := struct {
+		Data logic.Decision `json:"data"`
+	}{}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("rulesHandler decode err: %+v", err)
+	}
+	if passed := assert.Equal(t, "by-agent", resp.Data.Rule); !passed {
+		t.Errorf("rulesHandler rule expect: by-agent, got: %v", resp.Data.Rule)
+	}
+}
@@ -0,0 +1,71 @@
+package bug
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/bugfixes/celeste/internal/database"
+	bugLog "github.com/bugfixes/go-bugfixes/logs"
+)
+
+// StackTrace is the stack sent with a log, clients sending the []byte from runtime/debug.Stack()
+// send it base64 encoded, anything that isn't base64 is taken as the trace itself
+type StackTrace []byte
+
+func (s *StackTrace) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		*s = nil
+		return nil
+	}
+
+	str := ""
+	if err := json.Unmarshal(b, &str); err != nil {
+		return bugLog.Errorf("stackTrace unmarshal: %+v", err)
+	}
+	if decoded, err := base64.StdEncoding.DecodeString(str); err == nil && utf8.Valid(decoded) {
+		*s = decoded
+		return nil
+	}
+	*s = []byte(str)
+
+	return nil
+}
+
+func (s StackTrace) MarshalJSON() ([]byte, error) {
+	return json.Marshal(string(s))
+}
+
+// legacyStack reads back the hex encoded stacks logs used to be stored with,
+// anything that doesn't decode to a multi line trace is left as it is
+func legacyStack(stack string) string {
+	if stack == "" || len(stack)%2 != 0 {
+		return stack
+	}
+
+	decoded, err := hex.DecodeString(stack)
+	if err != nil || !utf8.Valid(decoded) || !strings.Contains(string(decoded), "\n") {
+		return stack
+	}
+
+	return string(decoded)
+}
+
+// ReadableLog is the stored log as the read API shows it, the stack as text with its frames,
+// and the fields, logs stored before either were kept have them worked out here
+func ReadableLog(lr database.LogRecord) database.LogRecord {
+	lr.Stack = legacyStack(lr.Stack)
+	if len(lr.Frames) == 0 && lr.Stack != "" {
+		lr.Frames = ParseStack(lr.Stack)
+	}
+	if lr.Frames == nil {
+		lr.Frames = []database.StackFrame{}
+	}
+	if lr.Fields == nil {
+		lr.Fields = ParseFields(lr.LogFmt, lr.Entry)
+	}
+
+	return lr
+}
@@ -0,0 +1,98 @@
+package bug_test
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	bug "github.com/bugfixes/celeste/internal/bug"
+	"github.com/bugfixes/celeste/internal/database"
+	"github.com/stretchr/testify/assert"
+)
+
+const debugStack = `goroutine 1 [running]:
+runtime/debug.Stack()
+	/usr/lib/go/src/runtime/debug/stack.go:24 +0x5e
+main.handle(0xc000010000)
+	/app/main.go:10 +0x1d
+`
+
+func TestStackTrace_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		request string
+		expect  string
+	}{
+		{
+			name:    "base64 from a []byte",
+			request: base64.StdEncoding.EncodeToString([]byte(debugStack)),
+			expect:  debugStack,
+		},
+		{
+			name:    "plain text",
+			request: debugStack,
+			expect:  debugStack,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			body, err := json.Marshal(map[string]string{"stack": test.request})
+			if err != nil {
+				t.Fatalf("marshal err: %+v", err)
+			}
+
+			log := bug.Log{}
+			if err := json.Unmarshal(body, &log); err != nil {
+				t.Errorf("unmarshal err: %+v", err)
+			}
+			if passed := assert.Equal(t, test.expect, string(log.Stack)); !passed {
+				t.Errorf("stack expect: %v, got: %v", test.expect, string(log.Stack))
+			}
+		})
+	}
+}
+
+func TestReadableLog(t *testing.T) {
+	expectFrames := []database.StackFrame{
+		{Function: "runtime/debug.Stack", File: "/usr/lib/go/src/runtime/debug/stack.go", Line: 24, InApp: false},
+		{Function: "main.handle", File: "/app/main.go", Line: 10, InApp: true},
+	}
+
+	tests := []struct {
+		name    string
+		request database.LogRecord
+		expect  database.LogRecord
+	}{
+		{
+			name:    "legacy hex",
+			request: database.LogRecord{Stack: hex.EncodeToString([]byte(debugStack))},
+			expect:  database.LogRecord{Stack: debugStack, Frames: expectFrames, Fields: map[string]string{}},
+		},
+		{
+			name:    "plain text",
+			request: database.LogRecord{Stack: debugStack},
+			expect:  database.LogRecord{Stack: debugStack, Frames: expectFrames, Fields: map[string]string{}},
+		},
+		{
+			name:    "hex that isn't a stack",
+			request: database.LogRecord{Stack: "deadbeef"},
+			expect:  database.LogRecord{Stack: "deadbeef", Frames: []database.StackFrame{}, Fields: map[string]string{}},
+		},
+		{
+			name:    "no stack",
+			request: database.LogRecord{},
+			expect:  database.LogRecord{Frames: []database.StackFrame{}, Fields: map[string]string{}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp := bug.ReadableLog(test.request)
+			if passed := assert.Equal(t, test.expect, resp); !passed {
+				t.Errorf("readableLog expect: %+v, got: %+v", test.expect, resp)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package bug
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	StatusOpen      = "open"
+	StatusResolved  = "resolved"
+	StatusIgnored   = "ignored"
+	StatusSnoozed   = "snoozed"
+	StatusRegressed = "regressed"
+)
+
+// ErrInvalidStatus is returned as is when a status change can't be made
+var ErrInvalidStatus = errors.New("invalid status change")
+
+// StatusChange is what someone asks for, snoozes need either a date or a number of occurrences
+type StatusChange struct {
+	Status            string    `json:"status"`
+	SnoozeUntil       time.Time `json:"snooze_until"`
+	SnoozeOccurrences int       `json:"snooze_occurrences"`
+}
+
+// CurrentStatus treats records from before lifecycle states as open
+func (r BugRecord) CurrentStatus() string {
+	if r.Status == "" {
+		return StatusOpen
+	}
+	return r.Status
+}
+
+// NotifyState changes when the bug's status does, or it is seen in another release,
+// a change lets the bug be sent again while it is cooling down
+func (r BugRecord) NotifyState() string {
+	return fmt.Sprintf("%s@%s:%s", r.CurrentStatus(), r.StatusChanged.UTC().Format(time.RFC3339), r.LastRelease)
+}
+
+// Muted is whether reports of the bug should be counted and nothing else
+func (r BugRecord) Muted() bool {
+	switch r.CurrentStatus() {
+	case StatusIgnored, StatusSnoozed:
+		return true
+	}
+	return false
+}
+
+// ChangeStatus moves the bug to the status asked for, regressed can only be reached by the bug coming back
+func (r *BugRecord) ChangeStatus(change StatusChange, now time.Time) error {
+	switch change.Status {
+	case StatusOpen, StatusResolved, StatusIgnored:
+		r.SnoozeUntil = time.Time{}
+		r.SnoozeUntilTimes = 0
+	case StatusSnoozed:
+		if change.SnoozeUntil.IsZero() && change.SnoozeOccurrences <= 0 {
+			return ErrInvalidStatus
+		}
+		if !change.SnoozeUntil.IsZero() && !change.SnoozeUntil.After(now) {
+			return ErrInvalidStatus
+		}
+		r.SnoozeUntil = change.SnoozeUntil
+		r.SnoozeUntilTimes = 0
+		if change.SnoozeOccurrences > 0 {
+			r.SnoozeUntilTimes = r.TimesReportedNumber + change.SnoozeOccurrences
+		}
+	default:
+		return ErrInvalidStatus
+	}
+
+	r.Status = change.Status
+	r.StatusChanged = now
+
+	return nil
+}
+
+// Occurred moves the bug on after another report of it has been counted,
+// a resolved bug has regressed, and a snooze that has run out wakes the bug back up
+func (r *BugRecord) Occurred(now time.Time) bool {
+	switch r.CurrentStatus() {
+	case StatusResolved:
+		r.Status = StatusRegressed
+		r.StatusChanged = now
+		r.Regressed = true
+		return true
+	case StatusSnoozed:
+		dateUp := !r.SnoozeUntil.IsZero() && !now.Before(r.SnoozeUntil)
+		timesUp := r.SnoozeUntilTimes > 0 && r.TimesReportedNumber >= r.SnoozeUntilTimes
+		if dateUp || timesUp {
+			r.Status = StatusOpen
+			r.StatusChanged = now
+			r.SnoozeUntil = time.Time{}
+			r.SnoozeUntilTimes = 0
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,130 @@
+package bug_test
+
+import (
+	"testing"
+	"time"
+
+	bug "github.com/bugfixes/celeste/internal/bug"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBugRecord_ChangeStatus(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name    string
+		request bug.StatusChange
+		expect  bug.BugRecord
+		err     error
+	}{
+		{
+			name:    "resolve",
+			request: bug.StatusChange{Status: bug.StatusResolved},
+			expect:  bug.BugRecord{TimesReportedNumber: 3, Status: bug.StatusResolved, StatusChanged: now},
+		},
+		{
+			name:    "snooze until date",
+			request: bug.StatusChange{Status: bug.StatusSnoozed, SnoozeUntil: now.Add(time.Hour)},
+			expect:  bug.BugRecord{TimesReportedNumber: 3, Status: bug.StatusSnoozed, StatusChanged: now, SnoozeUntil: now.Add(time.Hour)},
+		},
+		{
+			name:    "snooze until occurrences",
+			request: bug.StatusChange{Status: bug.StatusSnoozed, SnoozeOccurrences: 10},
+			expect:  bug.BugRecord{TimesReportedNumber: 3, Status: bug.StatusSnoozed, StatusChanged: now, SnoozeUntilTimes: 13},
+		},
+		{
+			name:    "snooze without end",
+			request: bug.StatusChange{Status: bug.StatusSnoozed},
+			expect:  bug.BugRecord{TimesReportedNumber: 3},
+			err:     bug.ErrInvalidStatus,
+		},
+		{
+			name:    "snooze into the past",
+			request: bug.StatusChange{Status: bug.StatusSnoozed, SnoozeUntil: now.Add(-time.Hour)},
+			expect:  bug.BugRecord{TimesReportedNumber: 3},
+			err:     bug.ErrInvalidStatus,
+		},
+		{
+			name:    "regressed by hand",
+			request: bug.StatusChange{Status: bug.StatusRegressed},
+			expect:  bug.BugRecord{TimesReportedNumber: 3},
+			err:     bug.ErrInvalidStatus,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp := bug.BugRecord{TimesReportedNumber: 3}
+			err := resp.ChangeStatus(test.request, now)
+			if passed := assert.Equal(t, test.err, err); !passed {
+				t.Errorf("changeStatus err: %+v", err)
+			}
+			if passed := assert.Equal(t, test.expect, resp); !passed {
+				t.Errorf("changeStatus expect: %v, got: %v", test.expect, resp)
+			}
+		})
+	}
+}
+
+func TestBugRecord_Occurred(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name    string
+		request bug.BugRecord
+		expect  string
+		changed bool
+	}{
+		{
+			name:    "open stays open",
+			request: bug.BugRecord{Status: bug.StatusOpen},
+			expect:  bug.StatusOpen,
+		},
+		{
+			name:    "resolved regresses",
+			request: bug.BugRecord{Status: bug.StatusResolved},
+			expect:  bug.StatusRegressed,
+			changed: true,
+		},
+		{
+			name:    "ignored stays ignored",
+			request: bug.BugRecord{Status: bug.StatusIgnored},
+			expect:  bug.StatusIgnored,
+		},
+		{
+			name:    "snoozed until later",
+			request: bug.BugRecord{Status: bug.StatusSnoozed, SnoozeUntil: now.Add(time.Hour)},
+			expect:  bug.StatusSnoozed,
+		},
+		{
+			name:    "snooze date passed",
+			request: bug.BugRecord{Status: bug.StatusSnoozed, SnoozeUntil: now.Add(-time.Minute)},
+			expect:  bug.StatusOpen,
+			changed: true,
+		},
+		{
+			name:    "snoozed for more occurrences",
+			request: bug.BugRecord{Status: bug.StatusSnoozed, TimesReportedNumber: 12, SnoozeUntilTimes: 13},
+			expect:  bug.StatusSnoozed,
+		},
+		{
+			name:    "snooze occurrences reached",
+			request: bug.BugRecord{Status: bug.StatusSnoozed, TimesReportedNumber: 13, SnoozeUntilTimes: 13},
+			expect:  bug.StatusOpen,
+			changed: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp := test.request
+			changed := resp.Occurred(now)
+			if passed := assert.Equal(t, test.changed, changed); !passed {
+				t.Errorf("occurred changed expect: %v, got: %v", test.changed, changed)
+			}
+			if passed := assert.Equal(t, test.expect, resp.Status); !passed {
+				t.Errorf("occurred expect: %v, got: %v", test.expect, resp.Status)
+			}
+		})
+	}
+}
@@ -1,8 +1,13 @@
 package bug
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -14,31 +19,350 @@ import (
 	bugLog "github.com/bugfixes/go-bugfixes/logs"
 )
 
-type BugStorage struct {
+//go:generate mockery --name=BugStorage
+type BugStorage interface {
+	Insert(data BugRecord) error
+	FindAndStore(data BugRecord) (BugRecord, error)
+	Find(data BugRecord) ([]BugRecord, error)
+	Store(data BugRecord) error
+	Increment(data BugRecord) (BugRecord, error)
+	Migrate() (int, error)
+	List(filter BugFilter) ([]BugRecord, string, error)
+	Get(agentID, id string) (BugRecord, error)
+	LinkTicket(data BugRecord) error
+	UpdateStatus(data BugRecord) error
+	MarkSpike(id string, now time.Time, cooldown time.Duration) (bool, error)
+	MarkRelease(id, release string) (bool, error)
+}
+
+// OccurrenceDayFormat is the key for each day in BugRecord.Occurrences
+const OccurrenceDayFormat = "2006-01-02"
+
+// RateMinuteFormat and RateHourFormat are the keys of BugRecord.Minutes and BugRecord.Hours, in UTC like the days
+const (
+	RateMinuteFormat = "2006-01-02T15:04"
+	RateHourFormat   = "2006-01-02T15"
+)
+
+// rateCutoffs are the oldest minute and hour kept, the minutes cover the last hour, the hours the spike baseline before it,
+// and at least a day so a rule window under a day can be counted from them
+func rateCutoffs(c config.Config, now time.Time) (string, string) {
+	hours := c.Spike.BaselineHours + 2
+	if hours < 25 {
+		hours = 25
+	}
+	minute := now.UTC().Add(-2 * time.Hour).Format(RateMinuteFormat)
+	hour := now.UTC().Add(-time.Duration(hours) * time.Hour).Format(RateHourFormat)
+
+	return minute, hour
+}
+
+// countRate copies the buckets without the ones older than cutoff, and counts one more in key
+func countRate(buckets map[string]int, key, cutoff string) map[string]int {
+	counted := map[string]int{}
+	for bucket, count := range buckets {
+		if bucket >= cutoff {
+			counted[bucket] = count
+		}
+	}
+	counted[key]++
+
+	return counted
+}
+
+// staleRates are the buckets older than cutoff
+func staleRates(buckets map[string]int, cutoff string) []string {
+	stale := []string{}
+	for bucket := range buckets {
+		if bucket < cutoff {
+			stale = append(stale, bucket)
+		}
+	}
+	sort.Strings(stale)
+
+	return stale
+}
+
+type DynamoBugStorage struct {
 	Config config.Config
 }
 
 type BugRecord struct {
-	ID                  string      `json:"id"`
-	AgentID             string      `json:"agent_id"`
-	Level               string      `json:"level"`
-	FileLineHash        string      `json:"file_line_hash"`
-	Hash                string      `json:"hash"`
-	Full                interface{} `json:"full"`
-	TimesReported       string      `json:"times_reported"`
-	TimesReportedNumber int         `json:"times_reported_number" dynamodbav:"-"`
-	LastReportedTime    time.Time   `json:"last_reported_time" dynamodbav:"-"`
-	LastReported        string      `json:"last_reported"`
-	FirstReportedTime   time.Time   `json:"first_reported_time" dynamodbav:"-"`
-	FirstReported       string      `json:"first_reported"`
-}
-
-func NewBugStorage(c config.Config) *BugStorage {
-	return &BugStorage{
+	ID                  string         `json:"id"`
+	AgentID             string         `json:"agent_id"`
+	Environment         string         `json:"environment"`
+	Level               string         `json:"level"`
+	File                string         `json:"file"`
+	Line                string         `json:"line"`
+	FileLineHash        string         `json:"file_line_hash"`
+	Hash                string         `json:"hash"`
+	Fingerprint         string         `json:"fingerprint"`
+	Full                interface{}    `json:"full"`
+	TimesReported       string         `json:"times_reported"`
+	TimesReportedNumber int            `json:"times_reported_number"`
+	LastReportedTime    time.Time      `json:"last_reported_time" dynamodbav:"-"`
+	LastReported        string         `json:"last_reported"`
+	FirstReportedTime   time.Time      `json:"first_reported_time" dynamodbav:"-"`
+	FirstReported       string         `json:"first_reported"`
+	Occurrences         map[string]int `json:"occurrences"`
+	Minutes             map[string]int `json:"minutes"`
+	Hours               map[string]int `json:"hours"`
+	LastSpike           time.Time      `json:"last_spike"`
+	RemoteLink          string         `json:"remote_link"`
+	TicketSystem        string         `json:"ticket_system"`
+	Status              string         `json:"status"`
+	StatusChanged       time.Time      `json:"status_changed"`
+	SnoozeUntil         time.Time      `json:"snooze_until"`
+	SnoozeUntilTimes    int            `json:"snooze_until_times"`
+	FirstRelease        string         `json:"first_release"`
+	LastRelease         string         `json:"last_release"`
+	LastCommit          string         `json:"last_commit"`
+	// Assignee is the email of who took the bug on, kept with its status
+	Assignee string `json:"assignee,omitempty" dynamodbav:"assignee,omitempty"`
+
+	// Regressed is only set on the report that brought a resolved bug back
+	Regressed bool `json:"-" dynamodbav:"-"`
+	// NewRelease is only set on the report that first saw the bug in its release
+	NewRelease bool `json:"-" dynamodbav:"-"`
+}
+
+func NewBugStorage(c config.Config) BugStorage {
+	switch c.StorageBackend {
+	case config.StoragePostgres:
+		return NewPostgresBugStorage(c)
+	case config.StorageMemory:
+		return NewMemoryBugStorage(c)
+	default:
+		return NewDynamoBugStorage(c)
+	}
+}
+
+func NewDynamoBugStorage(c config.Config) *DynamoBugStorage {
+	return &DynamoBugStorage{
 		Config: c,
 	}
 }
 
+// findAndStore either stores a new record, or counts another occurrence of the one that is already there,
+// a first report that loses the race to store the bug is counted on the one that won,
+// the status is left as it was, the bug's MoveStatus moves it on
+func findAndStore(s BugStorage, c config.Config, data BugRecord) (BugRecord, error) {
+	bugRecords, err := s.Find(data)
+	if err != nil {
+		return BugRecord{}, bugLog.Errorf("bugstorage findAndStore find: %+v", err)
+	}
+
+	if len(bugRecords) == 0 {
+		first := firstReport(c, data)
+		err := s.Store(first)
+		if err == nil {
+			if first.LastRelease != "" {
+				if _, err := s.MarkRelease(first.ID, first.LastRelease); err != nil {
+					bugLog.Debugf("bugstorage findAndStore markRelease: %+v", err)
+				}
+			}
+			return first, nil
+		}
+		if !errors.Is(err, ErrBugExists) {
+			return BugRecord{}, bugLog.Errorf("bugstorage findAndStore store: %+v", err)
+		}
+
+		if bugRecords, err = s.Find(data); err != nil {
+			return BugRecord{}, bugLog.Errorf("bugstorage findAndStore refind: %+v", err)
+		}
+		if len(bugRecords) == 0 {
+			// an index that hasn't caught up yet, the record is under the id it was stored with
+			data.ID = first.ID
+			bugRecords = []BugRecord{data}
+		}
+	}
+
+	// a release is only new the first time the bug is seen in it, releases from before they were kept
+	// are known from the first and last release
+	found := bugRecords[0]
+	newRelease := false
+	if data.LastRelease != "" {
+		marked, err := s.MarkRelease(found.ID, data.LastRelease)
+		if err != nil {
+			bugLog.Debugf("bugstorage findAndStore markRelease: %+v", err)
+		}
+		newRelease = marked && data.LastRelease != found.LastRelease && data.LastRelease != found.FirstRelease
+	}
+	if data.LastRelease != "" {
+		found.LastRelease = data.LastRelease
+	}
+	if data.LastCommit != "" {
+		found.LastCommit = data.LastCommit
+	}
+
+	bri, err := s.Increment(found)
+	if err != nil {
+		return BugRecord{}, bugLog.Errorf("bugstorage findAndStore increment: %+v", err)
+	}
+	bri.NewRelease = newRelease
+
+	return bri, nil
+}
+
+// firstReport is the record for the first time the bug is seen, under the id every first report of it shares
+func firstReport(c config.Config, data BugRecord) BugRecord {
+	now := time.Now()
+	data.ID = BugRecordID(data.AgentID, data.Environment, data.Fingerprint)
+	data.TimesReportedNumber = 1
+	data.TimesReported = "1"
+	data.LastReportedTime = now
+	data.LastReported = now.Format(c.DateFormat)
+	data.FirstReportedTime = now
+	data.FirstReported = now.Format(c.DateFormat)
+	data.Occurrences = map[string]int{
+		now.UTC().Format(OccurrenceDayFormat): 1,
+	}
+	data.Minutes = map[string]int{
+		now.UTC().Format(RateMinuteFormat): 1,
+	}
+	data.Hours = map[string]int{
+		now.UTC().Format(RateHourFormat): 1,
+	}
+	data.Status = StatusOpen
+	data.StatusChanged = now
+	data.FirstRelease = data.LastRelease
+	data.NewRelease = data.LastRelease != ""
+
+	return data
+}
+
+// FullDetails is what was reported the first time the bug was seen
+type FullDetails struct {
+	Pretty  string
+	Raw     string
+	Tags    map[string]string `json:"tags,omitempty"`
+	Release string            `json:"release,omitempty"`
+	Commit  string            `json:"commit,omitempty"`
+	LogID   string            `json:"log_id,omitempty"`
+}
+
+// pageRecords filters, orders and cuts out the page the cursor points at,
+// the cursor returned is empty once there are no more pages
+func pageRecords(brs []BugRecord, filter BugFilter) ([]BugRecord, string, error) {
+	offset, err := decodeOffsetCursor(filter.Cursor)
+	if err != nil {
+		return []BugRecord{}, "", bugLog.Errorf("bugstorage pageRecords cursor: %+v", err)
+	}
+
+	matched := []BugRecord{}
+	for _, bri := range brs {
+		if filter.Matches(bri) {
+			matched = append(matched, bri)
+		}
+	}
+	sortByLastReported(matched)
+
+	if offset >= len(matched) {
+		return []BugRecord{}, "", nil
+	}
+	end := offset + filter.Limit
+	if filter.Limit <= 0 || end >= len(matched) {
+		return matched[offset:], "", nil
+	}
+
+	return matched[offset:end], encodeOffsetCursor(end), nil
+}
+
+// rawFromFull pulls the raw bug back out of the full details, however they were decoded
+func rawFromFull(full interface{}) string {
+	switch f := full.(type) {
+	case map[string]interface{}:
+		if raw, ok := f["Raw"].(string); ok {
+			return raw
+		}
+	case FullDetails:
+		return f.Raw
+	}
+
+	return ""
+}
+
+// duplicateRecords are the records that share an agent, environment and fingerprint, a new fingerprint can make
+// two records from before it the same bug, each group starts with the one first reported, the rest are merged into it
+func duplicateRecords(brs []BugRecord) [][]BugRecord {
+	groups := map[string][]BugRecord{}
+	keys := []string{}
+	for _, bri := range brs {
+		key := bri.AgentID + "\n" + bri.Environment + "\n" + bri.Fingerprint
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], bri)
+	}
+	sort.Strings(keys)
+
+	dups := [][]BugRecord{}
+	for _, key := range keys {
+		group := groups[key]
+		if len(group) < 2 {
+			continue
+		}
+		sort.SliceStable(group, func(i, j int) bool {
+			if !group[i].FirstReportedTime.Equal(group[j].FirstReportedTime) {
+				return group[i].FirstReportedTime.Before(group[j].FirstReportedTime)
+			}
+			return group[i].ID < group[j].ID
+		})
+		dups = append(dups, group)
+	}
+
+	return dups
+}
+
+// mergeRecord folds dup into keep, the counts and histograms add up, the reports span both,
+// the latest release and commit are the ones last reported, and keep takes dup's ticket when it has none
+func mergeRecord(c config.Config, keep, dup BugRecord) BugRecord {
+	keep.TimesReportedNumber += dup.TimesReportedNumber
+	keep.TimesReported = strconv.Itoa(keep.TimesReportedNumber)
+	if !dup.FirstReportedTime.IsZero() && (keep.FirstReportedTime.IsZero() || dup.FirstReportedTime.Before(keep.FirstReportedTime)) {
+		keep.FirstReportedTime = dup.FirstReportedTime
+		keep.FirstReported = dup.FirstReportedTime.Format(c.DateFormat)
+		if dup.FirstRelease != "" {
+			keep.FirstRelease = dup.FirstRelease
+		}
+	}
+	if dup.LastReportedTime.After(keep.LastReportedTime) {
+		keep.LastReportedTime = dup.LastReportedTime
+		keep.LastReported = dup.LastReportedTime.Format(c.DateFormat)
+		if dup.LastRelease != "" {
+			keep.LastRelease = dup.LastRelease
+		}
+		if dup.LastCommit != "" {
+			keep.LastCommit = dup.LastCommit
+		}
+	}
+	if keep.FirstRelease == "" {
+		keep.FirstRelease = dup.FirstRelease
+	}
+	if keep.RemoteLink == "" {
+		keep.RemoteLink = dup.RemoteLink
+		keep.TicketSystem = dup.TicketSystem
+	}
+	keep.Occurrences = addBuckets(keep.Occurrences, dup.Occurrences)
+	keep.Minutes = addBuckets(keep.Minutes, dup.Minutes)
+	keep.Hours = addBuckets(keep.Hours, dup.Hours)
+
+	return keep
+}
+
+// addBuckets is a copy of a with b's counts added
+func addBuckets(a, b map[string]int) map[string]int {
+	added := map[string]int{}
+	for bucket, count := range a {
+		added[bucket] = count
+	}
+	for bucket, count := range b {
+		added[bucket] += count
+	}
+
+	return added
+}
+
 func dynamoError(e error) error {
 	// nolint:errorlint
 	if aerr, ok := e.(awserr.Error); ok {
@@ -65,7 +389,7 @@ func dynamoError(e error) error {
 	}
 }
 
-func (b BugStorage) dynamoSession() (*dynamodb.DynamoDB, error) {
+func (b DynamoBugStorage) dynamoSession() (*dynamodb.DynamoDB, error) {
 	sess, err := config.BuildSession(b.Config)
 	if err != nil {
 		return nil, bugLog.Errorf("dynamoSessioN: %+v", err)
@@ -74,7 +398,7 @@ func (b BugStorage) dynamoSession() (*dynamodb.DynamoDB, error) {
 	return dynamodb.New(sess), nil
 }
 
-func (b BugStorage) Insert(data BugRecord) error {
+func (b DynamoBugStorage) Insert(data BugRecord) error {
 	svc, err := b.dynamoSession()
 	if err != nil {
 		return bugLog.Errorf("insert bug dynamo session failed: %+v", err)
@@ -96,26 +420,11 @@ func (b BugStorage) Insert(data BugRecord) error {
 	return nil
 }
 
-func (b BugStorage) FindAndStore(data BugRecord) (BugRecord, error) {
-	bugRecords, err := b.Find(data)
-	if err != nil {
-		return BugRecord{}, bugLog.Errorf("bugstorage findAndStore find: %+v", err)
-	}
-
-	if len(bugRecords) == 0 {
-		data.TimesReportedNumber = 1
-		data.TimesReported = "1"
-		data.LastReportedTime = time.Now()
-		data.LastReported = time.Now().Format(b.Config.DateFormat)
-		data.FirstReportedTime = time.Now()
-		data.FirstReported = time.Now().Format(b.Config.DateFormat)
-		return data, b.Store(data)
-	}
-
-	return bugRecords[0], b.Update(bugRecords[0])
+func (b DynamoBugStorage) FindAndStore(data BugRecord) (BugRecord, error) {
+	return findAndStore(b, b.Config, data)
 }
 
-func (b BugStorage) Find(data BugRecord) ([]BugRecord, error) {
+func (b DynamoBugStorage) Find(data BugRecord) ([]BugRecord, error) {
 	brs := []BugRecord{}
 
 	svc, err := b.dynamoSession()
@@ -123,69 +432,430 @@ func (b BugStorage) Find(data BugRecord) ([]BugRecord, error) {
 		return brs, bugLog.Errorf("bug findAndStore session: %+v", err)
 	}
 
-	filt := expression.And(
-		expression.Name("hash").Equal(expression.Value(data.Hash)),
-		expression.Name("agent_id").Equal(expression.Value(data.AgentID)))
+	keyCond := expression.Key("agent_id").Equal(expression.Value(data.AgentID)).
+		And(expression.Key("fingerprint").Equal(expression.Value(data.Fingerprint)))
 	proj := expression.NamesList(
 		expression.Name("id"),
 		expression.Name("agent_id"),
+		expression.Name("environment"),
 		expression.Name("level"),
+		expression.Name("file"),
+		expression.Name("line"),
 		expression.Name("hash"),
+		expression.Name("fingerprint"),
 		expression.Name("full"),
 		expression.Name("times_reported"),
+		expression.Name("times_reported_number"),
+		expression.Name("occurrences"),
+		expression.Name("minutes"),
+		expression.Name("hours"),
 		expression.Name("last_reported"),
-		expression.Name("first_reported"))
-	expr, err := expression.NewBuilder().WithFilter(filt).WithProjection(proj).Build()
+		expression.Name("first_reported"),
+		expression.Name("remote_link"),
+		expression.Name("ticket_system"),
+		expression.Name("status"),
+		expression.Name("status_changed"),
+		expression.Name("snooze_until"),
+		expression.Name("snooze_until_times"),
+		expression.Name("first_release"),
+		expression.Name("last_release"),
+		expression.Name("last_commit"),
+		expression.Name("last_spike"),
+		expression.Name("assignee"))
+	expr, err := expression.NewBuilder().WithKeyCondition(keyCond).WithProjection(proj).Build()
 	if err != nil {
 		return brs, bugLog.Errorf("bug findAndStore build: %+v", err)
 	}
 
-	result, err := svc.Scan(&dynamodb.ScanInput{
+	input := &dynamodb.QueryInput{
 		TableName:                 aws.String(b.Config.BugsTable),
+		IndexName:                 aws.String(b.Config.BugsIndex),
 		ExpressionAttributeValues: expr.Values(),
 		ExpressionAttributeNames:  expr.Names(),
-		FilterExpression:          expr.Filter(),
+		KeyConditionExpression:    expr.KeyCondition(),
 		ProjectionExpression:      expr.Projection(),
+	}
+	for {
+		result, err := svc.Query(input)
+		if err != nil {
+			return brs, bugLog.Errorf("bug findAndStore query: %+v", err)
+		}
+
+		for _, i := range result.Items {
+			bri, err := b.unmarshalRecord(i)
+			if err != nil {
+				return brs, bugLog.Errorf("bug findAndStore unmarshalRecord: %+v", err)
+			}
+			// the environment is part of what makes a bug the same, it isn't in the index so it is checked here
+			if bri.Environment != data.Environment {
+				continue
+			}
+
+			brs = append(brs, bri)
+		}
+
+		if len(result.LastEvaluatedKey) == 0 {
+			break
+		}
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+
+	return brs, nil
+}
+
+// List reads the agent's bugs off the index a page at a time, Dynamo can't order them by last report,
+// so the pages follow the index and only each page is ordered, the cursor is the key of the last bug on the page
+// nolint: gocyclo
+func (b DynamoBugStorage) List(filter BugFilter) ([]BugRecord, string, error) {
+	brs := []BugRecord{}
+
+	startKey, err := decodeDynamoCursor(filter.Cursor)
+	if err != nil {
+		return brs, "", bugLog.Errorf("bug list cursor: %+v", err)
+	}
+	if startKey != nil && aws.StringValue(startKey["agent_id"].S) != filter.AgentID {
+		return brs, "", bugLog.Errorf("bug list cursor: not the agent's")
+	}
+
+	svc, err := b.dynamoSession()
+	if err != nil {
+		return brs, "", bugLog.Errorf("bug list session: %+v", err)
+	}
+
+	builder := expression.NewBuilder().WithKeyCondition(expression.Key("agent_id").Equal(expression.Value(filter.AgentID)))
+	conds := []expression.ConditionBuilder{}
+	if filter.Level != "" {
+		conds = append(conds, expression.Name("level").Equal(expression.Value(filter.Level)))
+	}
+	if filter.File != "" {
+		conds = append(conds, expression.Name("file").Equal(expression.Value(filter.File)))
+	}
+	if filter.Environment != "" {
+		conds = append(conds, expression.Name("environment").Equal(expression.Value(filter.Environment)))
+	}
+	if filter.Status != "" && filter.Status != StatusOpen {
+		conds = append(conds, expression.Name("status").Equal(expression.Value(filter.Status)))
+	}
+	if filter.MinTimesReported > 0 {
+		conds = append(conds, expression.Name("times_reported_number").GreaterThanEqual(expression.Value(filter.MinTimesReported)))
+	}
+	if filter.MaxTimesReported > 0 {
+		conds = append(conds, expression.Name("times_reported_number").LessThanEqual(expression.Value(filter.MaxTimesReported)))
+	}
+	switch len(conds) {
+	case 0:
+	case 1:
+		builder = builder.WithFilter(conds[0])
+	default:
+		builder = builder.WithFilter(expression.And(conds[0], conds[1], conds[2:]...))
+	}
+	expr, err := builder.Build()
+	if err != nil {
+		return brs, "", bugLog.Errorf("bug list build: %+v", err)
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	input := &dynamodb.QueryInput{
+		TableName:                 aws.String(b.Config.BugsTable),
+		IndexName:                 aws.String(b.Config.BugsIndex),
+		ExpressionAttributeValues: expr.Values(),
+		ExpressionAttributeNames:  expr.Names(),
+		KeyConditionExpression:    expr.KeyCondition(),
+		FilterExpression:          expr.Filter(),
+		ExclusiveStartKey:         startKey,
+		Limit:                     aws.Int64(int64(limit)),
+	}
+
+	// the limit is applied before the filter, so a page can take more than one query to fill
+	more := false
+	last := BugRecord{}
+	for len(brs) < limit {
+		result, err := svc.Query(input)
+		if err != nil {
+			return brs, "", bugLog.Errorf("bug list query: %+v", err)
+		}
+
+		for i, item := range result.Items {
+			bri, err := b.unmarshalRecord(item)
+			if err != nil {
+				return brs, "", bugLog.Errorf("bug list unmarshalRecord: %+v", err)
+			}
+			// the status and the dates aren't something the filter expression can compare
+			if !filter.Matches(bri) {
+				continue
+			}
+
+			brs = append(brs, bri)
+			if len(brs) == limit {
+				more = i < len(result.Items)-1 || len(result.LastEvaluatedKey) != 0
+				last = bri
+				break
+			}
+		}
+
+		if len(result.LastEvaluatedKey) == 0 {
+			break
+		}
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+	sortByLastReported(brs)
+
+	if !more {
+		return brs, "", nil
+	}
+
+	cursor, err := encodeDynamoCursor(last)
+	if err != nil {
+		return brs, "", bugLog.Errorf("bug list encode cursor: %+v", err)
+	}
+
+	return brs, cursor, nil
+}
+
+// encodeDynamoCursor is the key the next page starts after, the table's and the index's
+func encodeDynamoCursor(bri BugRecord) (string, error) {
+	key, err := json.Marshal(map[string]string{
+		"id":          bri.ID,
+		"agent_id":    bri.AgentID,
+		"fingerprint": bri.Fingerprint,
 	})
 	if err != nil {
-		return brs, bugLog.Errorf("bug findAndStore scan: %+v", err)
+		return "", bugLog.Errorf("encodeDynamoCursor marshal: %+v", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(key), nil
+}
+
+func decodeDynamoCursor(cursor string) (map[string]*dynamodb.AttributeValue, error) {
+	if cursor == "" {
+		return nil, nil
 	}
 
-	if len(result.Items) == 0 {
-		return brs, nil
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, bugLog.Errorf("decodeDynamoCursor decode: %+v", err)
+	}
+	key := map[string]string{}
+	if err := json.Unmarshal(b, &key); err != nil {
+		return nil, bugLog.Errorf("decodeDynamoCursor unmarshal: %+v", err)
+	}
+	if key["id"] == "" || key["agent_id"] == "" {
+		return nil, bugLog.Errorf("decodeDynamoCursor: incomplete key")
 	}
 
-	for _, i := range result.Items {
-		bri := BugRecord{}
-		if err := dynamodbattribute.UnmarshalMap(i, &bri); err != nil {
-			return brs, bugLog.Errorf("bug findAndStore unmarshall: %+v", err)
+	startKey := map[string]*dynamodb.AttributeValue{}
+	for name, value := range key {
+		startKey[name] = &dynamodb.AttributeValue{
+			S: aws.String(value),
 		}
+	}
 
-		trn, err := strconv.Atoi(bri.TimesReported)
-		if err != nil {
-			return brs, bugLog.Errorf("bug findAndStore atoi: %+v", err)
+	return startKey, nil
+}
+
+func (b DynamoBugStorage) Get(agentID, id string) (BugRecord, error) {
+	svc, err := b.dynamoSession()
+	if err != nil {
+		return BugRecord{}, bugLog.Errorf("bug get session: %+v", err)
+	}
+
+	result, err := svc.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String(b.Config.BugsTable),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {
+				S: aws.String(id),
+			},
+		},
+	})
+	if err != nil {
+		return BugRecord{}, dynamoError(err)
+	}
+	if len(result.Item) == 0 {
+		return BugRecord{}, ErrBugNotFound
+	}
+
+	bri, err := b.unmarshalRecord(result.Item)
+	if err != nil {
+		return bri, bugLog.Errorf("bug get unmarshalRecord: %+v", err)
+	}
+	if bri.AgentID != agentID {
+		return BugRecord{}, ErrBugNotFound
+	}
+
+	return bri, nil
+}
+
+func (b DynamoBugStorage) LinkTicket(data BugRecord) error {
+	svc, err := b.dynamoSession()
+	if err != nil {
+		return bugLog.Errorf("bug linkTicket session: %+v", err)
+	}
+
+	if _, err := svc.UpdateItem(&dynamodb.UpdateItemInput{
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":rl": {
+				S: aws.String(data.RemoteLink),
+			},
+			":ts": {
+				S: aws.String(data.TicketSystem),
+			},
+		},
+		TableName: aws.String(b.Config.BugsTable),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {
+				S: aws.String(data.ID),
+			},
+		},
+		UpdateExpression: aws.String("set remote_link = :rl, ticket_system = :ts"),
+	}); err != nil {
+		return dynamoError(err)
+	}
+
+	return nil
+}
+
+func (b DynamoBugStorage) UpdateStatus(data BugRecord) error {
+	svc, err := b.dynamoSession()
+	if err != nil {
+		return bugLog.Errorf("bug updateStatus session: %+v", err)
+	}
+
+	values, err := dynamodbattribute.MarshalMap(map[string]interface{}{
+		":s":  data.Status,
+		":sc": data.StatusChanged,
+		":su": data.SnoozeUntil,
+		":st": data.SnoozeUntilTimes,
+		":as": data.Assignee,
+	})
+	if err != nil {
+		return bugLog.Errorf("bug updateStatus marshal: %+v", err)
+	}
+
+	if _, err := svc.UpdateItem(&dynamodb.UpdateItemInput{
+		ExpressionAttributeNames: map[string]*string{
+			"#status": aws.String("status"),
+		},
+		ExpressionAttributeValues: values,
+		TableName:                 aws.String(b.Config.BugsTable),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {
+				S: aws.String(data.ID),
+			},
+		},
+		UpdateExpression: aws.String("set #status = :s, status_changed = :sc, snooze_until = :su, snooze_until_times = :st, assignee = :as"),
+	}); err != nil {
+		return dynamoError(err)
+	}
+
+	return nil
+}
+
+// MarkSpike notes the bug's spike has been sent, it is false when one was already sent within the cooldown,
+// the check and the mark are one conditional write so two workers can't both send it
+func (b DynamoBugStorage) MarkSpike(id string, now time.Time, cooldown time.Duration) (bool, error) {
+	svc, err := b.dynamoSession()
+	if err != nil {
+		return false, bugLog.Errorf("bug markSpike session: %+v", err)
+	}
+
+	if _, err := svc.UpdateItem(&dynamodb.UpdateItemInput{
+		ConditionExpression: aws.String("attribute_not_exists(last_spike) OR last_spike < :cutoff"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":now": {
+				S: aws.String(now.UTC().Format(time.RFC3339)),
+			},
+			":cutoff": {
+				S: aws.String(now.UTC().Add(-cooldown).Format(time.RFC3339)),
+			},
+		},
+		TableName: aws.String(b.Config.BugsTable),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {
+				S: aws.String(id),
+			},
+		},
+		UpdateExpression: aws.String("set last_spike = :now"),
+	}); err != nil {
+		// nolint:errorlint
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return false, nil
 		}
+		return false, dynamoError(err)
+	}
 
-		lr, err := time.Parse(b.Config.DateFormat, bri.LastReported)
-		if err != nil {
-			return brs, bugLog.Errorf("bug findAndStore lastReportedParse: %+v", err)
+	return true, nil
+}
+
+// MarkRelease adds the release to the ones the bug has been seen in, it is false when it was already there,
+// the releases are a string set kept on the bug
+func (b DynamoBugStorage) MarkRelease(id, release string) (bool, error) {
+	svc, err := b.dynamoSession()
+	if err != nil {
+		return false, bugLog.Errorf("bug markRelease session: %+v", err)
+	}
+
+	if _, err := svc.UpdateItem(&dynamodb.UpdateItemInput{
+		ConditionExpression: aws.String("attribute_not_exists(releases) OR NOT contains(releases, :release)"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":release": {
+				S: aws.String(release),
+			},
+			":releases": {
+				SS: []*string{aws.String(release)},
+			},
+		},
+		TableName: aws.String(b.Config.BugsTable),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {
+				S: aws.String(id),
+			},
+		},
+		UpdateExpression: aws.String("add releases :releases"),
+	}); err != nil {
+		// nolint:errorlint
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return false, nil
 		}
-		bri.LastReportedTime = lr
+		return false, dynamoError(err)
+	}
+
+	return true, nil
+}
 
-		fr, err := time.Parse(b.Config.DateFormat, bri.FirstReported)
+func (b DynamoBugStorage) unmarshalRecord(item map[string]*dynamodb.AttributeValue) (BugRecord, error) {
+	bri := BugRecord{}
+	if err := dynamodbattribute.UnmarshalMap(item, &bri); err != nil {
+		return bri, bugLog.Errorf("bug unmarshalRecord unmarshall: %+v", err)
+	}
+
+	// records from before atomic counting only have the string count
+	if bri.TimesReportedNumber == 0 {
+		trn, err := strconv.Atoi(bri.TimesReported)
 		if err != nil {
-			return brs, bugLog.Errorf("bug findAndStore firstReportedParse: %+v", err)
+			return bri, bugLog.Errorf("bug unmarshalRecord atoi: %+v", err)
 		}
-		bri.FirstReportedTime = fr
+		bri.TimesReportedNumber = trn
+	}
 
-		bri.TimesReportedNumber = trn + 1
-		brs = append(brs, bri)
+	lr, err := time.Parse(b.Config.DateFormat, bri.LastReported)
+	if err != nil {
+		return bri, bugLog.Errorf("bug unmarshalRecord lastReportedParse: %+v", err)
 	}
+	bri.LastReportedTime = lr
 
-	return brs, nil
+	fr, err := time.Parse(b.Config.DateFormat, bri.FirstReported)
+	if err != nil {
+		return bri, bugLog.Errorf("bug unmarshalRecord firstReportedParse: %+v", err)
+	}
+	bri.FirstReportedTime = fr
+
+	return bri, nil
 }
 
-func (b BugStorage) Store(data BugRecord) error {
+func (b DynamoBugStorage) Store(data BugRecord) error {
 	svc, err := b.dynamoSession()
 	if err != nil {
 		return bugLog.Errorf("bug store dynamosession failed: %+v", err)
@@ -199,40 +869,323 @@ func (b BugStorage) Store(data BugRecord) error {
 		return bugLog.Errorf("bug store marshal failed: %+v", err)
 	}
 
+	// the id comes from the agent, environment and fingerprint, so a record already there is the same bug
 	if _, err := svc.PutItem(&dynamodb.PutItemInput{
-		Item:      av,
-		TableName: aws.String(b.Config.BugsTable),
+		ConditionExpression: aws.String("attribute_not_exists(id)"),
+		Item:                av,
+		TableName:           aws.String(b.Config.BugsTable),
 	}); err != nil {
+		// nolint:errorlint
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return ErrBugExists
+		}
 		return bugLog.Errorf("bug store putitem failed: %+v", err)
 	}
 
 	return nil
 }
 
-func (b BugStorage) Update(data BugRecord) error {
+// Increment counts another occurrence in a single UpdateItem, so concurrent reports can't lose a count
+func (b DynamoBugStorage) Increment(data BugRecord) (BugRecord, error) {
 	svc, err := b.dynamoSession()
 	if err != nil {
-		return bugLog.Errorf("bug update dynamosession failed: %+v", err)
+		return data, bugLog.Errorf("bug increment dynamosession failed: %+v", err)
 	}
 
-	data.LastReported = time.Now().Format(b.Config.DateFormat)
+	key := map[string]*dynamodb.AttributeValue{
+		"id": {
+			S: aws.String(data.ID),
+		},
+	}
+
+	// records from before the histogram and the rates need the maps before a day or a minute can be set in them
+	if data.Occurrences == nil || data.Minutes == nil || data.Hours == nil {
+		if _, err := svc.UpdateItem(&dynamodb.UpdateItemInput{
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+				":empty": {
+					M: map[string]*dynamodb.AttributeValue{},
+				},
+			},
+			TableName: aws.String(b.Config.BugsTable),
+			Key:       key,
+			UpdateExpression: aws.String("set occurrences = if_not_exists(occurrences, :empty), " +
+				"minutes = if_not_exists(minutes, :empty), hours = if_not_exists(hours, :empty)"),
+		}); err != nil {
+			return data, dynamoError(err)
+		}
+	}
+
+	now := time.Now()
+	values := map[string]*dynamodb.AttributeValue{
+		":one": {
+			N: aws.String("1"),
+		},
+		":zero": {
+			N: aws.String("0"),
+		},
+		":tr": {
+			N: aws.String(strconv.Itoa(data.TimesReportedNumber)),
+		},
+		":lr": {
+			S: aws.String(now.Format(b.Config.DateFormat)),
+		},
+	}
+	names := map[string]*string{
+		"#day":    aws.String(now.UTC().Format(OccurrenceDayFormat)),
+		"#minute": aws.String(now.UTC().Format(RateMinuteFormat)),
+		"#hour":   aws.String(now.UTC().Format(RateHourFormat)),
+	}
+	update := "set times_reported_number = if_not_exists(times_reported_number, :tr) + :one, " +
+		"last_reported = :lr, " +
+		"occurrences.#day = if_not_exists(occurrences.#day, :zero) + :one, " +
+		"minutes.#minute = if_not_exists(minutes.#minute, :zero) + :one, " +
+		"hours.#hour = if_not_exists(hours.#hour, :zero) + :one"
+
+	// a report without a release or commit leaves the last one seen
+	if data.LastRelease != "" {
+		values[":rel"] = &dynamodb.AttributeValue{
+			S: aws.String(data.LastRelease),
+		}
+		update += ", last_release = :rel"
+	}
+	if data.LastCommit != "" {
+		values[":com"] = &dynamodb.AttributeValue{
+			S: aws.String(data.LastCommit),
+		}
+		update += ", last_commit = :com"
+	}
+
+	// the rates only keep what the spike check looks at, the stale buckets seen when the bug was found go
+	minuteCutoff, hourCutoff := rateCutoffs(b.Config, now)
+	remove := []string{}
+	for i, minute := range staleRates(data.Minutes, minuteCutoff) {
+		name := fmt.Sprintf("#sm%d", i)
+		names[name] = aws.String(minute)
+		remove = append(remove, "minutes."+name)
+	}
+	for i, hour := range staleRates(data.Hours, hourCutoff) {
+		name := fmt.Sprintf("#sh%d", i)
+		names[name] = aws.String(hour)
+		remove = append(remove, "hours."+name)
+	}
+	if len(remove) > 0 {
+		update += " remove " + strings.Join(remove, ", ")
+	}
+
+	result, err := svc.UpdateItem(&dynamodb.UpdateItemInput{
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+		TableName:                 aws.String(b.Config.BugsTable),
+		Key:                       key,
+		ReturnValues:              aws.String(dynamodb.ReturnValueAllNew),
+		UpdateExpression:          aws.String(update),
+	})
+	if err != nil {
+		return data, dynamoError(err)
+	}
+
+	bri, err := b.unmarshalRecord(result.Attributes)
+	if err != nil {
+		return data, bugLog.Errorf("bug increment unmarshalRecord: %+v", err)
+	}
+
+	return bri, nil
+}
+
+// Migrate makes sure the agent/fingerprint index exists,
+// and gives every record stored before fingerprinting its fingerprint so Find can reach it,
+// records the new fingerprint makes the same bug are merged into the one first reported
+func (b DynamoBugStorage) Migrate() (int, error) {
+	migrated := 0
+
+	svc, err := b.dynamoSession()
+	if err != nil {
+		return migrated, bugLog.Errorf("bug migrate session: %+v", err)
+	}
+
+	if err := b.createIndex(svc); err != nil {
+		return migrated, bugLog.Errorf("bug migrate createIndex: %+v", err)
+	}
+
+	brs := []BugRecord{}
+	releases := map[string][]*string{}
+	input := &dynamodb.ScanInput{
+		TableName: aws.String(b.Config.BugsTable),
+	}
+	for {
+		result, err := svc.Scan(input)
+		if err != nil {
+			return migrated, bugLog.Errorf("bug migrate scan: %+v", err)
+		}
+
+		for _, i := range result.Items {
+			bri := BugRecord{}
+			if err := dynamodbattribute.UnmarshalMap(i, &bri); err != nil {
+				return migrated, bugLog.Errorf("bug migrate unmarshal: %+v", err)
+			}
+			if bri.TimesReportedNumber == 0 {
+				bri.TimesReportedNumber, _ = strconv.Atoi(bri.TimesReported)
+			}
+			bri.FirstReportedTime, _ = time.Parse(b.Config.DateFormat, bri.FirstReported)
+			bri.LastReportedTime, _ = time.Parse(b.Config.DateFormat, bri.LastReported)
+			if r, ok := i["releases"]; ok {
+				releases[bri.ID] = r.SS
+			}
+
+			fingerprint := GenerateFingerprint(rawFromFull(bri.Full))
+			if bri.Fingerprint != fingerprint {
+				bri.Fingerprint = fingerprint
+				if _, err := svc.UpdateItem(&dynamodb.UpdateItemInput{
+					ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+						":fp": {
+							S: aws.String(fingerprint),
+						},
+					},
+					TableName: aws.String(b.Config.BugsTable),
+					Key: map[string]*dynamodb.AttributeValue{
+						"id": {
+							S: aws.String(bri.ID),
+						},
+					},
+					UpdateExpression: aws.String("set fingerprint = :fp"),
+				}); err != nil {
+					return migrated, bugLog.Errorf("bug migrate updateItem: %+v", err)
+				}
+				migrated++
+			}
+			brs = append(brs, bri)
+		}
+
+		if len(result.LastEvaluatedKey) == 0 {
+			break
+		}
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+
+	for _, group := range duplicateRecords(brs) {
+		keep := group[0]
+		for _, dup := range group[1:] {
+			keep = mergeRecord(b.Config, keep, dup)
+			if err := b.mergeRecord(svc, keep, dup, releases[dup.ID]); err != nil {
+				return migrated, bugLog.Errorf("bug migrate merge: %+v", err)
+			}
+			migrated++
+		}
+	}
+
+	return migrated, nil
+}
+
+// mergeRecord writes the merged keep back with dup's releases added, then deletes dup
+func (b DynamoBugStorage) mergeRecord(svc *dynamodb.DynamoDB, keep, dup BugRecord, releases []*string) error {
+	update := expression.Set(expression.Name("times_reported_number"), expression.Value(keep.TimesReportedNumber)).
+		Set(expression.Name("times_reported"), expression.Value(keep.TimesReported)).
+		Set(expression.Name("first_reported"), expression.Value(keep.FirstReported)).
+		Set(expression.Name("last_reported"), expression.Value(keep.LastReported)).
+		Set(expression.Name("first_release"), expression.Value(keep.FirstRelease)).
+		Set(expression.Name("last_release"), expression.Value(keep.LastRelease)).
+		Set(expression.Name("last_commit"), expression.Value(keep.LastCommit)).
+		Set(expression.Name("remote_link"), expression.Value(keep.RemoteLink)).
+		Set(expression.Name("ticket_system"), expression.Value(keep.TicketSystem))
+	// an empty map would be stored as NULL, which Increment can't count into
+	for name, buckets := range map[string]map[string]int{"occurrences": keep.Occurrences, "minutes": keep.Minutes, "hours": keep.Hours} {
+		if len(buckets) > 0 {
+			update = update.Set(expression.Name(name), expression.Value(buckets))
+		}
+	}
+	if len(releases) > 0 {
+		update = update.Add(expression.Name("releases"), expression.Value(&dynamodb.AttributeValue{SS: releases}))
+	}
+	expr, err := expression.NewBuilder().WithUpdate(update).Build()
+	if err != nil {
+		return bugLog.Errorf("bug mergeRecord build: %+v", err)
+	}
 
 	if _, err := svc.UpdateItem(&dynamodb.UpdateItemInput{
-		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
-			":tr": {
-				S: aws.String(fmt.Sprint(data.TimesReportedNumber)),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		TableName:                 aws.String(b.Config.BugsTable),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {
+				S: aws.String(keep.ID),
 			},
 		},
+		UpdateExpression: expr.Update(),
+	}); err != nil {
+		return bugLog.Errorf("bug mergeRecord updateItem: %+v", err)
+	}
+
+	if _, err := svc.DeleteItem(&dynamodb.DeleteItemInput{
 		TableName: aws.String(b.Config.BugsTable),
 		Key: map[string]*dynamodb.AttributeValue{
 			"id": {
-				S: aws.String(data.ID),
+				S: aws.String(dup.ID),
+			},
+		},
+	}); err != nil {
+		return bugLog.Errorf("bug mergeRecord deleteItem: %+v", err)
+	}
+
+	return nil
+}
+
+func (b DynamoBugStorage) createIndex(svc *dynamodb.DynamoDB) error {
+	table, err := svc.DescribeTable(&dynamodb.DescribeTableInput{
+		TableName: aws.String(b.Config.BugsTable),
+	})
+	if err != nil {
+		return bugLog.Errorf("bug createIndex describe: %+v", err)
+	}
+
+	for _, gsi := range table.Table.GlobalSecondaryIndexes {
+		if aws.StringValue(gsi.IndexName) == b.Config.BugsIndex {
+			return nil
+		}
+	}
+
+	index := &dynamodb.CreateGlobalSecondaryIndexAction{
+		IndexName: aws.String(b.Config.BugsIndex),
+		KeySchema: []*dynamodb.KeySchemaElement{
+			{
+				AttributeName: aws.String("agent_id"),
+				KeyType:       aws.String(dynamodb.KeyTypeHash),
+			},
+			{
+				AttributeName: aws.String("fingerprint"),
+				KeyType:       aws.String(dynamodb.KeyTypeRange),
+			},
+		},
+		Projection: &dynamodb.Projection{
+			ProjectionType: aws.String(dynamodb.ProjectionTypeAll),
+		},
+	}
+	if table.Table.BillingModeSummary == nil ||
+		aws.StringValue(table.Table.BillingModeSummary.BillingMode) != dynamodb.BillingModePayPerRequest {
+		index.ProvisionedThroughput = &dynamodb.ProvisionedThroughput{
+			ReadCapacityUnits:  aws.Int64(5),
+			WriteCapacityUnits: aws.Int64(5),
+		}
+	}
+
+	if _, err := svc.UpdateTable(&dynamodb.UpdateTableInput{
+		TableName: aws.String(b.Config.BugsTable),
+		AttributeDefinitions: []*dynamodb.AttributeDefinition{
+			{
+				AttributeName: aws.String("agent_id"),
+				AttributeType: aws.String(dynamodb.ScalarAttributeTypeS),
+			},
+			{
+				AttributeName: aws.String("fingerprint"),
+				AttributeType: aws.String(dynamodb.ScalarAttributeTypeS),
+			},
+		},
+		GlobalSecondaryIndexUpdates: []*dynamodb.GlobalSecondaryIndexUpdate{
+			{
+				Create: index,
 			},
 		},
-		ReturnValues:     aws.String("UPDATED_NEW"),
-		UpdateExpression: aws.String("set times_reported = :tr"),
 	}); err != nil {
-		return bugLog.Errorf("bug update updateItem failed: %+v", err)
+		return dynamoError(err)
 	}
 
 	return nil
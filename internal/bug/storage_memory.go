@@ -0,0 +1,253 @@
+package bug
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bugfixes/celeste/internal/config"
+	bugLog "github.com/bugfixes/go-bugfixes/logs"
+)
+
+type bugMemory struct {
+	sync.RWMutex
+
+	records  map[string]BugRecord
+	releases map[string]map[string]bool
+}
+
+// sharedBugMemory is kept for the life of the process so every MemoryBugStorage sees the same bugs
+var sharedBugMemory = &bugMemory{
+	records:  map[string]BugRecord{},
+	releases: map[string]map[string]bool{},
+}
+
+// ResetMemory empties the bugs every MemoryBugStorage shares
+func ResetMemory() {
+	sharedBugMemory.Lock()
+	defer sharedBugMemory.Unlock()
+
+	sharedBugMemory.records = map[string]BugRecord{}
+	sharedBugMemory.releases = map[string]map[string]bool{}
+}
+
+type MemoryBugStorage struct {
+	Config config.Config
+
+	memory *bugMemory
+}
+
+func NewMemoryBugStorage(c config.Config) *MemoryBugStorage {
+	return &MemoryBugStorage{
+		Config: c,
+		memory: sharedBugMemory,
+	}
+}
+
+func (b MemoryBugStorage) Insert(data BugRecord) error {
+	b.memory.Lock()
+	defer b.memory.Unlock()
+
+	b.memory.records[data.ID] = data
+
+	return nil
+}
+
+func (b MemoryBugStorage) FindAndStore(data BugRecord) (BugRecord, error) {
+	return findAndStore(b, b.Config, data)
+}
+
+func (b MemoryBugStorage) Find(data BugRecord) ([]BugRecord, error) {
+	b.memory.RLock()
+	defer b.memory.RUnlock()
+
+	brs := []BugRecord{}
+	for _, bri := range b.memory.records {
+		if bri.Fingerprint != data.Fingerprint || bri.AgentID != data.AgentID || bri.Environment != data.Environment {
+			continue
+		}
+
+		brs = append(brs, bri)
+	}
+
+	return brs, nil
+}
+
+func (b MemoryBugStorage) Store(data BugRecord) error {
+	data.FirstReported = time.Now().Format(b.Config.DateFormat)
+	data.LastReported = time.Now().Format(b.Config.DateFormat)
+
+	b.memory.Lock()
+	defer b.memory.Unlock()
+
+	for _, bri := range b.memory.records {
+		if bri.ID == data.ID || (bri.Fingerprint == data.Fingerprint && bri.AgentID == data.AgentID && bri.Environment == data.Environment) {
+			return ErrBugExists
+		}
+	}
+	b.memory.records[data.ID] = data
+
+	return nil
+}
+
+func (b MemoryBugStorage) Increment(data BugRecord) (BugRecord, error) {
+	b.memory.Lock()
+	defer b.memory.Unlock()
+
+	bri, ok := b.memory.records[data.ID]
+	if !ok {
+		return data, bugLog.Errorf("bug increment: %s not found", data.ID)
+	}
+
+	now := time.Now()
+	bri.TimesReportedNumber++
+	bri.TimesReported = strconv.Itoa(bri.TimesReportedNumber)
+	bri.LastReportedTime = now
+	bri.LastReported = now.Format(b.Config.DateFormat)
+	if data.LastRelease != "" {
+		bri.LastRelease = data.LastRelease
+	}
+	if data.LastCommit != "" {
+		bri.LastCommit = data.LastCommit
+	}
+
+	occurrences := map[string]int{}
+	for day, count := range bri.Occurrences {
+		occurrences[day] = count
+	}
+	occurrences[now.UTC().Format(OccurrenceDayFormat)]++
+	bri.Occurrences = occurrences
+	minuteCutoff, hourCutoff := rateCutoffs(b.Config, now)
+	bri.Minutes = countRate(bri.Minutes, now.UTC().Format(RateMinuteFormat), minuteCutoff)
+	bri.Hours = countRate(bri.Hours, now.UTC().Format(RateHourFormat), hourCutoff)
+
+	b.memory.records[data.ID] = bri
+
+	return bri, nil
+}
+
+func (b MemoryBugStorage) Migrate() (int, error) {
+	b.memory.Lock()
+	defer b.memory.Unlock()
+
+	migrated := 0
+	brs := make([]BugRecord, 0, len(b.memory.records))
+	for id, bri := range b.memory.records {
+		if fp := GenerateFingerprint(rawFromFull(bri.Full)); fp != bri.Fingerprint {
+			bri.Fingerprint = fp
+			b.memory.records[id] = bri
+			migrated++
+		}
+		brs = append(brs, bri)
+	}
+
+	for _, group := range duplicateRecords(brs) {
+		keep := group[0]
+		for _, dup := range group[1:] {
+			keep = mergeRecord(b.Config, keep, dup)
+			if b.memory.releases[keep.ID] == nil {
+				b.memory.releases[keep.ID] = map[string]bool{}
+			}
+			for release := range b.memory.releases[dup.ID] {
+				b.memory.releases[keep.ID][release] = true
+			}
+			delete(b.memory.releases, dup.ID)
+			delete(b.memory.records, dup.ID)
+			migrated++
+		}
+		b.memory.records[keep.ID] = keep
+	}
+
+	return migrated, nil
+}
+
+func (b MemoryBugStorage) List(filter BugFilter) ([]BugRecord, string, error) {
+	b.memory.RLock()
+	brs := make([]BugRecord, 0, len(b.memory.records))
+	for _, bri := range b.memory.records {
+		brs = append(brs, bri)
+	}
+	b.memory.RUnlock()
+
+	return pageRecords(brs, filter)
+}
+
+func (b MemoryBugStorage) Get(agentID, id string) (BugRecord, error) {
+	b.memory.RLock()
+	defer b.memory.RUnlock()
+
+	bri, ok := b.memory.records[id]
+	if !ok || bri.AgentID != agentID {
+		return BugRecord{}, ErrBugNotFound
+	}
+
+	return bri, nil
+}
+
+func (b MemoryBugStorage) LinkTicket(data BugRecord) error {
+	b.memory.Lock()
+	defer b.memory.Unlock()
+
+	bri, ok := b.memory.records[data.ID]
+	if !ok {
+		return bugLog.Errorf("bug linkTicket: %s not found", data.ID)
+	}
+	bri.RemoteLink = data.RemoteLink
+	bri.TicketSystem = data.TicketSystem
+	b.memory.records[data.ID] = bri
+
+	return nil
+}
+
+func (b MemoryBugStorage) UpdateStatus(data BugRecord) error {
+	b.memory.Lock()
+	defer b.memory.Unlock()
+
+	bri, ok := b.memory.records[data.ID]
+	if !ok {
+		return bugLog.Errorf("bug updateStatus: %s not found", data.ID)
+	}
+	bri.Status = data.Status
+	bri.StatusChanged = data.StatusChanged
+	bri.SnoozeUntil = data.SnoozeUntil
+	bri.SnoozeUntilTimes = data.SnoozeUntilTimes
+	bri.Assignee = data.Assignee
+	b.memory.records[data.ID] = bri
+
+	return nil
+}
+
+func (b MemoryBugStorage) MarkSpike(id string, now time.Time, cooldown time.Duration) (bool, error) {
+	b.memory.Lock()
+	defer b.memory.Unlock()
+
+	bri, ok := b.memory.records[id]
+	if !ok {
+		return false, bugLog.Errorf("bug markSpike: %s not found", id)
+	}
+	if !bri.LastSpike.IsZero() && bri.LastSpike.After(now.Add(-cooldown)) {
+		return false, nil
+	}
+	bri.LastSpike = now
+	b.memory.records[id] = bri
+
+	return true, nil
+}
+
+func (b MemoryBugStorage) MarkRelease(id, release string) (bool, error) {
+	b.memory.Lock()
+	defer b.memory.Unlock()
+
+	if _, ok := b.memory.records[id]; !ok {
+		return false, bugLog.Errorf("bug markRelease: %s not found", id)
+	}
+	if b.memory.releases[id] == nil {
+		b.memory.releases[id] = map[string]bool{}
+	}
+	if b.memory.releases[id][release] {
+		return false, nil
+	}
+	b.memory.releases[id][release] = true
+
+	return true, nil
+}
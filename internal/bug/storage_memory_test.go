@@ -0,0 +1,509 @@
+package bug_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bugfixes/celeste/internal/agent"
+	bug "github.com/bugfixes/celeste/internal/bug"
+	"github.com/bugfixes/celeste/internal/comms"
+	"github.com/bugfixes/celeste/internal/config"
+	"github.com/bugfixes/celeste/internal/database"
+	"github.com/bugfixes/celeste/internal/ticketing"
+	"github.com/stretchr/testify/assert"
+)
+
+// resetMemory empties every memory backend the bug tests write to, so -count=2 starts from nothing
+func resetMemory() {
+	bug.ResetMemory()
+	database.ResetMemory()
+	agent.ResetMemory()
+	comms.ResetMemory()
+	ticketing.ResetMemory()
+}
+
+func TestMemoryBugStorage_FindAndStore(t *testing.T) {
+	t.Cleanup(resetMemory)
+
+	tests := []struct {
+		name    string
+		request []bug.BugRecord
+		expect  int
+		err     error
+	}{
+		{
+			name: "first report",
+			request: []bug.BugRecord{
+				{ID: "1", AgentID: "first", Fingerprint: "tester"},
+			},
+			expect: 1,
+		},
+		{
+			name: "reported three times",
+			request: []bug.BugRecord{
+				{ID: "1", AgentID: "three", Fingerprint: "tester"},
+				{ID: "2", AgentID: "three", Fingerprint: "tester"},
+				{ID: "3", AgentID: "three", Fingerprint: "tester"},
+			},
+			expect: 3,
+		},
+		{
+			name: "different agents",
+			request: []bug.BugRecord{
+				{ID: "1", AgentID: "agent-a", Fingerprint: "tester"},
+				{ID: "2", AgentID: "agent-b", Fingerprint: "tester"},
+			},
+			expect: 1,
+		},
+		{
+			name: "different environments",
+			request: []bug.BugRecord{
+				{ID: "1", AgentID: "environments", Fingerprint: "tester", Environment: "production"},
+				{ID: "2", AgentID: "environments", Fingerprint: "tester", Environment: "staging"},
+			},
+			expect: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := bug.NewBugStorage(config.Config{
+				Storage: config.Storage{
+					StorageBackend: config.StorageMemory,
+				},
+			})
+
+			var resp bug.BugRecord
+			var err error
+			for _, r := range test.request {
+				r.ID = test.name + r.ID
+				resp, err = s.FindAndStore(r)
+			}
+			if passed := assert.IsType(t, test.err, err); !passed {
+				t.Errorf("findAndStore err: %+v", err)
+			}
+			if passed := assert.Equal(t, test.expect, resp.TimesReportedNumber); !passed {
+				t.Errorf("findAndStore expect: %v, got: %v", test.expect, resp.TimesReportedNumber)
+			}
+		})
+	}
+}
+
+func TestMemoryBugStorage_Increment(t *testing.T) {
+	t.Cleanup(resetMemory)
+
+	s := bug.NewBugStorage(config.Config{
+		Storage: config.Storage{
+			StorageBackend: config.StorageMemory,
+		},
+	})
+
+	first, err := s.FindAndStore(bug.BugRecord{ID: "increment", AgentID: "increment", Fingerprint: "tester"})
+	if passed := assert.Nil(t, err); !passed {
+		t.Errorf("findAndStore err: %+v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := s.Increment(first); err != nil {
+				t.Errorf("increment err: %+v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	resp, err := s.Increment(first)
+	if passed := assert.Nil(t, err); !passed {
+		t.Errorf("increment err: %+v", err)
+	}
+	if passed := assert.Equal(t, 52, resp.TimesReportedNumber); !passed {
+		t.Errorf("increment expect: %v, got: %v", 52, resp.TimesReportedNumber)
+	}
+	if passed := assert.Equal(t, 52, resp.Occurrences[resp.LastReportedTime.UTC().Format(bug.OccurrenceDayFormat)]); !passed {
+		t.Errorf("increment occurrences: %v", resp.Occurrences)
+	}
+}
+
+func TestMemoryBugStorage_FindAndStoreConcurrentFirst(t *testing.T) {
+	t.Cleanup(resetMemory)
+
+	s := bug.NewBugStorage(config.Config{
+		Storage: config.Storage{
+			StorageBackend: config.StorageMemory,
+		},
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := s.FindAndStore(bug.BugRecord{AgentID: "concurrent", Fingerprint: "tester"}); err != nil {
+				t.Errorf("findAndStore err: %+v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	resp, err := s.Find(bug.BugRecord{AgentID: "concurrent", Fingerprint: "tester"})
+	if passed := assert.Nil(t, err); !passed {
+		t.Errorf("find err: %+v", err)
+	}
+	if passed := assert.Len(t, resp, 1); !passed {
+		t.Fatalf("find expect: 1 record, got: %d", len(resp))
+	}
+	if passed := assert.Equal(t, 20, resp[0].TimesReportedNumber); !passed {
+		t.Errorf("times reported expect: %v, got: %v", 20, resp[0].TimesReportedNumber)
+	}
+	if passed := assert.Equal(t, bug.BugRecordID("concurrent", "", "tester"), resp[0].ID); !passed {
+		t.Errorf("id expect: %v, got: %v", bug.BugRecordID("concurrent", "", "tester"), resp[0].ID)
+	}
+}
+
+func TestMemoryBugStorage_FindAndStoreReleases(t *testing.T) {
+	t.Cleanup(resetMemory)
+
+	s := bug.NewBugStorage(config.Config{
+		Storage: config.Storage{
+			StorageBackend: config.StorageMemory,
+		},
+	})
+
+	tests := []struct {
+		name       string
+		release    string
+		commit     string
+		newRelease bool
+		first      string
+		last       string
+		lastCommit string
+	}{
+		{
+			name:       "first report",
+			release:    "1.0.0",
+			commit:     "aaa",
+			newRelease: true,
+			first:      "1.0.0",
+			last:       "1.0.0",
+			lastCommit: "aaa",
+		},
+		{
+			name:       "same release",
+			release:    "1.0.0",
+			commit:     "aaa",
+			first:      "1.0.0",
+			last:       "1.0.0",
+			lastCommit: "aaa",
+		},
+		{
+			name:       "no release sent",
+			first:      "1.0.0",
+			last:       "1.0.0",
+			lastCommit: "aaa",
+		},
+		{
+			name:       "next release",
+			release:    "1.1.0",
+			commit:     "bbb",
+			newRelease: true,
+			first:      "1.0.0",
+			last:       "1.1.0",
+			lastCommit: "bbb",
+		},
+		{
+			name:       "canary",
+			release:    "1.2.0",
+			commit:     "ccc",
+			newRelease: true,
+			first:      "1.0.0",
+			last:       "1.2.0",
+			lastCommit: "ccc",
+		},
+		{
+			name:       "back on the release already seen",
+			release:    "1.1.0",
+			commit:     "bbb",
+			first:      "1.0.0",
+			last:       "1.1.0",
+			lastCommit: "bbb",
+		},
+		{
+			name:       "canary again",
+			release:    "1.2.0",
+			commit:     "ccc",
+			first:      "1.0.0",
+			last:       "1.2.0",
+			lastCommit: "ccc",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp, err := s.FindAndStore(bug.BugRecord{
+				ID:          "releases",
+				AgentID:     "releases",
+				Fingerprint: "tester",
+				LastRelease: test.release,
+				LastCommit:  test.commit,
+			})
+			if passed := assert.Nil(t, err); !passed {
+				t.Fatalf("findAndStore err: %+v", err)
+			}
+			if passed := assert.Equal(t, test.newRelease, resp.NewRelease); !passed {
+				t.Errorf("findAndStore newRelease expect: %v, got: %v", test.newRelease, resp.NewRelease)
+			}
+			if passed := assert.Equal(t, test.first, resp.FirstRelease); !passed {
+				t.Errorf("findAndStore firstRelease expect: %v, got: %v", test.first, resp.FirstRelease)
+			}
+			if passed := assert.Equal(t, test.last, resp.LastRelease); !passed {
+				t.Errorf("findAndStore lastRelease expect: %v, got: %v", test.last, resp.LastRelease)
+			}
+			if passed := assert.Equal(t, test.lastCommit, resp.LastCommit); !passed {
+				t.Errorf("findAndStore lastCommit expect: %v, got: %v", test.lastCommit, resp.LastCommit)
+			}
+		})
+	}
+}
+
+func TestMemoryBugStorage_List(t *testing.T) {
+	t.Cleanup(resetMemory)
+
+	s := bug.NewBugStorage(config.Config{
+		Storage: config.Storage{
+			StorageBackend: config.StorageMemory,
+		},
+	})
+
+	for _, r := range []bug.BugRecord{
+		{ID: "list-1", AgentID: "list", Fingerprint: "a", Level: "error", File: "main.go"},
+		{ID: "list-2", AgentID: "list", Fingerprint: "b", Level: "error", File: "other.go"},
+		{ID: "list-3", AgentID: "list", Fingerprint: "c", Level: "info", File: "main.go"},
+		{ID: "list-4", AgentID: "list-other", Fingerprint: "a", Level: "error", File: "main.go"},
+	} {
+		if _, err := s.FindAndStore(r); err != nil {
+			t.Errorf("findAndStore err: %+v", err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter bug.BugFilter
+		expect int
+	}{
+		{
+			name:   "agent",
+			filter: bug.BugFilter{AgentID: "list"},
+			expect: 3,
+		},
+		{
+			name:   "level",
+			filter: bug.BugFilter{AgentID: "list", Level: "error"},
+			expect: 2,
+		},
+		{
+			name:   "level and file",
+			filter: bug.BugFilter{AgentID: "list", Level: "error", File: "main.go"},
+			expect: 1,
+		},
+		{
+			name:   "times reported",
+			filter: bug.BugFilter{AgentID: "list", MinTimesReported: 2},
+			expect: 0,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp, _, err := s.List(test.filter)
+			if passed := assert.Nil(t, err); !passed {
+				t.Errorf("list err: %+v", err)
+			}
+			if passed := assert.Equal(t, test.expect, len(resp)); !passed {
+				t.Errorf("list expect: %v, got: %v", test.expect, len(resp))
+			}
+		})
+	}
+
+	t.Run("pages", func(t *testing.T) {
+		seen := map[string]bool{}
+		filter := bug.BugFilter{AgentID: "list", Limit: 2}
+		for pages := 0; pages < 3; pages++ {
+			resp, cursor, err := s.List(filter)
+			if passed := assert.Nil(t, err); !passed {
+				t.Errorf("list err: %+v", err)
+			}
+			for _, bri := range resp {
+				seen[bri.ID] = true
+			}
+			if cursor == "" {
+				break
+			}
+			filter.Cursor = cursor
+		}
+		if passed := assert.Equal(t, 3, len(seen)); !passed {
+			t.Errorf("pages expect: 3, got: %v", seen)
+		}
+	})
+
+	t.Run("get other agent", func(t *testing.T) {
+		_, err := s.Get("list", "list-4")
+		if passed := assert.ErrorIs(t, err, bug.ErrBugNotFound); !passed {
+			t.Errorf("get err: %+v", err)
+		}
+	})
+}
+
+func TestMemoryBugStorage_Rates(t *testing.T) {
+	t.Cleanup(resetMemory)
+
+	s := bug.NewBugStorage(config.Config{
+		Storage: config.Storage{
+			StorageBackend: config.StorageMemory,
+		},
+		Spike: config.Spike{
+			BaselineHours: 24,
+		},
+	})
+
+	now := time.Now().UTC()
+	staleMinute := now.Add(-3 * time.Hour).Format(bug.RateMinuteFormat)
+	staleHour := now.Add(-30 * time.Hour).Format(bug.RateHourFormat)
+	keptHour := now.Add(-20 * time.Hour).Format(bug.RateHourFormat)
+	if err := s.Insert(bug.BugRecord{
+		ID:          "rates",
+		AgentID:     "rates",
+		Fingerprint: "rates",
+		Minutes:     map[string]int{staleMinute: 4},
+		Hours:       map[string]int{staleHour: 4, keptHour: 2},
+	}); err != nil {
+		t.Fatalf("insert err: %+v", err)
+	}
+
+	resp, err := s.Increment(bug.BugRecord{ID: "rates"})
+	if passed := assert.Nil(t, err); !passed {
+		t.Fatalf("increment err: %+v", err)
+	}
+	expectMinutes := map[string]int{resp.LastReportedTime.UTC().Format(bug.RateMinuteFormat): 1}
+	if passed := assert.Equal(t, expectMinutes, resp.Minutes); !passed {
+		t.Errorf("increment minutes expect: %v, got: %v", expectMinutes, resp.Minutes)
+	}
+	expectHours := map[string]int{keptHour: 2, resp.LastReportedTime.UTC().Format(bug.RateHourFormat): 1}
+	if passed := assert.Equal(t, expectHours, resp.Hours); !passed {
+		t.Errorf("increment hours expect: %v, got: %v", expectHours, resp.Hours)
+	}
+
+	for _, mark := range []struct {
+		at     time.Time
+		marked bool
+	}{
+		{at: now, marked: true},
+		{at: now.Add(30 * time.Minute), marked: false},
+		{at: now.Add(2 * time.Hour), marked: true},
+	} {
+		marked, err := s.MarkSpike("rates", mark.at, time.Hour)
+		if passed := assert.Nil(t, err); !passed {
+			t.Errorf("markSpike err: %+v", err)
+		}
+		if passed := assert.Equal(t, mark.marked, marked); !passed {
+			t.Errorf("markSpike at %v expect: %v, got: %v", mark.at, mark.marked, marked)
+		}
+	}
+}
+
+func TestMemoryBugStorage_MigrateMerge(t *testing.T) {
+	t.Cleanup(resetMemory)
+
+	s := bug.NewBugStorage(config.Config{
+		Storage: config.Storage{
+			StorageBackend: config.StorageMemory,
+		},
+	})
+
+	now := time.Now()
+	full := bug.FullDetails{Raw: "panic: runtime error: index out of range"}
+	for _, bri := range []bug.BugRecord{
+		{
+			ID:                  "merge-first",
+			AgentID:             "merge",
+			Fingerprint:         "legacy-a",
+			Full:                full,
+			TimesReportedNumber: 3,
+			FirstReportedTime:   now.Add(-48 * time.Hour),
+			LastReportedTime:    now.Add(-24 * time.Hour),
+			Occurrences:         map[string]int{"2021-06-01": 2, "2021-06-02": 1},
+		},
+		{
+			ID:                  "merge-second",
+			AgentID:             "merge",
+			Fingerprint:         "legacy-b",
+			Full:                full,
+			TimesReportedNumber: 2,
+			FirstReportedTime:   now.Add(-36 * time.Hour),
+			LastReportedTime:    now,
+			Occurrences:         map[string]int{"2021-06-02": 2},
+			RemoteLink:          "https://github.com/bugfixes/celeste/issues/1",
+			LastRelease:         "v1.1.0",
+		},
+		{
+			ID:                  "merge-other",
+			AgentID:             "merge",
+			Fingerprint:         "legacy-c",
+			Full:                bug.FullDetails{Raw: "panic: something else entirely"},
+			TimesReportedNumber: 1,
+		},
+	} {
+		if err := s.Insert(bri); err != nil {
+			t.Fatalf("insert err: %+v", err)
+		}
+	}
+	if _, err := s.MarkRelease("merge-second", "v1.1.0"); err != nil {
+		t.Fatalf("markRelease err: %+v", err)
+	}
+
+	migrated, err := s.Migrate()
+	if passed := assert.Nil(t, err); !passed {
+		t.Fatalf("migrate err: %+v", err)
+	}
+	if passed := assert.Equal(t, 4, migrated); !passed {
+		t.Errorf("migrated expect: %v, got: %v", 4, migrated)
+	}
+
+	brs, _, err := s.List(bug.BugFilter{AgentID: "merge"})
+	if passed := assert.Nil(t, err); !passed {
+		t.Fatalf("list err: %+v", err)
+	}
+	if passed := assert.Len(t, brs, 2); !passed {
+		t.Fatalf("list got: %+v", brs)
+	}
+
+	merged, err := s.Get("merge", "merge-first")
+	if passed := assert.Nil(t, err); !passed {
+		t.Fatalf("get err: %+v", err)
+	}
+	if passed := assert.Equal(t, 5, merged.TimesReportedNumber); !passed {
+		t.Errorf("times reported expect: %v, got: %v", 5, merged.TimesReportedNumber)
+	}
+	if passed := assert.Equal(t, map[string]int{"2021-06-01": 2, "2021-06-02": 3}, merged.Occurrences); !passed {
+		t.Errorf("occurrences got: %v", merged.Occurrences)
+	}
+	if passed := assert.Equal(t, "https://github.com/bugfixes/celeste/issues/1", merged.RemoteLink); !passed {
+		t.Errorf("remote link got: %v", merged.RemoteLink)
+	}
+	if passed := assert.Equal(t, "v1.1.0", merged.LastRelease); !passed {
+		t.Errorf("last release got: %v", merged.LastRelease)
+	}
+	if passed := assert.True(t, merged.LastReportedTime.Equal(now)); !passed {
+		t.Errorf("last reported got: %v", merged.LastReportedTime)
+	}
+	marked, err := s.MarkRelease("merge-first", "v1.1.0")
+	if passed := assert.Nil(t, err); !passed {
+		t.Errorf("markRelease err: %+v", err)
+	}
+	if passed := assert.False(t, marked); !passed {
+		t.Errorf("merged bug lost the release it was seen in")
+	}
+}
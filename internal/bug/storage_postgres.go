@@ -0,0 +1,765 @@
+package bug
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bugfixes/celeste/internal/config"
+	bugLog "github.com/bugfixes/go-bugfixes/logs"
+	"github.com/jackc/pgx/v4"
+)
+
+type PostgresBugStorage struct {
+	Config  config.Config
+	Context context.Context
+}
+
+func NewPostgresBugStorage(c config.Config) *PostgresBugStorage {
+	return &PostgresBugStorage{
+		Config:  c,
+		Context: context.Background(),
+	}
+}
+
+func (b PostgresBugStorage) getConnection() (*pgx.Conn, error) {
+	conn, err := pgx.Connect(
+		b.Context,
+		fmt.Sprintf(
+			"postgres://%s:%s@%s:%s/%s",
+			b.Config.RDS.Username,
+			b.Config.RDS.Password,
+			b.Config.RDS.Hostname,
+			b.Config.RDS.Port,
+			b.Config.RDS.Database))
+	if err != nil {
+		return nil, bugLog.Errorf("getConnection: %+v", err)
+	}
+
+	return conn, nil
+}
+
+func (b PostgresBugStorage) closeConnection(conn *pgx.Conn) {
+	if err := conn.Close(b.Context); err != nil {
+		bugLog.Debugf("close: %+v", err)
+	}
+}
+
+func (b PostgresBugStorage) Insert(data BugRecord) error {
+	conn, err := b.getConnection()
+	if err != nil {
+		return bugLog.Errorf("insert bug connection: %+v", err)
+	}
+	defer b.closeConnection(conn)
+
+	full, err := json.Marshal(data.Full)
+	if err != nil {
+		return bugLog.Errorf("insert bug marshal: %+v", err)
+	}
+
+	// the bug and its first occurrence go in together, a crash between them would leave a bug nothing counted
+	tx, err := conn.Begin(b.Context)
+	if err != nil {
+		return bugLog.Errorf("insert bug begin: %+v", err)
+	}
+	defer func() {
+		if err := tx.Rollback(b.Context); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			bugLog.Debugf("rollback: %+v", err)
+		}
+	}()
+
+	// the unique index on agent, environment and fingerprint turns a second first report into no row at all
+	tag, err := tx.Exec(b.Context,
+		"INSERT INTO bug (id, agent_id, environment, level, file, line, file_line_hash, hash, fingerprint, full_details, times_reported, first_reported, last_reported, status, status_changed, first_release, last_release, last_commit) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18) ON CONFLICT DO NOTHING",
+		data.ID,
+		data.AgentID,
+		data.Environment,
+		data.Level,
+		data.File,
+		data.Line,
+		data.FileLineHash,
+		data.Hash,
+		data.Fingerprint,
+		string(full),
+		data.TimesReportedNumber,
+		data.FirstReportedTime,
+		data.LastReportedTime,
+		data.CurrentStatus(),
+		nullTime(data.StatusChanged),
+		data.FirstRelease,
+		data.LastRelease,
+		data.LastCommit)
+	if err != nil {
+		return bugLog.Errorf("insert bug exec: %+v", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrBugExists
+	}
+
+	for day, count := range data.Occurrences {
+		if _, err := tx.Exec(b.Context,
+			"INSERT INTO bug_occurrence (bug_id, day, occurrences) VALUES ($1, $2, $3)",
+			data.ID,
+			day,
+			count); err != nil {
+			return bugLog.Errorf("insert bug occurrence: %+v", err)
+		}
+	}
+	for span, buckets := range map[string]map[string]int{rateMinute: data.Minutes, rateHour: data.Hours} {
+		for bucket, count := range buckets {
+			if _, err := tx.Exec(b.Context,
+				"INSERT INTO bug_rate (bug_id, span, bucket, occurrences) VALUES ($1, $2, $3, $4)",
+				data.ID,
+				span,
+				bucket,
+				count); err != nil {
+				return bugLog.Errorf("insert bug rate: %+v", err)
+			}
+		}
+	}
+
+	if err := tx.Commit(b.Context); err != nil {
+		return bugLog.Errorf("insert bug commit: %+v", err)
+	}
+
+	return nil
+}
+
+func (b PostgresBugStorage) FindAndStore(data BugRecord) (BugRecord, error) {
+	return findAndStore(b, b.Config, data)
+}
+
+// bugColumns are the columns scanRecord expects, in its order
+const bugColumns = "id, agent_id, COALESCE(environment, ''), level, COALESCE(file, ''), COALESCE(line, ''), hash, fingerprint, full_details, times_reported, first_reported, last_reported, COALESCE(remote_link, ''), COALESCE(ticket_system, ''), COALESCE(status, 'open'), status_changed, snooze_until, COALESCE(snooze_until_times, 0), COALESCE(first_release, ''), COALESCE(last_release, ''), COALESCE(last_commit, ''), last_spike, COALESCE(assignee, '')"
+
+func (b PostgresBugStorage) scanRecord(row pgx.Row) (BugRecord, error) {
+	bri := BugRecord{}
+	var full string
+	var statusChanged, snoozeUntil, lastSpike *time.Time
+	if err := row.Scan(
+		&bri.ID,
+		&bri.AgentID,
+		&bri.Environment,
+		&bri.Level,
+		&bri.File,
+		&bri.Line,
+		&bri.Hash,
+		&bri.Fingerprint,
+		&full,
+		&bri.TimesReportedNumber,
+		&bri.FirstReportedTime,
+		&bri.LastReportedTime,
+		&bri.RemoteLink,
+		&bri.TicketSystem,
+		&bri.Status,
+		&statusChanged,
+		&snoozeUntil,
+		&bri.SnoozeUntilTimes,
+		&bri.FirstRelease,
+		&bri.LastRelease,
+		&bri.LastCommit,
+		&lastSpike,
+		&bri.Assignee); err != nil {
+		return bri, err
+	}
+	if statusChanged != nil {
+		bri.StatusChanged = *statusChanged
+	}
+	if snoozeUntil != nil {
+		bri.SnoozeUntil = *snoozeUntil
+	}
+	if lastSpike != nil {
+		bri.LastSpike = *lastSpike
+	}
+	if err := json.Unmarshal([]byte(full), &bri.Full); err != nil {
+		return bri, bugLog.Errorf("scanRecord unmarshal: %+v", err)
+	}
+
+	bri.TimesReported = strconv.Itoa(bri.TimesReportedNumber)
+	bri.FirstReported = bri.FirstReportedTime.Format(b.Config.DateFormat)
+	bri.LastReported = bri.LastReportedTime.Format(b.Config.DateFormat)
+
+	return bri, nil
+}
+
+func (b PostgresBugStorage) Find(data BugRecord) ([]BugRecord, error) {
+	brs := []BugRecord{}
+
+	conn, err := b.getConnection()
+	if err != nil {
+		return brs, bugLog.Errorf("bug find connection: %+v", err)
+	}
+	defer b.closeConnection(conn)
+
+	rows, err := conn.Query(b.Context,
+		"SELECT "+bugColumns+" FROM bug WHERE fingerprint = $1 AND agent_id = $2 AND COALESCE(environment, '') = $3",
+		data.Fingerprint,
+		data.AgentID,
+		data.Environment)
+	if err != nil {
+		return brs, bugLog.Errorf("bug find query: %+v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		bri, err := b.scanRecord(rows)
+		if err != nil {
+			return brs, bugLog.Errorf("bug find scan: %+v", err)
+		}
+		brs = append(brs, bri)
+	}
+	if err := rows.Err(); err != nil {
+		return brs, bugLog.Errorf("bug find rows: %+v", err)
+	}
+
+	return brs, nil
+}
+
+func (b PostgresBugStorage) Store(data BugRecord) error {
+	data.FirstReportedTime = time.Now()
+	data.LastReportedTime = time.Now()
+
+	return b.Insert(data)
+}
+
+// Increment counts another occurrence inside one transaction, the counter is bumped by the UPDATE itself
+// so concurrent reports can't lose a count
+func (b PostgresBugStorage) Increment(data BugRecord) (BugRecord, error) {
+	conn, err := b.getConnection()
+	if err != nil {
+		return data, bugLog.Errorf("bug increment connection: %+v", err)
+	}
+	defer b.closeConnection(conn)
+
+	tx, err := conn.Begin(b.Context)
+	if err != nil {
+		return data, bugLog.Errorf("bug increment begin: %+v", err)
+	}
+	defer func() {
+		if err := tx.Rollback(b.Context); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			bugLog.Debugf("rollback: %+v", err)
+		}
+	}()
+
+	now := time.Now()
+	if err := tx.QueryRow(b.Context,
+		"UPDATE bug SET times_reported = times_reported + 1, last_reported = $1, "+
+			"last_release = COALESCE(NULLIF($2, ''), last_release), last_commit = COALESCE(NULLIF($3, ''), last_commit) "+
+			"WHERE id = $4 RETURNING times_reported, first_reported, last_reported, COALESCE(last_release, ''), COALESCE(last_commit, '')",
+		now,
+		data.LastRelease,
+		data.LastCommit,
+		data.ID).Scan(
+		&data.TimesReportedNumber,
+		&data.FirstReportedTime,
+		&data.LastReportedTime,
+		&data.LastRelease,
+		&data.LastCommit); err != nil {
+		return data, bugLog.Errorf("bug increment update: %+v", err)
+	}
+
+	if _, err := tx.Exec(b.Context,
+		"INSERT INTO bug_occurrence (bug_id, day, occurrences) VALUES ($1, $2, 1) ON CONFLICT (bug_id, day) DO UPDATE SET occurrences = bug_occurrence.occurrences + 1",
+		data.ID,
+		now.UTC().Format(OccurrenceDayFormat)); err != nil {
+		return data, bugLog.Errorf("bug increment occurrence: %+v", err)
+	}
+
+	minuteCutoff, hourCutoff := rateCutoffs(b.Config, now)
+	for _, rate := range []struct {
+		span   string
+		bucket string
+		cutoff string
+	}{
+		{rateMinute, now.UTC().Format(RateMinuteFormat), minuteCutoff},
+		{rateHour, now.UTC().Format(RateHourFormat), hourCutoff},
+	} {
+		if _, err := tx.Exec(b.Context,
+			"INSERT INTO bug_rate (bug_id, span, bucket, occurrences) VALUES ($1, $2, $3, 1) ON CONFLICT (bug_id, span, bucket) DO UPDATE SET occurrences = bug_rate.occurrences + 1",
+			data.ID,
+			rate.span,
+			rate.bucket); err != nil {
+			return data, bugLog.Errorf("bug increment rate: %+v", err)
+		}
+		if _, err := tx.Exec(b.Context,
+			"DELETE FROM bug_rate WHERE bug_id = $1 AND span = $2 AND bucket < $3",
+			data.ID,
+			rate.span,
+			rate.cutoff); err != nil {
+			return data, bugLog.Errorf("bug increment prune rate: %+v", err)
+		}
+	}
+
+	occurrences, err := b.occurrences(tx, data.ID)
+	if err != nil {
+		return data, bugLog.Errorf("bug increment occurrences: %+v", err)
+	}
+	minutes, hours, err := b.rates(tx, data.ID)
+	if err != nil {
+		return data, bugLog.Errorf("bug increment rates: %+v", err)
+	}
+
+	if err := tx.Commit(b.Context); err != nil {
+		return data, bugLog.Errorf("bug increment commit: %+v", err)
+	}
+
+	data.Occurrences = occurrences
+	data.Minutes = minutes
+	data.Hours = hours
+	data.TimesReported = strconv.Itoa(data.TimesReportedNumber)
+	data.FirstReported = data.FirstReportedTime.Format(b.Config.DateFormat)
+	data.LastReported = data.LastReportedTime.Format(b.Config.DateFormat)
+
+	return data, nil
+}
+
+// querier is the part of a connection or a transaction occurrences needs
+type querier interface {
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+}
+
+func (b PostgresBugStorage) occurrences(q querier, id string) (map[string]int, error) {
+	occurrences := map[string]int{}
+
+	rows, err := q.Query(b.Context,
+		"SELECT day, occurrences FROM bug_occurrence WHERE bug_id = $1",
+		id)
+	if err != nil {
+		return occurrences, bugLog.Errorf("occurrences query: %+v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var day string
+		var count int
+		if err := rows.Scan(&day, &count); err != nil {
+			return occurrences, bugLog.Errorf("occurrences scan: %+v", err)
+		}
+		occurrences[day] = count
+	}
+
+	return occurrences, rows.Err()
+}
+
+// rateMinute and rateHour are the spans of bug_rate, a bucket is one of the rate formats
+const (
+	rateMinute = "minute"
+	rateHour   = "hour"
+)
+
+func (b PostgresBugStorage) rates(q querier, id string) (map[string]int, map[string]int, error) {
+	minutes, hours := map[string]int{}, map[string]int{}
+
+	rows, err := q.Query(b.Context,
+		"SELECT span, bucket, occurrences FROM bug_rate WHERE bug_id = $1",
+		id)
+	if err != nil {
+		return minutes, hours, bugLog.Errorf("rates query: %+v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var span, bucket string
+		var count int
+		if err := rows.Scan(&span, &bucket, &count); err != nil {
+			return minutes, hours, bugLog.Errorf("rates scan: %+v", err)
+		}
+		if span == rateMinute {
+			minutes[bucket] = count
+		} else {
+			hours[bucket] = count
+		}
+	}
+
+	return minutes, hours, rows.Err()
+}
+
+// Migrate brings the schema up to date, and gives every record stored before fingerprinting its fingerprint so Find can reach it,
+// records the new fingerprint makes the same bug are merged before the unique agent/fingerprint index that keeps a bug to one record
+func (b PostgresBugStorage) Migrate() (int, error) {
+	migrated := 0
+
+	conn, err := b.getConnection()
+	if err != nil {
+		return migrated, bugLog.Errorf("bug migrate connection: %+v", err)
+	}
+	defer b.closeConnection(conn)
+
+	for _, stmt := range []string{
+		"CREATE INDEX IF NOT EXISTS idx_bug_fingerprint ON bug(agent_id, fingerprint)",
+		"ALTER TABLE bug ADD COLUMN IF NOT EXISTS file TEXT",
+		"ALTER TABLE bug ADD COLUMN IF NOT EXISTS line VARCHAR(100)",
+		"ALTER TABLE bug ADD COLUMN IF NOT EXISTS remote_link TEXT",
+		"ALTER TABLE bug ADD COLUMN IF NOT EXISTS ticket_system VARCHAR(100)",
+		"ALTER TABLE bug ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'open'",
+		"ALTER TABLE bug ADD COLUMN IF NOT EXISTS status_changed TIMESTAMP",
+		"ALTER TABLE bug ADD COLUMN IF NOT EXISTS snooze_until TIMESTAMP",
+		"ALTER TABLE bug ADD COLUMN IF NOT EXISTS snooze_until_times INT NOT NULL DEFAULT 0",
+		"ALTER TABLE bug ADD COLUMN IF NOT EXISTS first_release VARCHAR(100)",
+		"ALTER TABLE bug ADD COLUMN IF NOT EXISTS last_release VARCHAR(100)",
+		"ALTER TABLE bug ADD COLUMN IF NOT EXISTS last_commit VARCHAR(100)",
+		"ALTER TABLE bug ADD COLUMN IF NOT EXISTS environment VARCHAR(100)",
+		"ALTER TABLE bug ADD COLUMN IF NOT EXISTS last_spike TIMESTAMP",
+		"ALTER TABLE bug ADD COLUMN IF NOT EXISTS assignee VARCHAR(200)",
+		"UPDATE bug SET environment = '' WHERE environment IS NULL",
+		"CREATE TABLE IF NOT EXISTS bug_rate (bug_id VARCHAR(100) NOT NULL, span VARCHAR(10) NOT NULL, bucket VARCHAR(20) NOT NULL, " +
+			"occurrences INT NOT NULL DEFAULT 0, PRIMARY KEY (bug_id, span, bucket), " +
+			"CONSTRAINT fk_bug_rate_bug_id FOREIGN KEY (bug_id) REFERENCES bug(id) ON DELETE CASCADE)",
+		"CREATE TABLE IF NOT EXISTS bug_release (bug_id VARCHAR(100) NOT NULL, release VARCHAR(100) NOT NULL, first_seen TIMESTAMP NOT NULL, " +
+			"PRIMARY KEY (bug_id, release), " +
+			"CONSTRAINT fk_bug_release_bug_id FOREIGN KEY (bug_id) REFERENCES bug(id) ON DELETE CASCADE)",
+		"INSERT INTO bug_release (bug_id, release, first_seen) SELECT id, first_release, COALESCE(first_reported, now()) FROM bug " +
+			"WHERE first_release IS NOT NULL AND first_release <> '' ON CONFLICT DO NOTHING",
+		"INSERT INTO bug_release (bug_id, release, first_seen) SELECT id, last_release, COALESCE(last_reported, now()) FROM bug " +
+			"WHERE last_release IS NOT NULL AND last_release <> '' ON CONFLICT DO NOTHING",
+
+		// logs, accounts and comms
+		"ALTER TABLE log ADD COLUMN IF NOT EXISTS expires TIMESTAMP NULL",
+		"ALTER TABLE log ADD COLUMN IF NOT EXISTS frames TEXT NULL",
+		"ALTER TABLE log ADD COLUMN IF NOT EXISTS fields JSONB NULL",
+		"ALTER TABLE log ADD COLUMN IF NOT EXISTS environment VARCHAR(100)",
+		"ALTER TABLE log ADD COLUMN IF NOT EXISTS received TIMESTAMP",
+		"UPDATE log SET received = logged WHERE received IS NULL",
+		"CREATE INDEX IF NOT EXISTS idx_log_received ON log(agent_id, received)",
+		"CREATE INDEX IF NOT EXISTS idx_log_expires ON log(expires)",
+		"CREATE INDEX IF NOT EXISTS idx_log_fields ON log USING GIN (fields)",
+		"ALTER TABLE account ADD COLUMN IF NOT EXISTS name VARCHAR(100)",
+		"ALTER TABLE account ADD COLUMN IF NOT EXISTS email VARCHAR(255)",
+		"ALTER TABLE account ADD COLUMN IF NOT EXISTS account_secret VARCHAR(100)",
+		"ALTER TABLE account ADD COLUMN IF NOT EXISTS date_created VARCHAR(100)",
+		"ALTER TABLE account ADD COLUMN IF NOT EXISTS retention TEXT NULL",
+		"ALTER TABLE account ADD COLUMN IF NOT EXISTS rules TEXT NULL",
+		"ALTER TABLE agent ADD COLUMN IF NOT EXISTS identifier VARCHAR(100)",
+		// agents made before the identifier get a uuid, the same as a new agent, without needing pgcrypto
+		"UPDATE agent SET identifier = md5(random()::text || id::text)::uuid::text WHERE identifier IS NULL",
+		"ALTER TABLE comms_details ADD COLUMN IF NOT EXISTS last_digest TIMESTAMP",
+		"CREATE TABLE IF NOT EXISTS comms_digest (id SERIAL, agent_id INT NOT NULL, bug_id VARCHAR(100), level VARCHAR(100), " +
+			"file TEXT, line VARCHAR(100), link TEXT, times_reported INT NOT NULL DEFAULT 0, regressed BOOLEAN NOT NULL DEFAULT FALSE, " +
+			"reported TIMESTAMP NOT NULL, PRIMARY KEY (id), " +
+			"CONSTRAINT fk_comms_digest_agent_id FOREIGN KEY (agent_id) REFERENCES agent(id))",
+		"CREATE TABLE IF NOT EXISTS comms_notification (id SERIAL, ref VARCHAR(100), agent_id INT, bug_id VARCHAR(100) NOT NULL, " +
+			"channel VARCHAR(200), kind VARCHAR(20), rule VARCHAR(200), state VARCHAR(200), sent TIMESTAMP NOT NULL, PRIMARY KEY (id))",
+		"CREATE INDEX IF NOT EXISTS idx_comms_notification_bug ON comms_notification(bug_id, sent)",
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_comms_notification_ref ON comms_notification(ref)",
+		"ALTER TABLE comms_notification ADD COLUMN IF NOT EXISTS follows INT",
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_comms_notification_follows ON comms_notification(bug_id, channel, kind, follows)",
+	} {
+		if _, err := conn.Exec(b.Context, stmt); err != nil {
+			return migrated, bugLog.Errorf("bug migrate schema: %+v", err)
+		}
+	}
+
+	rows, err := conn.Query(b.Context,
+		"SELECT id, agent_id, COALESCE(environment, ''), COALESCE(fingerprint, ''), full_details, first_reported FROM bug")
+	if err != nil {
+		return migrated, bugLog.Errorf("bug migrate query: %+v", err)
+	}
+
+	brs := []BugRecord{}
+	fingerprints := map[string]string{}
+	for rows.Next() {
+		bri := BugRecord{}
+		var full string
+		var first *time.Time
+		if err := rows.Scan(&bri.ID, &bri.AgentID, &bri.Environment, &bri.Fingerprint, &full, &first); err != nil {
+			rows.Close()
+			return migrated, bugLog.Errorf("bug migrate scan: %+v", err)
+		}
+		if first != nil {
+			bri.FirstReportedTime = *first
+		}
+
+		var details interface{}
+		if err := json.Unmarshal([]byte(full), &details); err != nil {
+			rows.Close()
+			return migrated, bugLog.Errorf("bug migrate unmarshal: %+v", err)
+		}
+		if fp := GenerateFingerprint(rawFromFull(details)); fp != bri.Fingerprint {
+			fingerprints[bri.ID] = fp
+			bri.Fingerprint = fp
+		}
+		brs = append(brs, bri)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return migrated, bugLog.Errorf("bug migrate rows: %+v", err)
+	}
+
+	for id, fp := range fingerprints {
+		if _, err := conn.Exec(b.Context,
+			"UPDATE bug SET fingerprint = $1 WHERE id = $2",
+			fp,
+			id); err != nil {
+			return migrated, bugLog.Errorf("bug migrate update: %+v", err)
+		}
+		migrated++
+	}
+
+	// the unique index can only go on once no two bugs share a fingerprint
+	for _, group := range duplicateRecords(brs) {
+		for _, dup := range group[1:] {
+			if err := b.mergeBug(conn, group[0].ID, dup.ID); err != nil {
+				return migrated, bugLog.Errorf("bug migrate merge: %+v", err)
+			}
+			migrated++
+		}
+	}
+	if _, err := conn.Exec(b.Context,
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_bug_agent_environment_fingerprint ON bug(agent_id, environment, fingerprint)"); err != nil {
+		return migrated, bugLog.Errorf("bug migrate index: %+v", err)
+	}
+
+	return migrated, nil
+}
+
+// mergeBug folds dup into keep in one transaction, the counts, days, rates and releases add up, the reports span both,
+// keep takes dup's ticket when it has none, then dup goes
+func (b PostgresBugStorage) mergeBug(conn *pgx.Conn, keep, dup string) error {
+	tx, err := conn.Begin(b.Context)
+	if err != nil {
+		return bugLog.Errorf("bug mergeBug begin: %+v", err)
+	}
+	defer func() {
+		if err := tx.Rollback(b.Context); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			bugLog.Debugf("rollback: %+v", err)
+		}
+	}()
+
+	for _, stmt := range []string{
+		"UPDATE bug SET times_reported = bug.times_reported + d.times_reported, " +
+			"first_reported = LEAST(bug.first_reported, d.first_reported), " +
+			"last_reported = GREATEST(bug.last_reported, d.last_reported), " +
+			"first_release = CASE WHEN d.first_reported < bug.first_reported OR bug.first_release IS NULL OR bug.first_release = '' THEN COALESCE(NULLIF(d.first_release, ''), bug.first_release) ELSE bug.first_release END, " +
+			"last_release = CASE WHEN d.last_reported > bug.last_reported THEN COALESCE(NULLIF(d.last_release, ''), bug.last_release) ELSE bug.last_release END, " +
+			"last_commit = CASE WHEN d.last_reported > bug.last_reported THEN COALESCE(NULLIF(d.last_commit, ''), bug.last_commit) ELSE bug.last_commit END, " +
+			"remote_link = COALESCE(NULLIF(bug.remote_link, ''), d.remote_link), " +
+			"ticket_system = CASE WHEN bug.remote_link IS NULL OR bug.remote_link = '' THEN d.ticket_system ELSE bug.ticket_system END " +
+			"FROM bug d WHERE bug.id = $1 AND d.id = $2",
+		"INSERT INTO bug_occurrence (bug_id, day, occurrences) SELECT $1::text, day, occurrences FROM bug_occurrence WHERE bug_id = $2 " +
+			"ON CONFLICT (bug_id, day) DO UPDATE SET occurrences = bug_occurrence.occurrences + EXCLUDED.occurrences",
+		"INSERT INTO bug_rate (bug_id, span, bucket, occurrences) SELECT $1::text, span, bucket, occurrences FROM bug_rate WHERE bug_id = $2 " +
+			"ON CONFLICT (bug_id, span, bucket) DO UPDATE SET occurrences = bug_rate.occurrences + EXCLUDED.occurrences",
+		"INSERT INTO bug_release (bug_id, release, first_seen) SELECT $1::text, release, first_seen FROM bug_release WHERE bug_id = $2 " +
+			"ON CONFLICT (bug_id, release) DO UPDATE SET first_seen = LEAST(bug_release.first_seen, EXCLUDED.first_seen)",
+		"UPDATE ticket SET hash = k.hash FROM bug k, bug d WHERE k.id = $1 AND d.id = $2 AND ticket.hash = d.hash " +
+			"AND NOT EXISTS (SELECT 1 FROM ticket t WHERE t.hash = k.hash)",
+	} {
+		if _, err := tx.Exec(b.Context, stmt, keep, dup); err != nil {
+			return bugLog.Errorf("bug mergeBug exec: %+v", err)
+		}
+	}
+	if _, err := tx.Exec(b.Context, "DELETE FROM bug WHERE id = $1", dup); err != nil {
+		return bugLog.Errorf("bug mergeBug delete: %+v", err)
+	}
+
+	if err := tx.Commit(b.Context); err != nil {
+		return bugLog.Errorf("bug mergeBug commit: %+v", err)
+	}
+
+	return nil
+}
+
+// List does the filtering in the query, paging is an offset into the rows ordered by last report
+// nolint: gocyclo
+func (b PostgresBugStorage) List(filter BugFilter) ([]BugRecord, string, error) {
+	brs := []BugRecord{}
+
+	offset, err := decodeOffsetCursor(filter.Cursor)
+	if err != nil {
+		return brs, "", bugLog.Errorf("bug list cursor: %+v", err)
+	}
+
+	conn, err := b.getConnection()
+	if err != nil {
+		return brs, "", bugLog.Errorf("bug list connection: %+v", err)
+	}
+	defer b.closeConnection(conn)
+
+	where := []string{"agent_id = $1"}
+	args := []interface{}{filter.AgentID}
+	add := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		where = append(where, fmt.Sprintf(cond, len(args)))
+	}
+	if filter.Level != "" {
+		add("level = $%d", filter.Level)
+	}
+	if filter.Environment != "" {
+		add("environment = $%d", filter.Environment)
+	}
+	if filter.File != "" {
+		add("file = $%d", filter.File)
+	}
+	if filter.Status != "" {
+		add("status = $%d", filter.Status)
+	}
+	if !filter.FirstSeenFrom.IsZero() {
+		add("first_reported >= $%d", filter.FirstSeenFrom)
+	}
+	if !filter.FirstSeenTo.IsZero() {
+		add("first_reported <= $%d", filter.FirstSeenTo)
+	}
+	if !filter.LastSeenFrom.IsZero() {
+		add("last_reported >= $%d", filter.LastSeenFrom)
+	}
+	if !filter.LastSeenTo.IsZero() {
+		add("last_reported <= $%d", filter.LastSeenTo)
+	}
+	if filter.MinTimesReported > 0 {
+		add("times_reported >= $%d", filter.MinTimesReported)
+	}
+	if filter.MaxTimesReported > 0 {
+		add("times_reported <= $%d", filter.MaxTimesReported)
+	}
+
+	// one extra row says whether there is another page
+	args = append(args, filter.Limit+1, offset)
+	rows, err := conn.Query(b.Context,
+		fmt.Sprintf("SELECT %s FROM bug WHERE %s ORDER BY last_reported DESC, id LIMIT $%d OFFSET $%d",
+			bugColumns,
+			strings.Join(where, " AND "),
+			len(args)-1,
+			len(args)),
+		args...)
+	if err != nil {
+		return brs, "", bugLog.Errorf("bug list query: %+v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		bri, err := b.scanRecord(rows)
+		if err != nil {
+			return brs, "", bugLog.Errorf("bug list scan: %+v", err)
+		}
+		brs = append(brs, bri)
+	}
+	if err := rows.Err(); err != nil {
+		return brs, "", bugLog.Errorf("bug list rows: %+v", err)
+	}
+
+	cursor := ""
+	if len(brs) > filter.Limit {
+		brs = brs[:filter.Limit]
+		cursor = encodeOffsetCursor(offset + filter.Limit)
+	}
+
+	return brs, cursor, nil
+}
+
+func (b PostgresBugStorage) Get(agentID, id string) (BugRecord, error) {
+	conn, err := b.getConnection()
+	if err != nil {
+		return BugRecord{}, bugLog.Errorf("bug get connection: %+v", err)
+	}
+	defer b.closeConnection(conn)
+
+	bri, err := b.scanRecord(conn.QueryRow(b.Context,
+		"SELECT "+bugColumns+" FROM bug WHERE id = $1 AND agent_id = $2",
+		id,
+		agentID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return BugRecord{}, ErrBugNotFound
+		}
+		return BugRecord{}, bugLog.Errorf("bug get scan: %+v", err)
+	}
+
+	occurrences, err := b.occurrences(conn, id)
+	if err != nil {
+		return bri, bugLog.Errorf("bug get occurrences: %+v", err)
+	}
+	bri.Occurrences = occurrences
+
+	return bri, nil
+}
+
+func (b PostgresBugStorage) LinkTicket(data BugRecord) error {
+	conn, err := b.getConnection()
+	if err != nil {
+		return bugLog.Errorf("bug linkTicket connection: %+v", err)
+	}
+	defer b.closeConnection(conn)
+
+	if _, err := conn.Exec(b.Context,
+		"UPDATE bug SET remote_link = $1, ticket_system = $2 WHERE id = $3",
+		data.RemoteLink,
+		data.TicketSystem,
+		data.ID); err != nil {
+		return bugLog.Errorf("bug linkTicket exec: %+v", err)
+	}
+
+	return nil
+}
+
+func (b PostgresBugStorage) UpdateStatus(data BugRecord) error {
+	conn, err := b.getConnection()
+	if err != nil {
+		return bugLog.Errorf("bug updateStatus connection: %+v", err)
+	}
+	defer b.closeConnection(conn)
+
+	if _, err := conn.Exec(b.Context,
+		"UPDATE bug SET status = $1, status_changed = $2, snooze_until = $3, snooze_until_times = $4, assignee = NULLIF($5, '') WHERE id = $6",
+		data.CurrentStatus(),
+		nullTime(data.StatusChanged),
+		nullTime(data.SnoozeUntil),
+		data.SnoozeUntilTimes,
+		data.Assignee,
+		data.ID); err != nil {
+		return bugLog.Errorf("bug updateStatus exec: %+v", err)
+	}
+
+	return nil
+}
+
+// MarkSpike notes the bug's spike has been sent, the conditional UPDATE means two workers can't both send it
+func (b PostgresBugStorage) MarkSpike(id string, now time.Time, cooldown time.Duration) (bool, error) {
+	conn, err := b.getConnection()
+	if err != nil {
+		return false, bugLog.Errorf("bug markSpike connection: %+v", err)
+	}
+	defer b.closeConnection(conn)
+
+	tag, err := conn.Exec(b.Context,
+		"UPDATE bug SET last_spike = $1 WHERE id = $2 AND (last_spike IS NULL OR last_spike < $3)",
+		now,
+		id,
+		now.Add(-cooldown))
+	if err != nil {
+		return false, bugLog.Errorf("bug markSpike exec: %+v", err)
+	}
+
+	return tag.RowsAffected() == 1, nil
+}
+
+// MarkRelease keeps the release in bug_release, it is false when the bug had already been seen in it
+func (b PostgresBugStorage) MarkRelease(id, release string) (bool, error) {
+	conn, err := b.getConnection()
+	if err != nil {
+		return false, bugLog.Errorf("bug markRelease connection: %+v", err)
+	}
+	defer b.closeConnection(conn)
+
+	tag, err := conn.Exec(b.Context,
+		"INSERT INTO bug_release (bug_id, release, first_seen) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING",
+		id,
+		release,
+		time.Now())
+	if err != nil {
+		return false, bugLog.Errorf("bug markRelease exec: %+v", err)
+	}
+
+	return tag.RowsAffected() == 1, nil
+}
+
+// nullTime stores an unset time as NULL rather than year one
+func nullTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
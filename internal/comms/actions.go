@@ -0,0 +1,239 @@
+package comms
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/bugfixes/celeste/internal/agent"
+	"github.com/bugfixes/celeste/internal/config"
+	bugLog "github.com/bugfixes/go-bugfixes/logs"
+	"github.com/slack-go/slack"
+)
+
+const (
+	ActionResolve = "resolve"
+	ActionIgnore  = "ignore"
+	ActionSnooze  = "snooze"
+	ActionAssign  = "assign"
+)
+
+// slackActionPrefix marks the buttons that are ours, the action is the rest of the button's id
+const slackActionPrefix = "celeste_"
+
+// slackRequestLimit is the most of a Slack request that is read, a button press is a few kilobytes
+const slackRequestLimit = 1 << 20
+
+// slackResponseTimeout is how long posting what was done back to Slack can take
+const slackResponseTimeout = 30 * time.Second
+
+// BugAction is a button pressed on a bug's message, User is who pressed it, Email is only looked up to assign the bug
+type BugAction struct {
+	Agent  agent.Agent
+	BugID  string
+	Action string
+	User   string
+	Email  string
+}
+
+//go:generate mockery --name=BugActions
+type BugActions interface {
+	// BugAction does what the button asks to the bug and its remote ticket, and says what was done,
+	// the bug package does it, comms can't import it
+	BugAction(action BugAction) (string, error)
+}
+
+// SlackActions are the buttons on Slack messages, Slack sends them to ActionHandler
+type SlackActions struct {
+	Config config.Config
+	Bugs   BugActions
+}
+
+func NewSlackActions(c config.Config) *SlackActions {
+	return &SlackActions{
+		Config: c,
+	}
+}
+
+func errorReport(w http.ResponseWriter, status int, textError string, wrappedError error) {
+	bugLog.Debugf("comms errorReport: %+v", wrappedError)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(struct {
+		Error     string
+		FullError string
+	}{
+		Error:     textError,
+		FullError: fmt.Sprintf("%+v", wrappedError),
+	}); err != nil {
+		bugLog.Debugf("comms errorReport json: %+v", err)
+	}
+}
+
+func (sa SlackActions) signingSecret() (string, error) {
+	if sa.Config.Slack.SigningSecret != "" {
+		return sa.Config.Slack.SigningSecret, nil
+	}
+
+	return config.GetSecret(sa.Config.SecretsClient, "slack_signing_secret")
+}
+
+// verifySlackRequest checks the body was signed with the app's secret in the last five minutes
+func verifySlackRequest(secret string, header http.Header, body []byte) error {
+	sv, err := slack.NewSecretsVerifier(header, secret)
+	if err != nil {
+		return bugLog.Errorf("verifySlackRequest newSecretsVerifier: %+v", err)
+	}
+	if _, err := sv.Write(body); err != nil {
+		return bugLog.Errorf("verifySlackRequest write: %+v", err)
+	}
+	if err := sv.Ensure(); err != nil {
+		return bugLog.Errorf("verifySlackRequest ensure: %+v", err)
+	}
+
+	return nil
+}
+
+// slackUserEmail looks the user up with the channel's token, it needs the users:read.email scope
+func (sa SlackActions) slackUserEmail(a agent.Agent, userID string) (string, error) {
+	creds, err := NewCommsStorage(sa.Config).FetchCredentials(a)
+	if err != nil {
+		return "", bugLog.Errorf("slackUserEmail fetchCredentials: %+v", err)
+	}
+
+	s := NewSlack(sa.Config)
+	if err := s.ParseCredentials(creds); err != nil {
+		return "", bugLog.Errorf("slackUserEmail parseCredentials: %+v", err)
+	}
+	if err := s.Connect(); err != nil {
+		return "", bugLog.Errorf("slackUserEmail connect: %+v", err)
+	}
+	user, err := s.Client.GetUserInfoContext(s.Context, userID)
+	if err != nil {
+		return "", bugLog.Errorf("slackUserEmail getUserInfo: %+v", err)
+	}
+	if user.Profile.Email == "" {
+		return "", bugLog.Errorf("slackUserEmail: %s has no email", userID)
+	}
+
+	return user.Profile.Email, nil
+}
+
+// slackAct does what the pressed button asks, the button's value is the ref of the notification it was sent with,
+// which finds the bug and its agent
+func (sa SlackActions) slackAct(callback slack.InteractionCallback, pressed *slack.BlockAction) (string, error) {
+	n, err := NewCommsStorage(sa.Config).Notification(pressed.Value)
+	if err != nil {
+		if errors.Is(err, ErrNotificationNotFound) {
+			return "", err
+		}
+		return "", bugLog.Errorf("slackAct notification: %+v", err)
+	}
+
+	action := BugAction{
+		Agent:  n.Agent,
+		BugID:  n.BugID,
+		Action: strings.TrimPrefix(pressed.ActionID, slackActionPrefix),
+		User:   callback.User.Name,
+	}
+	if action.Action == ActionAssign {
+		email, err := sa.slackUserEmail(n.Agent, callback.User.ID)
+		if err != nil {
+			return "", bugLog.Errorf("slackAct user email: %+v", err)
+		}
+		action.Email = email
+	}
+
+	done, err := sa.Bugs.BugAction(action)
+	if err != nil {
+		return "", bugLog.Errorf("slackAct bugAction: %+v", err)
+	}
+
+	return done, nil
+}
+
+// slackRespond posts what was done back in the channel, a failure is only shown to who pressed the button
+func (sa SlackActions) slackRespond(callback slack.InteractionCallback, pressed *slack.BlockAction) {
+	msg := &slack.WebhookMessage{
+		ResponseType: slack.ResponseTypeInChannel,
+	}
+	done, err := sa.slackAct(callback, pressed)
+	switch {
+	case errors.Is(err, ErrNotificationNotFound):
+		msg.Text = "the bug this message was sent for can't be found"
+		msg.ResponseType = slack.ResponseTypeEphemeral
+	case err != nil:
+		msg.Text = fmt.Sprintf("couldn't %s the bug, try again", strings.TrimPrefix(pressed.ActionID, slackActionPrefix))
+		msg.ResponseType = slack.ResponseTypeEphemeral
+	default:
+		msg.Text = fmt.Sprintf("<@%s> %s", callback.User.ID, done)
+	}
+
+	if callback.ResponseURL == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), slackResponseTimeout)
+	defer cancel()
+	if err := slack.PostWebhookContext(ctx, callback.ResponseURL, msg); err != nil {
+		bugLog.Debugf("slackRespond postWebhook: %+v", err)
+	}
+}
+
+// ActionHandler is the Slack app's interactivity url, only signed requests are acted on,
+// Slack wants an answer within three seconds, so the press is acknowledged straight away,
+// and what was done is posted back in the channel once it has been
+func (sa SlackActions) ActionHandler(w http.ResponseWriter, r *http.Request) {
+	defer func() {
+		if err := r.Body.Close(); err != nil {
+			bugLog.Debugf("actionHandler body close: %+v", err)
+		}
+	}()
+
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, slackRequestLimit))
+	if err != nil {
+		errorReport(w, http.StatusBadRequest, "actionHandler read", err)
+		return
+	}
+
+	secret, err := sa.signingSecret()
+	if err != nil {
+		errorReport(w, http.StatusInternalServerError, "actionHandler signing secret", err)
+		return
+	}
+	if err := verifySlackRequest(secret, r.Header, body); err != nil {
+		errorReport(w, http.StatusUnauthorized, "actionHandler invalid signature", err)
+		return
+	}
+
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		errorReport(w, http.StatusBadRequest, "actionHandler parse", err)
+		return
+	}
+	callback := slack.InteractionCallback{}
+	if err := json.Unmarshal([]byte(form.Get("payload")), &callback); err != nil {
+		errorReport(w, http.StatusBadRequest, "actionHandler payload", err)
+		return
+	}
+
+	var pressed *slack.BlockAction
+	for _, ba := range callback.ActionCallback.BlockActions {
+		if strings.HasPrefix(ba.ActionID, slackActionPrefix) {
+			pressed = ba
+			break
+		}
+	}
+	// the link to the ticket is a button too, Slack still tells us it was pressed
+	if callback.Type == slack.InteractionTypeBlockActions && pressed != nil {
+		go sa.slackRespond(callback, pressed)
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,189 @@
+package comms_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bugfixes/celeste/internal/agent"
+	"github.com/bugfixes/celeste/internal/comms"
+	"github.com/bugfixes/celeste/internal/comms/mocks"
+	"github.com/bugfixes/celeste/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func slackRequest(t *testing.T, secret string, payload map[string]interface{}) *http.Request {
+	t.Helper()
+
+	p, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatalf("payload marshal err: %+v", err)
+	}
+	body := "payload=" + url.QueryEscape(string(p))
+	ts := fmt.Sprint(time.Now().Unix())
+	mac := hmac.New(sha256.New, []byte(secret))
+	if _, err := mac.Write([]byte(fmt.Sprintf("v0:%s:%s", ts, body))); err != nil {
+		t.Fatalf("hmac err: %+v", err)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/comms/slack/actions", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Set("X-Slack-Request-Timestamp", ts)
+	r.Header.Set("X-Slack-Signature", "v0="+hex.EncodeToString(mac.Sum(nil)))
+
+	return r
+}
+
+func TestSlackActions_ActionHandler(t *testing.T) {
+	t.Cleanup(resetMemory)
+
+	c := config.Config{
+		Storage: config.Storage{
+			StorageBackend: config.StorageMemory,
+		},
+		Slack: config.Slack{
+			SigningSecret: "signing",
+		},
+	}
+	a := agent.Agent{
+		Credentials: agent.Credentials{
+			Key:    "slack-actions",
+			Secret: "slack-actions",
+		},
+	}
+	if err := comms.NewCommsStorage(c).RecordNotification(comms.Notification{
+		Ref:     "ref-resolve",
+		Agent:   a,
+		BugID:   "bug-resolve",
+		Channel: "slack:#bugs",
+		Kind:    comms.KindTicket,
+		Sent:    time.Now(),
+	}); err != nil {
+		t.Fatalf("recordNotification err: %+v", err)
+	}
+
+	posted := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		msg := struct {
+			Text         string `json:"text"`
+			ResponseType string `json:"response_type"`
+		}{}
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			t.Errorf("response decode err: %+v", err)
+		}
+		posted <- fmt.Sprintf("%s: %s", msg.ResponseType, msg.Text)
+	}))
+	defer srv.Close()
+
+	press := func(actionID, value string) map[string]interface{} {
+		return map[string]interface{}{
+			"type":         "block_actions",
+			"user":         map[string]string{"id": "U1", "name": "bob"},
+			"response_url": srv.URL,
+			"actions": []map[string]string{
+				{"type": "button", "block_id": "actions", "action_id": actionID, "value": value},
+			},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		secret string
+		press  map[string]interface{}
+		status int
+		action *comms.BugAction
+		err    error
+		posted string
+	}{
+		{
+			name:   "resolve",
+			secret: "signing",
+			press:  press("celeste_resolve", "ref-resolve"),
+			status: http.StatusOK,
+			action: &comms.BugAction{
+				Agent:  a,
+				BugID:  "bug-resolve",
+				Action: comms.ActionResolve,
+				User:   "bob",
+			},
+			posted: "in_channel: <@U1> resolved the bug",
+		},
+		{
+			name:   "bug action failed",
+			secret: "signing",
+			press:  press("celeste_ignore", "ref-resolve"),
+			status: http.StatusOK,
+			action: &comms.BugAction{
+				Agent:  a,
+				BugID:  "bug-resolve",
+				Action: comms.ActionIgnore,
+				User:   "bob",
+			},
+			err:    errors.New("jira is down"),
+			posted: "ephemeral: couldn't ignore the bug, try again",
+		},
+		{
+			name:   "not signed by slack",
+			secret: "forged",
+			press:  press("celeste_resolve", "ref-resolve"),
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "unknown notification",
+			secret: "signing",
+			press:  press("celeste_ignore", "ref-unknown"),
+			status: http.StatusOK,
+			posted: "ephemeral: the bug this message was sent for can't be found",
+		},
+		{
+			name:   "ticket link",
+			secret: "signing",
+			press:  press("open_ticket", ""),
+			status: http.StatusOK,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			bugs := &mocks.BugActions{}
+			if test.action != nil {
+				bugs.On("BugAction", *test.action).Return("resolved the bug", test.err).Once()
+			}
+
+			sa := comms.NewSlackActions(c)
+			sa.Bugs = bugs
+			w := httptest.NewRecorder()
+			sa.ActionHandler(w, slackRequest(t, test.secret, test.press))
+			if passed := assert.Equal(t, test.status, w.Code); !passed {
+				t.Errorf("actionHandler expect: %v, got: %v, %s", test.status, w.Code, w.Body.String())
+			}
+
+			if test.posted != "" {
+				select {
+				case got := <-posted:
+					if passed := assert.Equal(t, test.posted, got); !passed {
+						t.Errorf("actionHandler posted expect: %v, got: %v", test.posted, got)
+					}
+				case <-time.After(time.Second):
+					t.Errorf("actionHandler posted nothing")
+				}
+			}
+
+			// the action is done after the handler has answered, it has been once something is posted
+			bugs.AssertExpectations(t)
+			if test.action == nil {
+				bugs.AssertNotCalled(t, "BugAction", mock.Anything)
+			}
+		})
+	}
+}
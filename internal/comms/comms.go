@@ -1,20 +1,77 @@
 package comms
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/bugfixes/celeste/internal/agent"
 	"github.com/bugfixes/celeste/internal/config"
 	bugLog "github.com/bugfixes/go-bugfixes/logs"
+	"github.com/google/uuid"
 )
 
 type Credentials struct {
 	AgentID string `json:"agent_id"`
 }
 
+const (
+	KindTicket = "ticket"
+	KindSpike  = "spike"
+	KindDigest = "digest"
+)
+
+// CommsPackage is what gets sent, the bug's details are laid out by its Template,
+// a spike's Message says how far over its usual rate the bug is, a digest's Message is the whole summary
 type CommsPackage struct {
 	Agent        agent.Agent
+	Kind         string
 	Message      string
 	Link         string
 	TicketSystem string
+
+	BugID         string
+	BugTitle      string
+	Level         string
+	Environment   string
+	File          string
+	Line          string
+	TimesReported int
+	Regressed     bool
+	FirstSeen     time.Time
+	LastSeen      time.Time
+
+	// Rule is what decided the bug is sent, it isn't sent on a channel again within the Cooldown while its State stays the same
+	Rule     string
+	State    string
+	Cooldown time.Duration
+
+	// DigestTitle is the headline of a digest
+	DigestTitle string
+
+	// Ref is the notification's, set when it is sent, the buttons on the message carry it back
+	Ref string
+}
+
+// Title is the headline, a ticket's is the bug's first line
+func (cp CommsPackage) Title() string {
+	switch cp.Kind {
+	case KindSpike:
+		if title := headline(cp.BugTitle); title != "" {
+			return fmt.Sprintf("Spike: %s", title)
+		}
+		return fmt.Sprintf("Spike: %s", cp.Message)
+	case KindDigest:
+		return cp.DigestTitle
+	}
+
+	if title := headline(cp.BugTitle); title != "" {
+		if cp.Regressed {
+			return fmt.Sprintf("Regressed: %s", title)
+		}
+		return title
+	}
+
+	return fmt.Sprintf("A new ticket has been added to %s by BugFix.es", cp.TicketSystem)
 }
 
 //go:generate mockery --name=CommsSystem
@@ -28,6 +85,8 @@ type CommsSystem interface {
 
 type Comms struct {
 	Config config.Config
+	// System is used instead of the one in the credentials when set
+	System CommsSystem
 }
 
 func NewComms(c config.Config) *Comms {
@@ -50,6 +109,10 @@ func (c Comms) fetchCommsCredentials(a agent.Agent) (CommsCredentials, error) {
 
 // nolint: gocyclo
 func (c Comms) fetchCommsSystem(creds CommsCredentials) (CommsSystem, error) {
+	if c.System != nil {
+		return c.System, nil
+	}
+
 	var cs CommsSystem
 	switch creds.System {
 	case "slack":
@@ -79,19 +142,85 @@ func (c Comms) CommsSend(system CommsSystem, creds CommsCredentials, commsPackag
 	return nil
 }
 
+// SendComms sends the package on the agent's channel, a bug is held back for the digest when the channel has one,
+// and isn't sent again while it is cooling down, what is sent goes in the ledger
 func (c Comms) SendComms(commsPackage CommsPackage) error {
 	creds, err := c.fetchCommsCredentials(commsPackage.Agent)
 	if err != nil {
 		return bugLog.Errorf("sendComms fetchCommsCredentials: %+v", err)
 	}
+
+	s := NewCommsStorage(c.Config)
+	now := time.Now()
+	if commsPackage.Kind == KindTicket && creds.Digest() != "" && !c.immediate(commsPackage.Level) {
+		if err := s.AddDigest(DigestEntry{
+			Agent:         commsPackage.Agent,
+			BugID:         commsPackage.BugID,
+			Level:         commsPackage.Level,
+			File:          commsPackage.File,
+			Line:          commsPackage.Line,
+			Link:          commsPackage.Link,
+			TimesReported: commsPackage.TimesReported,
+			Regressed:     commsPackage.Regressed,
+			Reported:      now,
+		}); err != nil {
+			return bugLog.Errorf("sendComms addDigest: %+v", err)
+		}
+		return nil
+	}
+
 	commsSystem, err := c.fetchCommsSystem(creds)
 	if err != nil {
 		return bugLog.Errorf("sendComms fetchCommsSystem: %+v", err)
 	}
 
+	if commsPackage.BugID == "" {
+		if err := c.CommsSend(commsSystem, creds, commsPackage); err != nil {
+			return bugLog.Errorf("sendComms commsSend: %+v", err)
+		}
+		return nil
+	}
+
+	commsPackage.Ref = uuid.New().String()
+	n := Notification{
+		Ref:     commsPackage.Ref,
+		Agent:   commsPackage.Agent,
+		BugID:   commsPackage.BugID,
+		Channel: creds.Channel(),
+		Kind:    commsPackage.Kind,
+		Rule:    commsPackage.Rule,
+		State:   commsPackage.State,
+		Sent:    now,
+	}
+
+	// a ticket is claimed in the ledger before it goes out, so two workers can't both send it inside the cooldown
+	if commsPackage.Kind == KindTicket && commsPackage.Cooldown > 0 {
+		claimed, err := s.ClaimNotification(n, commsPackage.Cooldown)
+		if err != nil {
+			return bugLog.Errorf("sendComms claimNotification: %+v", err)
+		}
+		if !claimed {
+			bugLog.Debugf("sendComms: %s is cooling down on %s", commsPackage.BugID, creds.Channel())
+			return nil
+		}
+
+		if err := c.CommsSend(commsSystem, creds, commsPackage); err != nil {
+			if err := s.ReleaseNotification(n.Ref); err != nil {
+				bugLog.Debugf("sendComms releaseNotification: %+v", err)
+			}
+			return bugLog.Errorf("sendComms commsSend: %+v", err)
+		}
+		return nil
+	}
+
 	if err := c.CommsSend(commsSystem, creds, commsPackage); err != nil {
 		return bugLog.Errorf("sendComms commsSend: %+v", err)
 	}
 
+	// the ledger is part of sending, once the message is out a retry would only send it twice
+	if err := s.RecordNotification(n); err != nil {
+		bugLog.Debugf("sendComms recordNotification: %+v", err)
+	}
+
 	return nil
 }
@@ -0,0 +1,271 @@
+package comms
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bugfixes/celeste/internal/agent"
+	bugLog "github.com/bugfixes/go-bugfixes/logs"
+)
+
+const (
+	DigestHourly = "hourly"
+	DigestDaily  = "daily"
+)
+
+// DigestEntry is one report held back for the channel's digest
+type DigestEntry struct {
+	Agent agent.Agent `json:"-"`
+
+	BugID         string    `json:"bug_id"`
+	Level         string    `json:"level"`
+	File          string    `json:"file"`
+	Line          string    `json:"line"`
+	Link          string    `json:"link"`
+	TimesReported int       `json:"times_reported"`
+	Regressed     bool      `json:"regressed"`
+	Reported      time.Time `json:"reported"`
+}
+
+// DigestBug is a bug in the digest, Reports are the ones in the period, TimesReported is every one so far
+type DigestBug struct {
+	BugID         string `json:"bug_id"`
+	Level         string `json:"level"`
+	File          string `json:"file"`
+	Line          string `json:"line"`
+	Link          string `json:"link"`
+	Reports       int    `json:"reports"`
+	TimesReported int    `json:"times_reported"`
+}
+
+// Digest is the channel's summary of the period, the bugs first seen in it, the ones that came back, and the most reported
+type Digest struct {
+	Period    string      `json:"period"`
+	From      time.Time   `json:"from"`
+	To        time.Time   `json:"to"`
+	Reports   int         `json:"reports"`
+	New       []DigestBug `json:"new"`
+	Regressed []DigestBug `json:"regressed"`
+	Top       []DigestBug `json:"top"`
+}
+
+// Digest is the channel's digest period from its details, e.g. {"channel": "#bugs", "digest": "hourly"},
+// empty sends every bug on its own
+func (cc CommsCredentials) Digest() string {
+	details, ok := cc.CommsDetails.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	if d, ok := details["digest"].(string); ok && (d == DigestHourly || d == DigestDaily) {
+		return d
+	}
+
+	return ""
+}
+
+func digestPeriod(digest string) time.Duration {
+	if digest == DigestDaily {
+		return 24 * time.Hour
+	}
+
+	return time.Hour
+}
+
+// digestDue is whether the period the last digest went out in is over, so a job run every hour sends
+// an hourly digest each run and a daily one on the first run of the day
+func digestDue(digest string, last, now time.Time) bool {
+	if last.IsZero() {
+		return true
+	}
+	period := digestPeriod(digest)
+
+	return now.UTC().Truncate(period).After(last.UTC().Truncate(period))
+}
+
+func (c Comms) immediate(level string) bool {
+	for _, l := range c.Config.Digest.ImmediateLevels {
+		if l == level {
+			return true
+		}
+	}
+
+	return false
+}
+
+// BuildDigest sums up the entries, a bug is new when one of its entries was its first report,
+// the top bugs are the most reported in the period, at most top of them
+func BuildDigest(period string, from, to time.Time, entries []DigestEntry, top int) Digest {
+	d := Digest{
+		Period:    period,
+		From:      from,
+		To:        to,
+		Reports:   len(entries),
+		New:       []DigestBug{},
+		Regressed: []DigestBug{},
+		Top:       []DigestBug{},
+	}
+
+	bugs := map[string]*DigestBug{}
+	order := []string{}
+	isNew, regressed := map[string]bool{}, map[string]bool{}
+	for _, e := range entries {
+		b, ok := bugs[e.BugID]
+		if !ok {
+			b = &DigestBug{
+				BugID: e.BugID,
+				Level: e.Level,
+				File:  e.File,
+				Line:  e.Line,
+			}
+			bugs[e.BugID] = b
+			order = append(order, e.BugID)
+		}
+		b.Reports++
+		if e.TimesReported > b.TimesReported {
+			b.TimesReported = e.TimesReported
+		}
+		if e.Link != "" {
+			b.Link = e.Link
+		}
+		if e.TimesReported == 1 {
+			isNew[e.BugID] = true
+		}
+		if e.Regressed {
+			regressed[e.BugID] = true
+		}
+	}
+
+	all := make([]DigestBug, 0, len(order))
+	for _, id := range order {
+		b := *bugs[id]
+		if isNew[id] {
+			d.New = append(d.New, b)
+		}
+		if regressed[id] {
+			d.Regressed = append(d.Regressed, b)
+		}
+		all = append(all, b)
+	}
+
+	sort.SliceStable(all, func(i, j int) bool {
+		return all[i].Reports > all[j].Reports
+	})
+	if len(all) > top {
+		all = all[:top]
+	}
+	d.Top = all
+
+	return d
+}
+
+func (b DigestBug) String() string {
+	line := fmt.Sprintf("- %s at %s:%s, %d in the period, %d in all", b.Level, b.File, b.Line, b.Reports, b.TimesReported)
+	if b.Link != "" {
+		line += fmt.Sprintf(" %s", b.Link)
+	}
+
+	return line
+}
+
+// Package turns the digest into what the channel is sent
+func (d Digest) Package(a agent.Agent) CommsPackage {
+	lines := []string{
+		fmt.Sprintf("%d reports from %s to %s", d.Reports, d.From.UTC().Format("2006-01-02 15:04"), d.To.UTC().Format("2006-01-02 15:04")),
+	}
+	for _, section := range []struct {
+		heading string
+		bugs    []DigestBug
+	}{
+		{"New", d.New},
+		{"Regressed", d.Regressed},
+		{"Most reported", d.Top},
+	} {
+		if len(section.bugs) == 0 {
+			continue
+		}
+		lines = append(lines, "", section.heading+":")
+		for _, b := range section.bugs {
+			lines = append(lines, b.String())
+		}
+	}
+
+	return CommsPackage{
+		Agent:       a,
+		Kind:        KindDigest,
+		DigestTitle: fmt.Sprintf("%s%s digest: %d new, %d regressed", strings.ToUpper(d.Period[:1]), d.Period[1:], len(d.New), len(d.Regressed)),
+		Message:     strings.Join(lines, "\n"),
+	}
+}
+
+// SendDigests is the scheduled job, every channel in digest mode whose period is over is sent what was held back for it,
+// a channel with nothing held back is sent nothing, a failed channel is left for the next run
+func (c Comms) SendDigests(now time.Time) (int, error) {
+	sent := 0
+	s := NewCommsStorage(c.Config)
+
+	channels, err := s.DigestChannels()
+	if err != nil {
+		return sent, bugLog.Errorf("sendDigests channels: %+v", err)
+	}
+
+	var failed error
+	for _, creds := range channels {
+		if creds.Digest() == "" || !digestDue(creds.Digest(), creds.LastDigest, now) {
+			continue
+		}
+		if err := c.sendDigest(s, creds, now); err != nil {
+			failed = bugLog.Errorf("sendDigests: %+v", err)
+			continue
+		}
+		sent++
+	}
+
+	return sent, failed
+}
+
+func (c Comms) sendDigest(s CommsStorage, creds CommsCredentials, now time.Time) error {
+	entries, err := s.DigestEntries(creds.Agent, now)
+	if err != nil {
+		return bugLog.Errorf("sendDigest entries: %+v", err)
+	}
+
+	if len(entries) > 0 {
+		from := creds.LastDigest
+		if from.IsZero() {
+			from = entries[0].Reported
+		}
+
+		system, err := c.fetchCommsSystem(creds)
+		if err != nil {
+			return bugLog.Errorf("sendDigest fetchCommsSystem: %+v", err)
+		}
+		d := BuildDigest(creds.Digest(), from, now, entries, c.Config.Digest.Top)
+		if err := c.CommsSend(system, creds, d.Package(creds.Agent)); err != nil {
+			return bugLog.Errorf("sendDigest commsSend: %+v", err)
+		}
+
+		recorded := map[string]bool{}
+		for _, e := range entries {
+			if e.BugID == "" || recorded[e.BugID] {
+				continue
+			}
+			recorded[e.BugID] = true
+			if err := s.RecordNotification(Notification{
+				BugID:   e.BugID,
+				Channel: creds.Channel(),
+				Kind:    KindDigest,
+				Sent:    now,
+			}); err != nil {
+				return bugLog.Errorf("sendDigest recordNotification: %+v", err)
+			}
+		}
+	}
+
+	if err := s.ClearDigest(creds.Agent, now); err != nil {
+		return bugLog.Errorf("sendDigest clearDigest: %+v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,122 @@
+package comms_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bugfixes/celeste/internal/agent"
+	"github.com/bugfixes/celeste/internal/comms"
+	"github.com/bugfixes/celeste/internal/comms/mocks"
+	"github.com/bugfixes/celeste/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestBuildDigest(t *testing.T) {
+	now := time.Date(2021, 6, 10, 12, 0, 0, 0, time.UTC)
+	entries := []comms.DigestEntry{
+		{BugID: "new", Level: "error", File: "main.go", Line: "10", TimesReported: 1, Reported: now},
+		{BugID: "busy", Level: "error", File: "busy.go", Line: "1", TimesReported: 40, Reported: now},
+		{BugID: "new", Level: "error", File: "main.go", Line: "10", TimesReported: 2, Reported: now},
+		{BugID: "busy", Level: "error", File: "busy.go", Line: "1", TimesReported: 41, Reported: now},
+		{BugID: "busy", Level: "error", File: "busy.go", Line: "1", TimesReported: 42, Reported: now},
+		{BugID: "back", Level: "warn", File: "back.go", Line: "5", TimesReported: 7, Regressed: true, Reported: now},
+	}
+
+	d := comms.BuildDigest(comms.DigestHourly, now.Add(-time.Hour), now, entries, 2)
+	expect := comms.Digest{
+		Period:  comms.DigestHourly,
+		From:    now.Add(-time.Hour),
+		To:      now,
+		Reports: 6,
+		New: []comms.DigestBug{
+			{BugID: "new", Level: "error", File: "main.go", Line: "10", Reports: 2, TimesReported: 2},
+		},
+		Regressed: []comms.DigestBug{
+			{BugID: "back", Level: "warn", File: "back.go", Line: "5", Reports: 1, TimesReported: 7},
+		},
+		Top: []comms.DigestBug{
+			{BugID: "busy", Level: "error", File: "busy.go", Line: "1", Reports: 3, TimesReported: 42},
+			{BugID: "new", Level: "error", File: "main.go", Line: "10", Reports: 2, TimesReported: 2},
+		},
+	}
+	if passed := assert.Equal(t, expect, d); !passed {
+		t.Errorf("buildDigest expect: %+v, got: %+v", expect, d)
+	}
+
+	cp := d.Package(agent.Agent{})
+	if passed := assert.Equal(t, "Hourly digest: 1 new, 1 regressed", cp.Title()); !passed {
+		t.Errorf("digest title got: %v", cp.Title())
+	}
+}
+
+func TestSendDigests(t *testing.T) {
+	t.Cleanup(resetMemory)
+
+	c := config.Config{
+		Storage: config.Storage{
+			StorageBackend: config.StorageMemory,
+		},
+		Digest: config.Digest{
+			ImmediateLevels: []string{"crash"},
+			Top:             5,
+		},
+	}
+	a := agent.Agent{
+		Credentials: agent.Credentials{
+			Key:    "digest",
+			Secret: "digest",
+		},
+	}
+	if err := comms.NewCommsStorage(c).StoreCredentials(comms.CommsCredentials{
+		Agent:  a,
+		System: "slack",
+		CommsDetails: map[string]interface{}{
+			"channel": "#bugs",
+			"digest":  comms.DigestHourly,
+		},
+	}); err != nil {
+		t.Fatalf("storeCredentials err: %+v", err)
+	}
+
+	system := &mocks.CommsSystem{}
+	system.On("ParseCredentials", mock.Anything).Return(nil)
+	system.On("Connect").Return(nil)
+	system.On("Send", mock.MatchedBy(func(cp comms.CommsPackage) bool {
+		return cp.Kind == comms.KindTicket && cp.Level == "crash"
+	})).Return(nil).Once()
+	system.On("Send", mock.MatchedBy(func(cp comms.CommsPackage) bool {
+		return cp.Kind == comms.KindDigest && cp.DigestTitle == "Hourly digest: 1 new, 0 regressed"
+	})).Return(nil).Once()
+
+	cm := comms.NewComms(c)
+	cm.System = system
+	for _, cp := range []comms.CommsPackage{
+		{Agent: a, Kind: comms.KindTicket, BugID: "first", Level: "error", TimesReported: 1},
+		{Agent: a, Kind: comms.KindTicket, BugID: "first", Level: "error", TimesReported: 2},
+		{Agent: a, Kind: comms.KindTicket, BugID: "crash", Level: "crash", TimesReported: 1},
+	} {
+		if err := cm.SendComms(cp); err != nil {
+			t.Errorf("sendComms err: %+v", err)
+		}
+	}
+
+	now := time.Now()
+	for _, run := range []struct {
+		at   time.Time
+		sent int
+	}{
+		{at: now, sent: 1},
+		{at: now, sent: 0},
+	} {
+		sent, err := cm.SendDigests(run.at)
+		if passed := assert.Nil(t, err); !passed {
+			t.Errorf("sendDigests err: %+v", err)
+		}
+		if passed := assert.Equal(t, run.sent, sent); !passed {
+			t.Errorf("sendDigests expect: %v, got: %v", run.sent, sent)
+		}
+	}
+
+	system.AssertExpectations(t)
+}
@@ -71,13 +71,43 @@ func (d *Discord) ParseCredentials(creds interface{}) error {
 	return nil
 }
 
-func (d *Discord) Send(commsPackage CommsPackage) error {
-	title := fmt.Sprintf("A new ticket has been added to %s by BugFix.es", commsPackage.TicketSystem)
+// discordFields and discordDescriptionLength are the most an embed can hold
+const (
+	discordFields            = 25
+	discordDescriptionLength = 4096
+)
+
+// DiscordEmbed lays the template out as an embed, coloured by the level, with the fields side by side
+func DiscordEmbed(t Template) discord.Embed {
 	embed := discord.Embed{
-		Title: "Ticket Link",
-		URL:   commsPackage.Link,
+		Title:       t.Title,
+		Description: cut(t.Text, discordDescriptionLength),
+		URL:         discord.URL(t.Link),
+		Color:       discord.Color(t.Colour),
+	}
+	for i, f := range t.Fields {
+		if i == discordFields {
+			break
+		}
+		embed.Fields = append(embed.Fields, discord.EmbedField{
+			Name:   f.Name,
+			Value:  f.Value,
+			Inline: true,
+		})
+	}
+	if t.Footer != "" {
+		embed.Footer = &discord.EmbedFooter{
+			Text: t.Footer,
+		}
 	}
 
+	return embed
+}
+
+func (d *Discord) Send(commsPackage CommsPackage) error {
+	t := commsPackage.Template()
+	embed := DiscordEmbed(t)
+
 	g, err := gateway.NewGateway(d.BotAuth)
 	if err != nil {
 		return bugLog.Errorf("discord send newGateway: %+v", err)
@@ -92,7 +122,7 @@ func (d *Discord) Send(commsPackage CommsPackage) error {
 	if err != nil {
 		return bugLog.Errorf("discord send parseSnowFlake: %+v", err)
 	}
-	m, err := c.SendMessage(discord.ChannelID(snow), title, &embed)
+	m, err := c.SendMessage(discord.ChannelID(snow), "", &embed)
 	if err != nil {
 		return bugLog.Errorf("discord send sendMessage: %+v", err)
 	}
@@ -0,0 +1,35 @@
+package comms
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bugfixes/celeste/internal/agent"
+)
+
+// ErrNotificationNotFound is returned as is when no notification was sent with the ref
+var ErrNotificationNotFound = errors.New("notification not found")
+
+// Notification is a line of the ledger, what was sent for a bug on a channel, with the bug's state at the time,
+// Ref is what the message's buttons carry back, it finds the bug and the agent it belongs to
+type Notification struct {
+	Ref     string      `json:"ref,omitempty"`
+	Agent   agent.Agent `json:"-"`
+	BugID   string      `json:"bug_id"`
+	Channel string      `json:"channel"`
+	Kind    string      `json:"kind"`
+	Rule    string      `json:"rule,omitempty"`
+	State   string      `json:"state,omitempty"`
+	Sent    time.Time   `json:"sent"`
+}
+
+// Channel is the system and the channel in it, e.g. slack:#bugs
+func (cc CommsCredentials) Channel() string {
+	channel := ""
+	if details, ok := cc.CommsDetails.(map[string]interface{}); ok {
+		channel, _ = details["channel"].(string)
+	}
+
+	return fmt.Sprintf("%s:%s", cc.System, channel)
+}
@@ -0,0 +1,227 @@
+package comms_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bugfixes/celeste/internal/agent"
+	"github.com/bugfixes/celeste/internal/comms"
+	"github.com/bugfixes/celeste/internal/comms/mocks"
+	"github.com/bugfixes/celeste/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// resetMemory empties the memory backends the comms tests write to, so -count=2 starts from nothing
+func resetMemory() {
+	comms.ResetMemory()
+	agent.ResetMemory()
+}
+
+func TestSendCommsCooldown(t *testing.T) {
+	t.Cleanup(resetMemory)
+
+	c := config.Config{
+		Storage: config.Storage{
+			StorageBackend: config.StorageMemory,
+		},
+	}
+
+	tests := []struct {
+		name   string
+		ledger []comms.Notification
+		state  string
+		sends  int
+	}{
+		{
+			name:  "never sent",
+			state: "open@:",
+			sends: 1,
+		},
+		{
+			name: "inside the cooldown",
+			ledger: []comms.Notification{
+				{Channel: "slack:#bugs", Kind: comms.KindTicket, State: "open@:", Sent: time.Now().Add(-time.Minute)},
+			},
+			state: "open@:",
+		},
+		{
+			name: "state changed",
+			ledger: []comms.Notification{
+				{Channel: "slack:#bugs", Kind: comms.KindTicket, State: "open@:", Sent: time.Now().Add(-time.Minute)},
+			},
+			state: "open@2021-06-10T12:00:00Z:v1.1.0",
+			sends: 1,
+		},
+		{
+			name: "after the cooldown",
+			ledger: []comms.Notification{
+				{Channel: "slack:#bugs", Kind: comms.KindTicket, State: "open@:", Sent: time.Now().Add(-2 * time.Hour)},
+			},
+			state: "open@:",
+			sends: 1,
+		},
+		{
+			name: "sent on another channel",
+			ledger: []comms.Notification{
+				{Channel: "discord:bugs", Kind: comms.KindTicket, State: "open@:", Sent: time.Now().Add(-time.Minute)},
+			},
+			state: "open@:",
+			sends: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a := agent.Agent{
+				Credentials: agent.Credentials{
+					Key:    "cooldown-" + test.name,
+					Secret: "cooldown",
+				},
+			}
+			s := comms.NewCommsStorage(c)
+			if err := s.StoreCredentials(comms.CommsCredentials{
+				Agent:  a,
+				System: "slack",
+				CommsDetails: map[string]interface{}{
+					"channel": "#bugs",
+				},
+			}); err != nil {
+				t.Fatalf("storeCredentials err: %+v", err)
+			}
+			bugID := "bug-" + test.name
+			for _, n := range test.ledger {
+				n.BugID = bugID
+				if err := s.RecordNotification(n); err != nil {
+					t.Fatalf("recordNotification err: %+v", err)
+				}
+			}
+
+			system := &mocks.CommsSystem{}
+			system.On("ParseCredentials", mock.Anything).Return(nil)
+			system.On("Connect").Return(nil)
+			system.On("Send", mock.Anything).Return(nil)
+
+			cm := comms.NewComms(c)
+			cm.System = system
+			err := cm.SendComms(comms.CommsPackage{
+				Agent:    a,
+				Kind:     comms.KindTicket,
+				BugID:    bugID,
+				Level:    "error",
+				Rule:     "more than 10 reports",
+				State:    test.state,
+				Cooldown: time.Hour,
+			})
+			if passed := assert.Nil(t, err); !passed {
+				t.Errorf("sendComms err: %+v", err)
+			}
+			system.AssertNumberOfCalls(t, "Send", test.sends)
+
+			ledger, err := s.Notifications(bugID)
+			if passed := assert.Nil(t, err); !passed {
+				t.Fatalf("notifications err: %+v", err)
+			}
+			if passed := assert.Len(t, ledger, len(test.ledger)+test.sends); !passed {
+				t.Errorf("notifications got: %+v", ledger)
+			}
+			if test.sends > 0 {
+				expect := comms.Notification{
+					Agent:   a,
+					BugID:   bugID,
+					Channel: "slack:#bugs",
+					Kind:    comms.KindTicket,
+					Rule:    "more than 10 reports",
+					State:   test.state,
+				}
+				if passed := assert.NotEmpty(t, ledger[0].Ref); !passed {
+					t.Errorf("notification has no ref")
+				}
+				ledger[0].Ref = ""
+				ledger[0].Sent = time.Time{}
+				if passed := assert.Equal(t, expect, ledger[0]); !passed {
+					t.Errorf("notification expect: %+v, got: %+v", expect, ledger[0])
+				}
+			}
+		})
+	}
+}
+
+func TestSendCommsClaim(t *testing.T) {
+	t.Cleanup(resetMemory)
+
+	c := config.Config{
+		Storage: config.Storage{
+			StorageBackend: config.StorageMemory,
+		},
+	}
+	a := agent.Agent{
+		Credentials: agent.Credentials{
+			Key:    "cooldown-claim",
+			Secret: "cooldown",
+		},
+	}
+	s := comms.NewCommsStorage(c)
+	if err := s.StoreCredentials(comms.CommsCredentials{
+		Agent:  a,
+		System: "slack",
+		CommsDetails: map[string]interface{}{
+			"channel": "#bugs",
+		},
+	}); err != nil {
+		t.Fatalf("storeCredentials err: %+v", err)
+	}
+	cp := comms.CommsPackage{
+		Agent:    a,
+		Kind:     comms.KindTicket,
+		BugID:    "bug-claim",
+		Level:    "error",
+		State:    "open@:",
+		Cooldown: time.Hour,
+	}
+
+	failing := &mocks.CommsSystem{}
+	failing.On("ParseCredentials", mock.Anything).Return(nil)
+	failing.On("Connect").Return(errors.New("slack is down"))
+	cm := comms.NewComms(c)
+	cm.System = failing
+	if err := cm.SendComms(cp); err == nil {
+		t.Errorf("sendComms expected an error")
+	}
+	ledger, err := s.Notifications(cp.BugID)
+	if passed := assert.Nil(t, err); !passed {
+		t.Fatalf("notifications err: %+v", err)
+	}
+	if passed := assert.Empty(t, ledger); !passed {
+		t.Errorf("failed send kept its claim: %+v", ledger)
+	}
+
+	system := &mocks.CommsSystem{}
+	system.On("ParseCredentials", mock.Anything).Return(nil)
+	system.On("Connect").Return(nil)
+	system.On("Send", mock.Anything).Return(nil)
+	cm.System = system
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := cm.SendComms(cp); err != nil {
+				t.Errorf("sendComms err: %+v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	system.AssertNumberOfCalls(t, "Send", 1)
+
+	ledger, err = s.Notifications(cp.BugID)
+	if passed := assert.Nil(t, err); !passed {
+		t.Fatalf("notifications err: %+v", err)
+	}
+	if passed := assert.Len(t, ledger, 1); !passed {
+		t.Errorf("notifications got: %+v", ledger)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/bugfixes/celeste/internal/config"
 	bugLog "github.com/bugfixes/go-bugfixes/logs"
@@ -68,19 +69,81 @@ func (s *Slack) ParseCredentials(creds interface{}) error {
 	return nil
 }
 
-func (s *Slack) Send(commsPackage CommsPackage) error {
-	title := slack.MsgOptionText(
-		fmt.Sprintf("A new ticket has been added to %s by BugFix.es", commsPackage.TicketSystem),
-		false)
-	message := slack.MsgOptionAttachments(slack.Attachment{
-		Text: fmt.Sprintf("Ticket Link\n %s", commsPackage.Link),
-	})
+// slackTextLength is the most a section's text can hold
+const slackTextLength = 3000
+
+// slackHeaderLength is the most a header can hold, a spike's prefix can take the title past it
+const slackHeaderLength = 150
+
+var slackEscape = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+func slackText(s string) *slack.TextBlockObject {
+	return slack.NewTextBlockObject(slack.MarkdownType, cut(slackEscape.Replace(s), slackTextLength), false, false)
+}
+
+// slackActions are the buttons on a bug's message, in order
+var slackActions = []struct {
+	action string
+	text   string
+	style  slack.Style
+}{
+	{action: ActionResolve, text: "Resolve", style: slack.StylePrimary},
+	{action: ActionIgnore, text: "Ignore", style: slack.StyleDanger},
+	{action: ActionSnooze, text: "Snooze 24h"},
+	{action: ActionAssign, text: "Assign to me"},
+}
+
+// SlackAttachment lays the template out in blocks, inside an attachment so the bar down the side has the level's colour
+func SlackAttachment(t Template) slack.Attachment {
+	blocks := []slack.Block{
+		slack.NewHeaderBlock(slack.NewTextBlockObject(slack.PlainTextType, cut(t.Title, slackHeaderLength), false, false)),
+	}
+	if t.Text != "" {
+		blocks = append(blocks, slack.NewSectionBlock(slackText(t.Text), nil, nil))
+	}
+	if len(t.Fields) > 0 {
+		fields := []*slack.TextBlockObject{}
+		for _, f := range t.Fields {
+			fields = append(fields, slackText(fmt.Sprintf("*%s*\n%s", f.Name, f.Value)))
+		}
+		blocks = append(blocks, slack.NewSectionBlock(nil, fields, nil))
+	}
+	buttons := []slack.BlockElement{}
+	if t.Link != "" {
+		open := slack.NewButtonBlockElement("open_ticket", "", slack.NewTextBlockObject(slack.PlainTextType, t.LinkText, false, false))
+		open.URL = t.Link
+		buttons = append(buttons, open)
+	}
+	if t.Ref != "" {
+		for _, a := range slackActions {
+			button := slack.NewButtonBlockElement(slackActionPrefix+a.action, t.Ref, slack.NewTextBlockObject(slack.PlainTextType, a.text, false, false))
+			button.Style = a.style
+			buttons = append(buttons, button)
+		}
+	}
+	if len(buttons) > 0 {
+		blocks = append(blocks, slack.NewActionBlock("actions", buttons...))
+	}
+	if t.Footer != "" {
+		blocks = append(blocks, slack.NewContextBlock("footer", slackText(t.Footer)))
+	}
 
+	return slack.Attachment{
+		Color:    fmt.Sprintf("#%06x", t.Colour),
+		Fallback: t.Title,
+		Blocks: slack.Blocks{
+			BlockSet: blocks,
+		},
+	}
+}
+
+func (s *Slack) Send(commsPackage CommsPackage) error {
+	t := commsPackage.Template()
 	if _, _, err := s.Client.PostMessageContext(
 		s.Context,
 		s.Credentials.Channel,
-		title,
-		message); err != nil {
+		slack.MsgOptionText(t.Title, false),
+		slack.MsgOptionAttachments(SlackAttachment(t))); err != nil {
 		return bugLog.Errorf("slack send postMessage: %+v", err)
 	}
 
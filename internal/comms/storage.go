@@ -3,7 +3,9 @@ package comms
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/bugfixes/celeste/internal/agent"
 	"github.com/bugfixes/celeste/internal/config"
@@ -11,7 +13,24 @@ import (
 	"github.com/jackc/pgx/v4"
 )
 
-type CommsStorage struct {
+//go:generate mockery --name=CommsStorage
+type CommsStorage interface {
+	StoreCredentials(credentials CommsCredentials) error
+	FetchCredentials(a agent.Agent) (CommsCredentials, error)
+
+	AddDigest(entry DigestEntry) error
+	DigestChannels() ([]CommsCredentials, error)
+	DigestEntries(a agent.Agent, until time.Time) ([]DigestEntry, error)
+	ClearDigest(a agent.Agent, until time.Time) error
+
+	RecordNotification(n Notification) error
+	ClaimNotification(n Notification, cooldown time.Duration) (bool, error)
+	ReleaseNotification(ref string) error
+	Notifications(bugID string) ([]Notification, error)
+	Notification(ref string) (Notification, error)
+}
+
+type PostgresCommsStorage struct {
 	Config  config.Config
 	Context context.Context
 }
@@ -20,16 +39,26 @@ type CommsCredentials struct {
 	Agent        agent.Agent `json:"agent_id"`
 	CommsDetails interface{} `json:"comms_details"`
 	System       string      `json:"system"`
+	// LastDigest is when the channel was last sent its digest
+	LastDigest time.Time `json:"last_digest"`
 }
 
-func NewCommsStorage(c config.Config) *CommsStorage {
-	return &CommsStorage{
+func NewCommsStorage(c config.Config) CommsStorage {
+	if c.StorageBackend == config.StorageMemory {
+		return NewMemoryCommsStorage(c)
+	}
+
+	return NewPostgresCommsStorage(c)
+}
+
+func NewPostgresCommsStorage(c config.Config) *PostgresCommsStorage {
+	return &PostgresCommsStorage{
 		Config:  c,
 		Context: context.Background(),
 	}
 }
 
-func (c CommsStorage) getConnection() (*pgx.Conn, error) {
+func (c PostgresCommsStorage) getConnection() (*pgx.Conn, error) {
 	conn, err := pgx.Connect(c.Context,
 		fmt.Sprintf(
 			"postgres://%s:%s@%s:%s/%s",
@@ -45,7 +74,35 @@ func (c CommsStorage) getConnection() (*pgx.Conn, error) {
 	return conn, nil
 }
 
-func (c CommsStorage) FetchCredentials(a agent.Agent) (CommsCredentials, error) {
+func (c PostgresCommsStorage) StoreCredentials(credentials CommsCredentials) error {
+	conn, err := c.getConnection()
+	if err != nil {
+		return bugLog.Errorf("storeCredentials: %+v", err)
+	}
+	defer func() {
+		if err := conn.Close(c.Context); err != nil {
+			bugLog.Debugf("close: %+v", err)
+		}
+	}()
+
+	dbytes, err := json.Marshal(credentials.CommsDetails)
+	if err != nil {
+		return bugLog.Errorf("marshal details: %+v", err)
+	}
+
+	if _, err := conn.Exec(c.Context,
+		"INSERT INTO comms_details (agent_id, system, details) VALUES ((SELECT id FROM agent WHERE key = $1 AND secret = $2 LIMIT 1), $3, $4)",
+		credentials.Agent.Credentials.Key,
+		credentials.Agent.Credentials.Secret,
+		credentials.System,
+		string(dbytes)); err != nil {
+		return bugLog.Errorf("store: %+v", err)
+	}
+
+	return nil
+}
+
+func (c PostgresCommsStorage) FetchCredentials(a agent.Agent) (CommsCredentials, error) {
 	cc := CommsCredentials{}
 	var details string
 
@@ -73,3 +130,273 @@ func (c CommsStorage) FetchCredentials(a agent.Agent) (CommsCredentials, error)
 
 	return cc, nil
 }
+
+func (c PostgresCommsStorage) closeConnection(conn *pgx.Conn) {
+	if err := conn.Close(c.Context); err != nil {
+		bugLog.Debugf("close: %+v", err)
+	}
+}
+
+func (c PostgresCommsStorage) AddDigest(entry DigestEntry) error {
+	conn, err := c.getConnection()
+	if err != nil {
+		return bugLog.Errorf("addDigest: %+v", err)
+	}
+	defer c.closeConnection(conn)
+
+	if _, err := conn.Exec(c.Context,
+		"INSERT INTO comms_digest (agent_id, bug_id, level, file, line, link, times_reported, regressed, reported) "+
+			"VALUES ((SELECT id FROM agent WHERE key = $1 AND secret = $2 LIMIT 1), $3, $4, $5, $6, $7, $8, $9, $10)",
+		entry.Agent.Credentials.Key,
+		entry.Agent.Credentials.Secret,
+		entry.BugID,
+		entry.Level,
+		entry.File,
+		entry.Line,
+		entry.Link,
+		entry.TimesReported,
+		entry.Regressed,
+		entry.Reported); err != nil {
+		return bugLog.Errorf("addDigest exec: %+v", err)
+	}
+
+	return nil
+}
+
+func (c PostgresCommsStorage) DigestChannels() ([]CommsCredentials, error) {
+	channels := []CommsCredentials{}
+
+	conn, err := c.getConnection()
+	if err != nil {
+		return channels, bugLog.Errorf("digestChannels: %+v", err)
+	}
+	defer c.closeConnection(conn)
+
+	rows, err := conn.Query(c.Context,
+		"SELECT agent.key, agent.secret, comms_details.system, comms_details.details, comms_details.last_digest "+
+			"FROM comms_details JOIN agent ON agent.id = comms_details.agent_id "+
+			"WHERE comms_details.details->>'digest' IN ($1, $2)",
+		DigestHourly,
+		DigestDaily)
+	if err != nil {
+		return channels, bugLog.Errorf("digestChannels query: %+v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		cc := CommsCredentials{}
+		var details string
+		var lastDigest *time.Time
+		if err := rows.Scan(&cc.Agent.Credentials.Key, &cc.Agent.Credentials.Secret, &cc.System, &details, &lastDigest); err != nil {
+			return channels, bugLog.Errorf("digestChannels scan: %+v", err)
+		}
+		if err := json.Unmarshal([]byte(details), &cc.CommsDetails); err != nil {
+			return channels, bugLog.Errorf("digestChannels unmarshal: %+v", err)
+		}
+		if lastDigest != nil {
+			cc.LastDigest = *lastDigest
+		}
+		channels = append(channels, cc)
+	}
+
+	return channels, rows.Err()
+}
+
+func (c PostgresCommsStorage) DigestEntries(a agent.Agent, until time.Time) ([]DigestEntry, error) {
+	entries := []DigestEntry{}
+
+	conn, err := c.getConnection()
+	if err != nil {
+		return entries, bugLog.Errorf("digestEntries: %+v", err)
+	}
+	defer c.closeConnection(conn)
+
+	rows, err := conn.Query(c.Context,
+		"SELECT bug_id, level, file, line, link, times_reported, regressed, reported FROM comms_digest "+
+			"WHERE agent_id = (SELECT id FROM agent WHERE key = $1 AND secret = $2 LIMIT 1) AND reported <= $3 ORDER BY reported",
+		a.Credentials.Key,
+		a.Credentials.Secret,
+		until)
+	if err != nil {
+		return entries, bugLog.Errorf("digestEntries query: %+v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		e := DigestEntry{
+			Agent: a,
+		}
+		if err := rows.Scan(&e.BugID, &e.Level, &e.File, &e.Line, &e.Link, &e.TimesReported, &e.Regressed, &e.Reported); err != nil {
+			return entries, bugLog.Errorf("digestEntries scan: %+v", err)
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, rows.Err()
+}
+
+// ClearDigest drops the entries that were sent and notes when, in one transaction so a digest isn't sent twice
+func (c PostgresCommsStorage) ClearDigest(a agent.Agent, until time.Time) error {
+	conn, err := c.getConnection()
+	if err != nil {
+		return bugLog.Errorf("clearDigest: %+v", err)
+	}
+	defer c.closeConnection(conn)
+
+	tx, err := conn.Begin(c.Context)
+	if err != nil {
+		return bugLog.Errorf("clearDigest begin: %+v", err)
+	}
+	defer func() {
+		if err := tx.Rollback(c.Context); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			bugLog.Debugf("rollback: %+v", err)
+		}
+	}()
+
+	if _, err := tx.Exec(c.Context,
+		"DELETE FROM comms_digest WHERE agent_id = (SELECT id FROM agent WHERE key = $1 AND secret = $2 LIMIT 1) AND reported <= $3",
+		a.Credentials.Key,
+		a.Credentials.Secret,
+		until); err != nil {
+		return bugLog.Errorf("clearDigest delete: %+v", err)
+	}
+	if _, err := tx.Exec(c.Context,
+		"UPDATE comms_details SET last_digest = $3 WHERE agent_id = (SELECT id FROM agent WHERE key = $1 AND secret = $2 LIMIT 1)",
+		a.Credentials.Key,
+		a.Credentials.Secret,
+		until); err != nil {
+		return bugLog.Errorf("clearDigest update: %+v", err)
+	}
+
+	if err := tx.Commit(c.Context); err != nil {
+		return bugLog.Errorf("clearDigest commit: %+v", err)
+	}
+
+	return nil
+}
+
+func (c PostgresCommsStorage) RecordNotification(n Notification) error {
+	conn, err := c.getConnection()
+	if err != nil {
+		return bugLog.Errorf("recordNotification: %+v", err)
+	}
+	defer c.closeConnection(conn)
+
+	if _, err := conn.Exec(c.Context,
+		"INSERT INTO comms_notification (ref, agent_id, bug_id, channel, kind, rule, state, sent) "+
+			"VALUES (NULLIF($1, ''), (SELECT id FROM agent WHERE key = $2 AND secret = $3 LIMIT 1), $4, $5, $6, $7, $8, $9)",
+		n.Ref,
+		n.Agent.Credentials.Key,
+		n.Agent.Credentials.Secret,
+		n.BugID,
+		n.Channel,
+		n.Kind,
+		n.Rule,
+		n.State,
+		n.Sent); err != nil {
+		return bugLog.Errorf("recordNotification exec: %+v", err)
+	}
+
+	return nil
+}
+
+// ClaimNotification puts n in the ledger before it is sent, it is false when the last one of its kind on the channel
+// was sent within the cooldown with the same state, the new line follows on from that last one and only one line
+// can follow each, so two workers can't both claim it
+func (c PostgresCommsStorage) ClaimNotification(n Notification, cooldown time.Duration) (bool, error) {
+	conn, err := c.getConnection()
+	if err != nil {
+		return false, bugLog.Errorf("claimNotification: %+v", err)
+	}
+	defer c.closeConnection(conn)
+
+	tag, err := conn.Exec(c.Context,
+		"WITH latest AS (SELECT id, state, sent FROM comms_notification WHERE bug_id = $4 AND channel = $5 AND kind = $6 ORDER BY sent DESC, id DESC LIMIT 1) "+
+			"INSERT INTO comms_notification (ref, agent_id, bug_id, channel, kind, rule, state, sent, follows) "+
+			"SELECT NULLIF($1, ''), (SELECT id FROM agent WHERE key = $2 AND secret = $3 LIMIT 1), $4, $5, $6, $7, $8, $9, COALESCE((SELECT id FROM latest), 0) "+
+			"WHERE NOT EXISTS (SELECT 1 FROM latest WHERE COALESCE(state, '') = $8 AND sent > $10) "+
+			"ON CONFLICT DO NOTHING",
+		n.Ref,
+		n.Agent.Credentials.Key,
+		n.Agent.Credentials.Secret,
+		n.BugID,
+		n.Channel,
+		n.Kind,
+		n.Rule,
+		n.State,
+		n.Sent,
+		n.Sent.Add(-cooldown))
+	if err != nil {
+		return false, bugLog.Errorf("claimNotification exec: %+v", err)
+	}
+
+	return tag.RowsAffected() == 1, nil
+}
+
+// ReleaseNotification takes a claimed line back out of the ledger when it couldn't be sent
+func (c PostgresCommsStorage) ReleaseNotification(ref string) error {
+	conn, err := c.getConnection()
+	if err != nil {
+		return bugLog.Errorf("releaseNotification: %+v", err)
+	}
+	defer c.closeConnection(conn)
+
+	if _, err := conn.Exec(c.Context, "DELETE FROM comms_notification WHERE ref = $1", ref); err != nil {
+		return bugLog.Errorf("releaseNotification exec: %+v", err)
+	}
+
+	return nil
+}
+
+// Notifications is the bug's ledger, newest first
+func (c PostgresCommsStorage) Notifications(bugID string) ([]Notification, error) {
+	ledger := []Notification{}
+
+	conn, err := c.getConnection()
+	if err != nil {
+		return ledger, bugLog.Errorf("notifications: %+v", err)
+	}
+	defer c.closeConnection(conn)
+
+	rows, err := conn.Query(c.Context,
+		"SELECT COALESCE(ref, ''), bug_id, channel, kind, COALESCE(rule, ''), COALESCE(state, ''), sent FROM comms_notification WHERE bug_id = $1 ORDER BY sent DESC, id DESC",
+		bugID)
+	if err != nil {
+		return ledger, bugLog.Errorf("notifications query: %+v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		n := Notification{}
+		if err := rows.Scan(&n.Ref, &n.BugID, &n.Channel, &n.Kind, &n.Rule, &n.State, &n.Sent); err != nil {
+			return ledger, bugLog.Errorf("notifications scan: %+v", err)
+		}
+		ledger = append(ledger, n)
+	}
+
+	return ledger, rows.Err()
+}
+
+// Notification is the one the ref was sent with, with its agent
+func (c PostgresCommsStorage) Notification(ref string) (Notification, error) {
+	n := Notification{}
+
+	conn, err := c.getConnection()
+	if err != nil {
+		return n, bugLog.Errorf("notification: %+v", err)
+	}
+	defer c.closeConnection(conn)
+
+	if err := conn.QueryRow(c.Context,
+		"SELECT comms_notification.ref, agent.key, agent.secret, comms_notification.bug_id, comms_notification.channel, comms_notification.kind, "+
+			"COALESCE(comms_notification.rule, ''), COALESCE(comms_notification.state, ''), comms_notification.sent "+
+			"FROM comms_notification JOIN agent ON agent.id = comms_notification.agent_id WHERE comms_notification.ref = $1",
+		ref).Scan(&n.Ref, &n.Agent.Credentials.Key, &n.Agent.Credentials.Secret, &n.BugID, &n.Channel, &n.Kind, &n.Rule, &n.State, &n.Sent); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return n, ErrNotificationNotFound
+		}
+		return n, bugLog.Errorf("notification queryRow: %+v", err)
+	}
+
+	return n, nil
+}
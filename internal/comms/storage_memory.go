@@ -0,0 +1,206 @@
+package comms
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/bugfixes/celeste/internal/agent"
+	"github.com/bugfixes/celeste/internal/config"
+	bugLog "github.com/bugfixes/go-bugfixes/logs"
+)
+
+type commsMemory struct {
+	sync.RWMutex
+
+	credentials map[string]CommsCredentials
+	digests     map[string][]DigestEntry
+	ledger      map[string][]Notification
+}
+
+// sharedCommsMemory is kept for the life of the process so every MemoryCommsStorage sees the same details
+var sharedCommsMemory = &commsMemory{
+	credentials: map[string]CommsCredentials{},
+	digests:     map[string][]DigestEntry{},
+	ledger:      map[string][]Notification{},
+}
+
+// ResetMemory empties the details, digests and ledger every MemoryCommsStorage shares
+func ResetMemory() {
+	sharedCommsMemory.Lock()
+	defer sharedCommsMemory.Unlock()
+
+	sharedCommsMemory.credentials = map[string]CommsCredentials{}
+	sharedCommsMemory.digests = map[string][]DigestEntry{}
+	sharedCommsMemory.ledger = map[string][]Notification{}
+}
+
+type MemoryCommsStorage struct {
+	Config config.Config
+
+	memory *commsMemory
+}
+
+func NewMemoryCommsStorage(c config.Config) *MemoryCommsStorage {
+	return &MemoryCommsStorage{
+		Config: c,
+		memory: sharedCommsMemory,
+	}
+}
+
+func credentialsKey(a agent.Agent) string {
+	return fmt.Sprintf("%s:%s", a.Credentials.Key, a.Credentials.Secret)
+}
+
+func (c MemoryCommsStorage) StoreCredentials(credentials CommsCredentials) error {
+	c.memory.Lock()
+	defer c.memory.Unlock()
+
+	c.memory.credentials[credentialsKey(credentials.Agent)] = credentials
+
+	return nil
+}
+
+func (c MemoryCommsStorage) FetchCredentials(a agent.Agent) (CommsCredentials, error) {
+	c.memory.RLock()
+	defer c.memory.RUnlock()
+
+	cc, ok := c.memory.credentials[credentialsKey(a)]
+	if !ok {
+		return CommsCredentials{}, bugLog.Errorf("fetchCredentials: no credentials for agent")
+	}
+
+	return cc, nil
+}
+
+func (c MemoryCommsStorage) AddDigest(entry DigestEntry) error {
+	c.memory.Lock()
+	defer c.memory.Unlock()
+
+	key := credentialsKey(entry.Agent)
+	c.memory.digests[key] = append(c.memory.digests[key], entry)
+
+	return nil
+}
+
+func (c MemoryCommsStorage) DigestChannels() ([]CommsCredentials, error) {
+	c.memory.RLock()
+	defer c.memory.RUnlock()
+
+	channels := []CommsCredentials{}
+	for _, cc := range c.memory.credentials {
+		if cc.Digest() != "" {
+			channels = append(channels, cc)
+		}
+	}
+
+	return channels, nil
+}
+
+func (c MemoryCommsStorage) DigestEntries(a agent.Agent, until time.Time) ([]DigestEntry, error) {
+	c.memory.RLock()
+	defer c.memory.RUnlock()
+
+	entries := []DigestEntry{}
+	for _, e := range c.memory.digests[credentialsKey(a)] {
+		if !e.Reported.After(until) {
+			entries = append(entries, e)
+		}
+	}
+
+	return entries, nil
+}
+
+func (c MemoryCommsStorage) ClearDigest(a agent.Agent, until time.Time) error {
+	c.memory.Lock()
+	defer c.memory.Unlock()
+
+	key := credentialsKey(a)
+	kept := []DigestEntry{}
+	for _, e := range c.memory.digests[key] {
+		if e.Reported.After(until) {
+			kept = append(kept, e)
+		}
+	}
+	c.memory.digests[key] = kept
+
+	if cc, ok := c.memory.credentials[key]; ok {
+		cc.LastDigest = until
+		c.memory.credentials[key] = cc
+	}
+
+	return nil
+}
+
+func (c MemoryCommsStorage) RecordNotification(n Notification) error {
+	c.memory.Lock()
+	defer c.memory.Unlock()
+
+	c.memory.ledger[n.BugID] = append(c.memory.ledger[n.BugID], n)
+
+	return nil
+}
+
+func (c MemoryCommsStorage) ClaimNotification(n Notification, cooldown time.Duration) (bool, error) {
+	c.memory.Lock()
+	defer c.memory.Unlock()
+
+	sent := c.memory.ledger[n.BugID]
+	for i := len(sent) - 1; i >= 0; i-- {
+		if sent[i].Channel != n.Channel || sent[i].Kind != n.Kind {
+			continue
+		}
+		if sent[i].State == n.State && n.Sent.Sub(sent[i].Sent) < cooldown {
+			return false, nil
+		}
+		break
+	}
+	c.memory.ledger[n.BugID] = append(sent, n)
+
+	return true, nil
+}
+
+func (c MemoryCommsStorage) ReleaseNotification(ref string) error {
+	c.memory.Lock()
+	defer c.memory.Unlock()
+
+	for bugID, sent := range c.memory.ledger {
+		kept := []Notification{}
+		for _, n := range sent {
+			if n.Ref != ref {
+				kept = append(kept, n)
+			}
+		}
+		c.memory.ledger[bugID] = kept
+	}
+
+	return nil
+}
+
+func (c MemoryCommsStorage) Notifications(bugID string) ([]Notification, error) {
+	c.memory.RLock()
+	defer c.memory.RUnlock()
+
+	sent := c.memory.ledger[bugID]
+	ledger := make([]Notification, 0, len(sent))
+	for i := len(sent) - 1; i >= 0; i-- {
+		ledger = append(ledger, sent[i])
+	}
+
+	return ledger, nil
+}
+
+func (c MemoryCommsStorage) Notification(ref string) (Notification, error) {
+	c.memory.RLock()
+	defer c.memory.RUnlock()
+
+	for _, sent := range c.memory.ledger {
+		for _, n := range sent {
+			if ref != "" && n.Ref == ref {
+				return n, nil
+			}
+		}
+	}
+
+	return Notification{}, ErrNotificationNotFound
+}
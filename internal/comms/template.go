@@ -0,0 +1,132 @@
+package comms
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// headlineLength is as much of the bug's first line as goes in a title
+const headlineLength = 150
+
+// seenFormat is how first and last seen are shown
+const seenFormat = "2006-01-02 15:04 MST"
+
+// levelColours are the colours of a message by the bug's level, anything else is grey
+var levelColours = map[string]int{
+	"crash": 0x8B0000,
+	"panic": 0x8B0000,
+	"fatal": 0x8B0000,
+	"error": 0xE01E5A,
+	"warn":  0xECB22E,
+	"info":  0x36C5F0,
+}
+
+const defaultColour = 0x9E9E9E
+
+// TemplateField is a name and value pair, shown side by side when there is room
+type TemplateField struct {
+	Name  string
+	Value string
+}
+
+// Template is what every comms system sends, built once from the package, each system lays it out its own way
+type Template struct {
+	Title    string
+	Text     string
+	Link     string
+	LinkText string
+	Colour   int
+	Fields   []TemplateField
+	Footer   string
+	// Ref is carried back by the buttons of systems that have them, only bugs that were sent have one
+	Ref string
+}
+
+// LevelColour is the level's colour as a number, e.g. 0xE01E5A
+func LevelColour(level string) int {
+	if colour, ok := levelColours[strings.ToLower(level)]; ok {
+		return colour
+	}
+
+	return defaultColour
+}
+
+// headline is the bug's first line, cut to headlineLength
+func headline(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = strings.TrimSpace(s[:i])
+	}
+
+	return cut(s, headlineLength)
+}
+
+// cut shortens s to n characters, ending it with an ellipsis when it was longer
+func cut(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n-1]) + "…"
+	}
+
+	return s
+}
+
+func seen(t time.Time) string {
+	if t.IsZero() {
+		return "unknown"
+	}
+
+	return t.UTC().Format(seenFormat)
+}
+
+// Template is the package's message, a digest is only its summary, tickets and spikes get the bug's details as fields
+func (cp CommsPackage) Template() Template {
+	m := Template{
+		Title:  cp.Title(),
+		Link:   cp.Link,
+		Colour: LevelColour(cp.Level),
+	}
+	if cp.Link != "" {
+		m.LinkText = fmt.Sprintf("Open in %s", cp.TicketSystem)
+		if cp.TicketSystem == "" {
+			m.LinkText = "Open the ticket"
+		}
+	}
+
+	switch cp.Kind {
+	case KindDigest:
+		m.Text = cp.Message
+		m.Colour = defaultColour
+		return m
+	case KindSpike:
+		m.Text = cp.Message
+		m.Ref = cp.Ref
+	default:
+		m.Ref = cp.Ref
+		if cp.Regressed {
+			m.Text = "This bug had been resolved and has come back"
+		}
+	}
+
+	location := cp.File
+	if cp.Line != "" {
+		location = fmt.Sprintf("%s:%s", cp.File, cp.Line)
+	}
+	environment := cp.Environment
+	if environment == "" {
+		environment = "none"
+	}
+	m.Fields = []TemplateField{
+		{Name: "Level", Value: cp.Level},
+		{Name: "Environment", Value: environment},
+		{Name: "Location", Value: location},
+		{Name: "Reports", Value: fmt.Sprint(cp.TimesReported)},
+		{Name: "First seen", Value: seen(cp.FirstSeen)},
+		{Name: "Last seen", Value: seen(cp.LastSeen)},
+	}
+	if cp.BugID != "" {
+		m.Footer = fmt.Sprintf("BugFix.es, bug %s", cp.BugID)
+	}
+
+	return m
+}
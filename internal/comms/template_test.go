@@ -0,0 +1,258 @@
+package comms_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bugfixes/celeste/internal/comms"
+	"github.com/diamondburned/arikawa/v2/discord"
+	"github.com/slack-go/slack"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTemplate(t *testing.T) {
+	first := time.Date(2021, 6, 1, 9, 30, 0, 0, time.UTC)
+	last := time.Date(2021, 6, 10, 12, 0, 0, 0, time.UTC)
+	fields := []comms.TemplateField{
+		{Name: "Level", Value: "error"},
+		{Name: "Environment", Value: "production"},
+		{Name: "Location", Value: "main.go:10"},
+		{Name: "Reports", Value: "12"},
+		{Name: "First seen", Value: "2021-06-01 09:30 UTC"},
+		{Name: "Last seen", Value: "2021-06-10 12:00 UTC"},
+	}
+	bug := comms.CommsPackage{
+		Kind:          comms.KindTicket,
+		Link:          "https://github.com/bugfixes/celeste/issues/1",
+		TicketSystem:  "github",
+		BugID:         "abc",
+		BugTitle:      "nil pointer dereference\ngoroutine 1 [running]:",
+		Level:         "error",
+		Environment:   "production",
+		File:          "main.go",
+		Line:          "10",
+		TimesReported: 12,
+		FirstSeen:     first,
+		LastSeen:      last,
+		Ref:           "ref",
+	}
+
+	regressed := bug
+	regressed.Regressed = true
+	spike := bug
+	spike.Kind = comms.KindSpike
+	spike.Message = "40 reports in the last hour"
+	untitled := comms.CommsPackage{
+		Kind:         comms.KindTicket,
+		TicketSystem: "jira",
+		Level:        "crash",
+	}
+
+	tests := []struct {
+		name   string
+		cp     comms.CommsPackage
+		expect comms.Template
+	}{
+		{
+			name: "ticket",
+			cp:   bug,
+			expect: comms.Template{
+				Title:    "nil pointer dereference",
+				Link:     "https://github.com/bugfixes/celeste/issues/1",
+				LinkText: "Open in github",
+				Colour:   0xE01E5A,
+				Fields:   fields,
+				Footer:   "BugFix.es, bug abc",
+				Ref:      "ref",
+			},
+		},
+		{
+			name: "regressed",
+			cp:   regressed,
+			expect: comms.Template{
+				Title:    "Regressed: nil pointer dereference",
+				Text:     "This bug had been resolved and has come back",
+				Link:     "https://github.com/bugfixes/celeste/issues/1",
+				LinkText: "Open in github",
+				Colour:   0xE01E5A,
+				Fields:   fields,
+				Footer:   "BugFix.es, bug abc",
+				Ref:      "ref",
+			},
+		},
+		{
+			name: "spike",
+			cp:   spike,
+			expect: comms.Template{
+				Title:    "Spike: nil pointer dereference",
+				Text:     "40 reports in the last hour",
+				Link:     "https://github.com/bugfixes/celeste/issues/1",
+				LinkText: "Open in github",
+				Colour:   0xE01E5A,
+				Fields:   fields,
+				Footer:   "BugFix.es, bug abc",
+				Ref:      "ref",
+			},
+		},
+		{
+			name: "no title",
+			cp:   untitled,
+			expect: comms.Template{
+				Title:  "A new ticket has been added to jira by BugFix.es",
+				Colour: 0x8B0000,
+				Fields: []comms.TemplateField{
+					{Name: "Level", Value: "crash"},
+					{Name: "Environment", Value: "none"},
+					{Name: "Location", Value: ""},
+					{Name: "Reports", Value: "0"},
+					{Name: "First seen", Value: "unknown"},
+					{Name: "Last seen", Value: "unknown"},
+				},
+			},
+		},
+		{
+			name: "digest",
+			cp: comms.CommsPackage{
+				Kind:        comms.KindDigest,
+				DigestTitle: "Hourly digest: 1 new, 0 regressed",
+				Message:     "1 reports",
+			},
+			expect: comms.Template{
+				Title:  "Hourly digest: 1 new, 0 regressed",
+				Text:   "1 reports",
+				Colour: 0x9E9E9E,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tmpl := test.cp.Template()
+			if passed := assert.Equal(t, test.expect, tmpl); !passed {
+				t.Errorf("template expect: %+v, got: %+v", test.expect, tmpl)
+			}
+		})
+	}
+
+	long := comms.CommsPackage{
+		BugTitle: strings.Repeat("a", 200),
+	}
+	if passed := assert.Equal(t, 150, len([]rune(long.Title()))); !passed {
+		t.Errorf("template long title got: %v", long.Title())
+	}
+}
+
+func TestLevelColour(t *testing.T) {
+	tests := []struct {
+		level  string
+		expect int
+	}{
+		{level: "panic", expect: 0x8B0000},
+		{level: "ERROR", expect: 0xE01E5A},
+		{level: "warn", expect: 0xECB22E},
+		{level: "info", expect: 0x36C5F0},
+		{level: "debug", expect: 0x9E9E9E},
+		{level: "", expect: 0x9E9E9E},
+	}
+
+	for _, test := range tests {
+		t.Run(test.level, func(t *testing.T) {
+			colour := comms.LevelColour(test.level)
+			if passed := assert.Equal(t, test.expect, colour); !passed {
+				t.Errorf("levelColour expect: %x, got: %x", test.expect, colour)
+			}
+		})
+	}
+}
+
+func TestSlackAttachment(t *testing.T) {
+	tmpl := comms.Template{
+		Title:    "nil pointer dereference",
+		Text:     "a <b> & c",
+		Link:     "https://github.com/bugfixes/celeste/issues/1",
+		LinkText: "Open in github",
+		Colour:   0xE01E5A,
+		Fields: []comms.TemplateField{
+			{Name: "Level", Value: "error"},
+		},
+		Footer: "BugFix.es, bug abc",
+		Ref:    "ref",
+	}
+
+	a := comms.SlackAttachment(tmpl)
+	if passed := assert.Equal(t, "#e01e5a", a.Color); !passed {
+		t.Errorf("slack colour got: %v", a.Color)
+	}
+
+	blocks := a.Blocks.BlockSet
+	if passed := assert.Len(t, blocks, 5); !passed {
+		t.Fatalf("slack blocks got: %+v", blocks)
+	}
+	if passed := assert.Equal(t, "nil pointer dereference", blocks[0].(*slack.HeaderBlock).Text.Text); !passed {
+		t.Errorf("slack header got: %+v", blocks[0])
+	}
+	if passed := assert.Equal(t, "a &lt;b&gt; &amp; c", blocks[1].(*slack.SectionBlock).Text.Text); !passed {
+		t.Errorf("slack text got: %+v", blocks[1])
+	}
+	if passed := assert.Equal(t, "*Level*\nerror", blocks[2].(*slack.SectionBlock).Fields[0].Text); !passed {
+		t.Errorf("slack fields got: %+v", blocks[2])
+	}
+	buttons := blocks[3].(*slack.ActionBlock).Elements.ElementSet
+	if passed := assert.Len(t, buttons, 5); !passed {
+		t.Fatalf("slack buttons got: %+v", buttons)
+	}
+	if passed := assert.Equal(t, tmpl.Link, buttons[0].(*slack.ButtonBlockElement).URL); !passed {
+		t.Errorf("slack link got: %+v", buttons[0])
+	}
+	for i, action := range []string{"celeste_resolve", "celeste_ignore", "celeste_snooze", "celeste_assign"} {
+		button := buttons[i+1].(*slack.ButtonBlockElement)
+		if passed := assert.Equal(t, action, button.ActionID); !passed {
+			t.Errorf("slack button expect: %v, got: %v", action, button.ActionID)
+		}
+		if passed := assert.Equal(t, "ref", button.Value); !passed {
+			t.Errorf("slack button value got: %v", button.Value)
+		}
+	}
+
+	tmpl.Title = "Spike: " + strings.Repeat("é", 150)
+	header := comms.SlackAttachment(tmpl).Blocks.BlockSet[0].(*slack.HeaderBlock).Text.Text
+	if passed := assert.Equal(t, 150, utf8.RuneCountInString(header)); !passed {
+		t.Errorf("slack header length got: %d", utf8.RuneCountInString(header))
+	}
+	if passed := assert.True(t, strings.HasSuffix(header, "…")); !passed {
+		t.Errorf("slack header got: %v", header)
+	}
+}
+
+func TestDiscordEmbed(t *testing.T) {
+	embed := comms.DiscordEmbed(comms.Template{
+		Title:  "nil pointer dereference",
+		Text:   "This bug had been resolved and has come back",
+		Link:   "https://github.com/bugfixes/celeste/issues/1",
+		Colour: 0xE01E5A,
+		Fields: []comms.TemplateField{
+			{Name: "Level", Value: "error"},
+			{Name: "Reports", Value: "12"},
+		},
+		Footer: "BugFix.es, bug abc",
+	})
+
+	expect := discord.Embed{
+		Title:       "nil pointer dereference",
+		Description: "This bug had been resolved and has come back",
+		URL:         "https://github.com/bugfixes/celeste/issues/1",
+		Color:       0xE01E5A,
+		Fields: []discord.EmbedField{
+			{Name: "Level", Value: "error", Inline: true},
+			{Name: "Reports", Value: "12", Inline: true},
+		},
+		Footer: &discord.EmbedFooter{
+			Text: "BugFix.es, bug abc",
+		},
+	}
+	if passed := assert.Equal(t, expect, embed); !passed {
+		t.Errorf("discord embed expect: %+v, got: %+v", expect, embed)
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"regexp"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
@@ -33,13 +35,26 @@ type AuthCredential struct {
 }
 
 type DynamoDB struct {
-	BugsTable      string `env:"DB_BUGS_TABLE" envDefault:"bugs"`
-	AccountsTable  string `env:"DB_ACCOUNTS_TABLE" envDefault:"accounts"`
-	AgentsTable    string `env:"DB_AGENTS_TABLE" envDefault:"agents"`
-	TicketingTable string `env:"DB_TICKETING_TABLE" envDefault:"ticketing"`
-	TicketsTable   string `env:"DB_TICKETS_TABLE" envDefault:"tickets"`
-	CommsTable     string `env:"DB_COMMS_TABLE" envDefault:"comms"`
-	LogsTable      string `env:"DB_LOGS_TABLE" envDefault:"logs"`
+	BugsTable         string `env:"DB_BUGS_TABLE" envDefault:"bugs"`
+	BugsIndex         string `env:"DB_BUGS_INDEX" envDefault:"agent_fingerprint"`
+	AccountsTable     string `env:"DB_ACCOUNTS_TABLE" envDefault:"accounts"`
+	AgentsTable       string `env:"DB_AGENTS_TABLE" envDefault:"agents"`
+	TicketingTable    string `env:"DB_TICKETING_TABLE" envDefault:"ticketing"`
+	TicketsTable      string `env:"DB_TICKETS_TABLE" envDefault:"tickets"`
+	CommsTable        string `env:"DB_COMMS_TABLE" envDefault:"comms"`
+	LogsTable         string `env:"DB_LOGS_TABLE" envDefault:"logs"`
+	LogsIndex         string `env:"DB_LOGS_INDEX" envDefault:"agent_logged"`
+	LogsReceivedIndex string `env:"DB_LOGS_RECEIVED_INDEX" envDefault:"agent_received"`
+}
+
+const (
+	StorageDynamo   = "dynamo"
+	StoragePostgres = "postgres"
+	StorageMemory   = "memory"
+)
+
+type Storage struct {
+	StorageBackend string `env:"STORAGE_BACKEND" envDefault:"dynamo"`
 }
 
 type Local struct {
@@ -49,9 +64,16 @@ type Local struct {
 	Port        int    `env:"LOCAL_PORT" envDefault:"3000"`
 }
 
+const (
+	QueueSQS   = "sqs"
+	QueueLocal = "local"
+)
+
 type Queues struct {
-	Name       string `env:"QUEUE_NAME" envDefault:"bugs"`
-	DeadLetter string `env:"QUEUE_DEADLETTER_NAME" envDefault:"deadletter"`
+	QueueBackend string `env:"QUEUE_BACKEND" envDefault:"sqs"`
+	Name         string `env:"QUEUE_NAME" envDefault:"bugs"`
+	DeadLetter   string `env:"QUEUE_DEADLETTER_NAME" envDefault:"deadletter"`
+	MaxAttempts  int    `env:"QUEUE_MAX_ATTEMPTS" envDefault:"5"`
 }
 
 type AWS struct {
@@ -59,6 +81,68 @@ type AWS struct {
 	SecretsClient *secretsmanager.SecretsManager
 }
 
+// Promotion is when a stored log is also reported as a bug, at or above Level, or when the entry matches Pattern,
+// a Level of none only promotes on the pattern
+type Promotion struct {
+	Level   string `env:"LOG_PROMOTE_LEVEL" envDefault:"error"`
+	Pattern string `env:"LOG_PROMOTE_PATTERN" envDefault:""`
+
+	// Regexp is the compiled Pattern
+	Regexp *regexp.Regexp
+}
+
+// Compile compiles the pattern once, BuildConfig fails when it isn't valid
+func (p *Promotion) Compile() error {
+	p.Regexp = nil
+	if p.Pattern == "" {
+		return nil
+	}
+
+	re, err := regexp.Compile(p.Pattern)
+	if err != nil {
+		return bugLog.Errorf("promotion compile: %+v", err)
+	}
+	p.Regexp = re
+
+	return nil
+}
+
+// Reporting holds back the tickets and comms of bugs from the QuietEnvironments, they are still counted,
+// NotifyCooldown is how long a bug isn't sent again on a channel, for rules that don't set their own
+type Reporting struct {
+	QuietEnvironments []string      `env:"QUIET_ENVIRONMENTS" envSeparator:","`
+	NotifyCooldown    time.Duration `env:"NOTIFY_COOLDOWN" envDefault:"1h"`
+}
+
+// Spike is a bug reported Multiplier times more in the last hour than an hour over the BaselineHours before it,
+// with at least MinRate reports in that hour, a bug's spike is only sent once a Cooldown, a Multiplier of 0 turns it off
+type Spike struct {
+	Multiplier    float64       `env:"SPIKE_MULTIPLIER" envDefault:"10"`
+	MinRate       int           `env:"SPIKE_MIN_RATE" envDefault:"20"`
+	BaselineHours int           `env:"SPIKE_BASELINE_HOURS" envDefault:"24"`
+	Cooldown      time.Duration `env:"SPIKE_COOLDOWN" envDefault:"1h"`
+}
+
+// Digest is how comms channels in digest mode are summed up, bugs at the ImmediateLevels are still sent on their own,
+// the Top most reported bugs of the period are listed
+type Digest struct {
+	ImmediateLevels []string `env:"DIGEST_IMMEDIATE_LEVELS" envSeparator:"," envDefault:"crash,panic,fatal"`
+	Top             int      `env:"DIGEST_TOP" envDefault:"5"`
+}
+
+// Source is where tickets get their code excerpt from, a local git checkout when Checkout is set,
+// otherwise the GitHub repo the agent tickets into
+type Source struct {
+	Checkout     string `env:"SOURCE_CHECKOUT" envDefault:""`
+	ContextLines int    `env:"SOURCE_CONTEXT_LINES" envDefault:"5"`
+}
+
+// Slack is the app's signing secret, only button presses signed with it are acted on,
+// it is read from secrets manager as slack_signing_secret when it isn't set
+type Slack struct {
+	SigningSecret string `env:"SLACK_SIGNING_SECRET" envDefault:""`
+}
+
 type Authorization struct {
 	JWTSecret    string
 	CallbackHost string `env:"CALLBACK_HOST" envDefault:"http://localhost:3000"`
@@ -73,7 +157,14 @@ type Config struct {
 	Local
 	RDS
 	DynamoDB
+	Storage
 	Queues
+	Promotion
+	Reporting
+	Spike
+	Digest
+	Source
+	Slack
 	Authorization
 	AWS
 
@@ -88,6 +179,10 @@ func BuildConfig() (Config, error) {
 		return cfg, bugLog.Errorf("parse: %+v", err)
 	}
 
+	if err := cfg.Promotion.Compile(); err != nil {
+		return cfg, bugLog.Errorf("LOG_PROMOTE_PATTERN: %+v", err)
+	}
+
 	sess, err := BuildSession(cfg)
 	if err != nil {
 		return Config{}, bugLog.Errorf("buildSession: %+v", err)
@@ -95,8 +190,11 @@ func BuildConfig() (Config, error) {
 
 	cfg.AWS.SecretsClient = secretsmanager.New(sess)
 
-	if err := buildDatabase(&cfg); err != nil {
-		return cfg, bugLog.Errorf("buildDatabase: %+v", err)
+	// the memory backend never touches a database, so there is nothing to look up
+	if cfg.StorageBackend != StorageMemory {
+		if err := buildDatabase(&cfg); err != nil {
+			return cfg, bugLog.Errorf("buildDatabase: %+v", err)
+		}
 	}
 
 	buildSystems(&cfg, "PROVIDERS_LIST")
@@ -147,7 +245,7 @@ func GetSecretEnv(client *secretsmanager.SecretsManager, secret, env string) (st
 }
 
 func getJWTSecret(cfg *Config) error {
-	jwt, err := GetSecret(cfg.AWS.SecretsClient, "JWT")
+	jwt, err := GetSecretEnv(cfg.AWS.SecretsClient, "JWT", "JWT_SECRET")
 	if err != nil {
 		return bugLog.Errorf("jwt_secret: %+v", err)
 	}
@@ -3,10 +3,21 @@ package database
 import (
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/bugfixes/celeste/internal/config"
 	bugLog "github.com/bugfixes/go-bugfixes/logs"
 )
 
-type AccountStorage struct {
+//go:generate mockery --name=AccountStorage
+type AccountStorage interface {
+	Insert(data AccountRecord) error
+	Fetch(id string) (AccountRecord, error)
+	Delete(id string) error
+	UpdateRetention(id string, r Retention) error
+	UpdateRules(id string, r Rules) error
+}
+
+type DynamoAccountStorage struct {
 	Database Database
 }
 
@@ -27,8 +38,10 @@ type AccountRecord struct {
 	ParentID string `json:"parent_id"`
 	Email    string `json:"email"`
 	AccountCredentials
-	Level       int    `json:"level"`
-	DateCreated string `json:"date_created"`
+	Level       int       `json:"level"`
+	DateCreated string    `json:"date_created"`
+	Retention   Retention `json:"retention"`
+	Rules       Rules     `json:"rules"`
 }
 
 func GetAccountLevel(level string) int {
@@ -42,13 +55,24 @@ func GetAccountLevel(level string) int {
 	}
 }
 
-func NewAccountStorage(d Database) *AccountStorage {
-	return &AccountStorage{
+func NewAccountStorage(d Database) AccountStorage {
+	switch d.Config.StorageBackend {
+	case config.StoragePostgres:
+		return NewPostgresAccountStorage(d)
+	case config.StorageMemory:
+		return NewMemoryAccountStorage(d)
+	default:
+		return NewDynamoAccountStorage(d)
+	}
+}
+
+func NewDynamoAccountStorage(d Database) *DynamoAccountStorage {
+	return &DynamoAccountStorage{
 		Database: d,
 	}
 }
 
-func (a AccountStorage) Insert(data AccountRecord) error {
+func (a DynamoAccountStorage) Insert(data AccountRecord) error {
 	svc, err := a.Database.dynamoSession()
 	if err != nil {
 		return bugLog.Errorf("insert agent: %+v", err)
@@ -84,10 +108,111 @@ func (a AccountStorage) Insert(data AccountRecord) error {
 	return nil
 }
 
-func (a AccountStorage) Fetch(id string) (AccountRecord, error) {
-	return AccountRecord{}, nil
+func (a DynamoAccountStorage) Fetch(id string) (AccountRecord, error) {
+	ar := AccountRecord{}
+
+	svc, err := a.Database.dynamoSession()
+	if err != nil {
+		return ar, bugLog.Errorf("fetch account: %+v", err)
+	}
+
+	result, err := svc.GetItem(&dynamodb.GetItemInput{
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {
+				S: aws.String(id),
+			},
+		},
+		TableName: aws.String(a.Database.Config.AccountsTable),
+	})
+	if err != nil {
+		return ar, dynamoError(err)
+	}
+	if len(result.Item) == 0 {
+		return ar, bugLog.Errorf("fetch account: %s not found", id)
+	}
+
+	item := struct {
+		ID          string             `dynamodbav:"id"`
+		Name        string             `dynamodbav:"name"`
+		DateCreated string             `dynamodbav:"date_created"`
+		Credentials AccountCredentials `dynamodbav:"credentials"`
+		Retention   Retention          `dynamodbav:"retention"`
+		Rules       Rules              `dynamodbav:"rules"`
+	}{}
+	if err := dynamodbattribute.UnmarshalMap(result.Item, &item); err != nil {
+		return ar, bugLog.Errorf("fetch account unmarshal: %+v", err)
+	}
+
+	return AccountRecord{
+		ID:                 item.ID,
+		Name:               item.Name,
+		DateCreated:        item.DateCreated,
+		AccountCredentials: item.Credentials,
+		Retention:          item.Retention,
+		Rules:              item.Rules,
+	}, nil
+}
+
+func (a DynamoAccountStorage) UpdateRetention(id string, r Retention) error {
+	svc, err := a.Database.dynamoSession()
+	if err != nil {
+		return bugLog.Errorf("updateRetention account: %+v", err)
+	}
+
+	av, err := dynamodbattribute.Marshal(r)
+	if err != nil {
+		return bugLog.Errorf("updateRetention account marshal: %+v", err)
+	}
+
+	if _, err := svc.UpdateItem(&dynamodb.UpdateItemInput{
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {
+				S: aws.String(id),
+			},
+		},
+		UpdateExpression:    aws.String("SET retention = :retention"),
+		ConditionExpression: aws.String("attribute_exists(id)"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":retention": av,
+		},
+		TableName: aws.String(a.Database.Config.AccountsTable),
+	}); err != nil {
+		return dynamoError(err)
+	}
+
+	return nil
+}
+
+func (a DynamoAccountStorage) UpdateRules(id string, r Rules) error {
+	svc, err := a.Database.dynamoSession()
+	if err != nil {
+		return bugLog.Errorf("updateRules account: %+v", err)
+	}
+
+	av, err := dynamodbattribute.Marshal(r)
+	if err != nil {
+		return bugLog.Errorf("updateRules account marshal: %+v", err)
+	}
+
+	if _, err := svc.UpdateItem(&dynamodb.UpdateItemInput{
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {
+				S: aws.String(id),
+			},
+		},
+		UpdateExpression:    aws.String("SET rules = :rules"),
+		ConditionExpression: aws.String("attribute_exists(id)"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":rules": av,
+		},
+		TableName: aws.String(a.Database.Config.AccountsTable),
+	}); err != nil {
+		return dynamoError(err)
+	}
+
+	return nil
 }
 
-func (a AccountStorage) Delete(id string) error {
+func (a DynamoAccountStorage) Delete(id string) error {
 	return nil
 }
@@ -0,0 +1,145 @@
+package database
+
+import (
+	"encoding/json"
+
+	bugLog "github.com/bugfixes/go-bugfixes/logs"
+)
+
+type PostgresAccountStorage struct {
+	Database Database
+}
+
+func NewPostgresAccountStorage(d Database) *PostgresAccountStorage {
+	return &PostgresAccountStorage{
+		Database: d,
+	}
+}
+
+func (a PostgresAccountStorage) Insert(data AccountRecord) error {
+	conn, err := a.Database.getConnection()
+	if err != nil {
+		return bugLog.Errorf("insert account: %+v", err)
+	}
+	defer a.Database.closeConnection(conn)
+
+	if _, err := conn.Exec(a.Database.Context,
+		"INSERT INTO account (identifier, name, email, account_key, account_secret, account_group, date_created) VALUES ($1, $2, $3, $4, $5, $6, $7)",
+		data.ID,
+		data.Name,
+		data.Email,
+		data.AccountCredentials.Key,
+		data.AccountCredentials.Secret,
+		data.Level,
+		data.DateCreated); err != nil {
+		return bugLog.Errorf("insert account exec: %+v", err)
+	}
+
+	return nil
+}
+
+func (a PostgresAccountStorage) Fetch(id string) (AccountRecord, error) {
+	ar := AccountRecord{}
+	retention, rules := "", ""
+
+	conn, err := a.Database.getConnection()
+	if err != nil {
+		return ar, bugLog.Errorf("fetch account: %+v", err)
+	}
+	defer a.Database.closeConnection(conn)
+
+	if err := conn.QueryRow(a.Database.Context,
+		"SELECT identifier, COALESCE(name, ''), COALESCE(email, ''), account_key, COALESCE(account_secret, ''), COALESCE(account_group, 0), COALESCE(date_created, ''), COALESCE(retention, ''), COALESCE(rules, '') FROM account WHERE identifier = $1 LIMIT 1",
+		id).Scan(
+		&ar.ID,
+		&ar.Name,
+		&ar.Email,
+		&ar.AccountCredentials.Key,
+		&ar.AccountCredentials.Secret,
+		&ar.Level,
+		&ar.DateCreated,
+		&retention,
+		&rules); err != nil {
+		return ar, bugLog.Errorf("fetch account queryRow: %+v", err)
+	}
+	if retention != "" {
+		if err := json.Unmarshal([]byte(retention), &ar.Retention); err != nil {
+			return ar, bugLog.Errorf("fetch account retention: %+v", err)
+		}
+	}
+	if rules != "" {
+		if err := json.Unmarshal([]byte(rules), &ar.Rules); err != nil {
+			return ar, bugLog.Errorf("fetch account rules: %+v", err)
+		}
+	}
+
+	return ar, nil
+}
+
+func (a PostgresAccountStorage) Delete(id string) error {
+	conn, err := a.Database.getConnection()
+	if err != nil {
+		return bugLog.Errorf("delete account: %+v", err)
+	}
+	defer a.Database.closeConnection(conn)
+
+	if _, err := conn.Exec(a.Database.Context,
+		"DELETE FROM account WHERE identifier = $1",
+		id); err != nil {
+		return bugLog.Errorf("delete account exec: %+v", err)
+	}
+
+	return nil
+}
+
+func (a PostgresAccountStorage) UpdateRetention(id string, r Retention) error {
+	retention, err := json.Marshal(r)
+	if err != nil {
+		return bugLog.Errorf("updateRetention account marshal: %+v", err)
+	}
+
+	conn, err := a.Database.getConnection()
+	if err != nil {
+		return bugLog.Errorf("updateRetention account: %+v", err)
+	}
+	defer a.Database.closeConnection(conn)
+
+	tag, err := conn.Exec(a.Database.Context,
+		"UPDATE account SET retention = $2 WHERE identifier = $1",
+		id,
+		string(retention))
+	if err != nil {
+		return bugLog.Errorf("updateRetention account exec: %+v", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return bugLog.Errorf("updateRetention account: %s not found", id)
+	}
+
+	return nil
+}
+
+func (a PostgresAccountStorage) UpdateRules(id string, r Rules) error {
+	rules, err := json.Marshal(r)
+	if err != nil {
+		return bugLog.Errorf("updateRules account marshal: %+v", err)
+	}
+
+	conn, err := a.Database.getConnection()
+	if err != nil {
+		return bugLog.Errorf("updateRules account: %+v", err)
+	}
+	defer a.Database.closeConnection(conn)
+
+	tag, err := conn.Exec(a.Database.Context,
+		"UPDATE account SET rules = $2 WHERE identifier = $1",
+		id,
+		string(rules))
+	if err != nil {
+		return bugLog.Errorf("updateRules account exec: %+v", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return bugLog.Errorf("updateRules account: %s not found", id)
+	}
+
+	return nil
+}
@@ -3,10 +3,18 @@ package database
 import (
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/bugfixes/celeste/internal/config"
 	bugLog "github.com/bugfixes/go-bugfixes/logs"
 )
 
-type AgentStorage struct {
+//go:generate mockery --name=AgentStorage
+type AgentStorage interface {
+	Insert(data AgentRecord) error
+	Fetch(id string) (AgentRecord, error)
+	Delete(id string) error
+}
+
+type DynamoAgentStorage struct {
 	Database Database
 }
 
@@ -22,13 +30,24 @@ type AgentRecord struct {
 	AccountRecord
 }
 
-func NewAgentStorage(d Database) *AgentStorage {
-	return &AgentStorage{
+func NewAgentStorage(d Database) AgentStorage {
+	switch d.Config.StorageBackend {
+	case config.StoragePostgres:
+		return NewPostgresAgentStorage(d)
+	case config.StorageMemory:
+		return NewMemoryAgentStorage(d)
+	default:
+		return NewDynamoAgentStorage(d)
+	}
+}
+
+func NewDynamoAgentStorage(d Database) *DynamoAgentStorage {
+	return &DynamoAgentStorage{
 		Database: d,
 	}
 }
 
-func (a AgentStorage) Insert(data AgentRecord) error {
+func (a DynamoAgentStorage) Insert(data AgentRecord) error {
 	svc, err := a.Database.dynamoSession()
 	if err != nil {
 		return bugLog.Errorf("insert agent: %+v", err)
@@ -60,6 +79,7 @@ func (a AgentStorage) Insert(data AgentRecord) error {
 				},
 			},
 		},
+		TableName: aws.String(a.Database.Config.AgentsTable),
 	})
 	if err != nil {
 		return dynamoError(err)
@@ -67,10 +87,10 @@ func (a AgentStorage) Insert(data AgentRecord) error {
 	return nil
 }
 
-func (a AgentStorage) Fetch(id string) (AgentRecord, error) {
+func (a DynamoAgentStorage) Fetch(id string) (AgentRecord, error) {
 	return AgentRecord{}, nil
 }
 
-func (a AgentStorage) Delete(id string) error {
+func (a DynamoAgentStorage) Delete(id string) error {
 	return nil
 }
@@ -0,0 +1,74 @@
+package database
+
+import (
+	bugLog "github.com/bugfixes/go-bugfixes/logs"
+)
+
+type PostgresAgentStorage struct {
+	Database Database
+}
+
+func NewPostgresAgentStorage(d Database) *PostgresAgentStorage {
+	return &PostgresAgentStorage{
+		Database: d,
+	}
+}
+
+func (a PostgresAgentStorage) Insert(data AgentRecord) error {
+	conn, err := a.Database.getConnection()
+	if err != nil {
+		return bugLog.Errorf("insert agent: %+v", err)
+	}
+	defer a.Database.closeConnection(conn)
+
+	if _, err := conn.Exec(a.Database.Context,
+		"INSERT INTO agent (identifier, account_id, name, key, secret) VALUES ($1, (SELECT id FROM account WHERE identifier = $2 LIMIT 1), $3, $4, $5)",
+		data.ID,
+		data.AccountRecord.ID,
+		data.Name,
+		data.AgentCredentials.Key,
+		data.AgentCredentials.Secret); err != nil {
+		return bugLog.Errorf("insert agent exec: %+v", err)
+	}
+
+	return nil
+}
+
+func (a PostgresAgentStorage) Fetch(id string) (AgentRecord, error) {
+	ar := AgentRecord{}
+
+	conn, err := a.Database.getConnection()
+	if err != nil {
+		return ar, bugLog.Errorf("fetch agent: %+v", err)
+	}
+	defer a.Database.closeConnection(conn)
+
+	if err := conn.QueryRow(a.Database.Context,
+		"SELECT agent.identifier, agent.name, agent.key, agent.secret, account.identifier FROM agent JOIN account ON account.id = agent.account_id WHERE agent.identifier = $1 LIMIT 1",
+		id).Scan(
+		&ar.ID,
+		&ar.Name,
+		&ar.AgentCredentials.Key,
+		&ar.AgentCredentials.Secret,
+		&ar.AccountRecord.ID); err != nil {
+		return ar, bugLog.Errorf("fetch agent queryRow: %+v", err)
+	}
+
+	return ar, nil
+}
+
+func (a PostgresAgentStorage) Delete(id string) error {
+	conn, err := a.Database.getConnection()
+	if err != nil {
+		return bugLog.Errorf("delete agent: %+v", err)
+	}
+	defer a.Database.closeConnection(conn)
+
+	if _, err := conn.Exec(a.Database.Context,
+		"DELETE FROM agent WHERE identifier = $1",
+		id); err != nil {
+		return bugLog.Errorf("delete agent exec: %+v", err)
+	}
+
+	return nil
+}
@@ -1,19 +1,25 @@
 package database
 
 import (
+	"context"
+	"fmt"
+
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/bugfixes/celeste/internal/config"
 	bugLog "github.com/bugfixes/go-bugfixes/logs"
+	"github.com/jackc/pgx/v4"
 )
 
 type Database struct {
-	Config config.Config
+	Config  config.Config
+	Context context.Context
 }
 
 func New(c config.Config) *Database {
 	return &Database{
-		Config: c,
+		Config:  c,
+		Context: context.Background(),
 	}
 }
 
@@ -26,11 +32,27 @@ func (d Database) dynamoSession() (*dynamodb.DynamoDB, error) {
 	return dynamodb.New(sess), nil
 }
 
-//go:generate mockery --name=Storage
-type Storage interface {
-	Insert(data interface{}) error
-	Fetch(data interface{}) (interface{}, error)
-	Delete(data interface{}) error
+func (d Database) getConnection() (*pgx.Conn, error) {
+	conn, err := pgx.Connect(
+		d.Context,
+		fmt.Sprintf(
+			"postgres://%s:%s@%s:%s/%s",
+			d.Config.RDS.Username,
+			d.Config.RDS.Password,
+			d.Config.RDS.Hostname,
+			d.Config.RDS.Port,
+			d.Config.RDS.Database))
+	if err != nil {
+		return nil, bugLog.Errorf("getConnection: %+v", err)
+	}
+
+	return conn, nil
+}
+
+func (d Database) closeConnection(conn *pgx.Conn) {
+	if err := conn.Close(d.Context); err != nil {
+		bugLog.Debugf("close: %+v", err)
+	}
 }
 
 func dynamoError(e error) error {
@@ -1,55 +1,296 @@
 package database
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
+	"github.com/bugfixes/celeste/internal/config"
 	bugLog "github.com/bugfixes/go-bugfixes/logs"
 )
 
-type LogStorage struct {
+// ErrLogExists is what Store gives when a log with the same id is already stored, a sender retrying what it already sent
+var ErrLogExists = errors.New("log already stored")
+
+//go:generate mockery --name=LogStorage
+type LogStorage interface {
+	Store(data LogRecord) error
+	Query(filter LogFilter) ([]LogRecord, string, error)
+	Prune(now time.Time) (int, error)
+}
+
+type DynamoLogStorage struct {
 	Database Database
 }
 
+// dynamoTimeFormat is how the index keys are stored, fixed width so they sort as strings,
+// RFC3339Nano drops trailing zeros and would put 10:00:00Z after 10:00:00.5Z
+const dynamoTimeFormat = "2006-01-02T15:04:05.000000000Z07:00"
+
+func dynamoTime(t time.Time) *dynamodb.AttributeValue {
+	return &dynamodb.AttributeValue{
+		S: aws.String(t.UTC().Format(dynamoTimeFormat)),
+	}
+}
+
+// StackFrame is one call in a stack, the most recent call is the first frame
+type StackFrame struct {
+	Function string `json:"function"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	InApp    bool   `json:"in_app"`
+}
+
 type LogRecord struct {
-	ID         string    `json:"id"`
-	AgentID    string    `json:"agent_id"`
-	Level      string    `json:"level"`
-	Line       string    `json:"line"`
-	File       string    `json:"file"`
-	Stack      string    `json:"stack"`
-	LogFmt     string    `json:"log_fmt"`
-	Entry      string    `json:"entry"`
-	LoggedTime time.Time `json:"logged_time" dynamodbav:"-"`
-	Logged     string    `json:"logged"`
-}
-
-func NewLogStorage(d Database) *LogStorage {
-	return &LogStorage{
+	ID          string            `json:"id"`
+	AgentID     string            `json:"agent_id"`
+	Environment string            `json:"environment"`
+	Level       string            `json:"level"`
+	Line        string            `json:"line"`
+	File        string            `json:"file"`
+	Stack       string            `json:"stack"`
+	Frames      []StackFrame      `json:"frames"`
+	LogFmt      string            `json:"log_fmt"`
+	Fields      map[string]string `json:"fields"`
+	Entry       string            `json:"entry"`
+	LoggedTime  time.Time         `json:"logged_time" dynamodbav:"logged_time"`
+	Logged      string            `json:"logged"`
+	// Received is when celeste stored the log, a log can turn up long after it was logged so the tail follows this
+	Received time.Time `json:"received" dynamodbav:"received"`
+
+	// Expires is when the account's retention lets the log go, zero keeps it forever,
+	// dynamo is given it as the TTL
+	Expires time.Time `json:"expires" dynamodbav:"-"`
+	TTL     int64     `json:"-" dynamodbav:"ttl,omitempty"`
+}
+
+func NewLogStorage(d Database) LogStorage {
+	switch d.Config.StorageBackend {
+	case config.StoragePostgres:
+		return NewPostgresLogStorage(d)
+	case config.StorageMemory:
+		return NewMemoryLogStorage(d)
+	default:
+		return NewDynamoLogStorage(d)
+	}
+}
+
+func NewDynamoLogStorage(d Database) *DynamoLogStorage {
+	return &DynamoLogStorage{
 		Database: d,
 	}
 }
 
-func (l LogStorage) Store(data LogRecord) error {
+func (l DynamoLogStorage) Store(data LogRecord) error {
 	svc, err := l.Database.dynamoSession()
 	if err != nil {
 		return bugLog.Errorf("logStorage store dynamo: %+v", err)
 	}
 
+	if !data.Expires.IsZero() {
+		data.TTL = data.Expires.Unix()
+	}
 	av, err := dynamodbattribute.MarshalMap(data)
 	if err != nil {
 		return bugLog.Errorf("logStorage store marshal: %+v", err)
 	}
+	av["logged_time"] = dynamoTime(data.LoggedTime)
+	av["received"] = dynamoTime(data.Received)
 
-	_, err = svc.PutItem(&dynamodb.PutItemInput{
-		Item:      av,
-		TableName: aws.String(l.Database.Config.LogsTable),
-	})
-	if err != nil {
+	if _, err := svc.PutItem(&dynamodb.PutItemInput{
+		ConditionExpression: aws.String("attribute_not_exists(id)"),
+		Item:                av,
+		TableName:           aws.String(l.Database.Config.LogsTable),
+	}); err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return ErrLogExists
+		}
 		return bugLog.Errorf("logStorage store putItem: %+v", err)
 	}
 
 	return nil
 }
+
+// Query reads the agent's logs newest first off the agent index, the time range is the index's range key,
+// level, file and environment are left to the filter expression, the search and the fields are matched here,
+// the cursor is the key of the last log on the page, a filter on when logs were received reads the received index
+// nolint: gocyclo
+func (l DynamoLogStorage) Query(filter LogFilter) ([]LogRecord, string, error) {
+	lrs := []LogRecord{}
+
+	index, rangeKey := l.Database.Config.LogsIndex, "logged_time"
+	from, to := filter.From, filter.To
+	if !filter.Received.IsZero() {
+		index, rangeKey = l.Database.Config.LogsReceivedIndex, "received"
+		from, to = filter.Received, time.Time{}
+	}
+
+	startKey, err := decodeDynamoLogCursor(filter.Cursor, rangeKey)
+	if err != nil {
+		return lrs, "", bugLog.Errorf("logStorage query cursor: %+v", err)
+	}
+	if startKey != nil && aws.StringValue(startKey["agent_id"].S) != filter.AgentID {
+		return lrs, "", bugLog.Errorf("logStorage query cursor: not the agent's")
+	}
+
+	svc, err := l.Database.dynamoSession()
+	if err != nil {
+		return lrs, "", bugLog.Errorf("logStorage query dynamo: %+v", err)
+	}
+
+	keyCond := expression.Key("agent_id").Equal(expression.Value(filter.AgentID))
+	// reading by received leaves the logged time range to Matches
+	rk := expression.Key(rangeKey)
+	switch {
+	case !from.IsZero() && !to.IsZero():
+		keyCond = expression.KeyAnd(keyCond, rk.Between(expression.Value(dynamoTime(from)), expression.Value(dynamoTime(to))))
+	case !from.IsZero():
+		keyCond = expression.KeyAnd(keyCond, rk.GreaterThanEqual(expression.Value(dynamoTime(from))))
+	case !to.IsZero():
+		keyCond = expression.KeyAnd(keyCond, rk.LessThanEqual(expression.Value(dynamoTime(to))))
+	}
+
+	builder := expression.NewBuilder().WithKeyCondition(keyCond)
+	conds := []expression.ConditionBuilder{}
+	if filter.Level != "" {
+		conds = append(conds, expression.Name("level").Equal(expression.Value(filter.Level)))
+	}
+	if filter.File != "" {
+		conds = append(conds, expression.Name("file").Equal(expression.Value(filter.File)))
+	}
+	if filter.Environment != "" {
+		conds = append(conds, expression.Name("environment").Equal(expression.Value(filter.Environment)))
+	}
+	switch len(conds) {
+	case 0:
+	case 1:
+		builder = builder.WithFilter(conds[0])
+	default:
+		builder = builder.WithFilter(expression.And(conds[0], conds[1], conds[2:]...))
+	}
+	expr, err := builder.Build()
+	if err != nil {
+		return lrs, "", bugLog.Errorf("logStorage query build: %+v", err)
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultLogLimit
+	}
+	input := &dynamodb.QueryInput{
+		TableName:                 aws.String(l.Database.Config.LogsTable),
+		IndexName:                 aws.String(index),
+		ExpressionAttributeValues: expr.Values(),
+		ExpressionAttributeNames:  expr.Names(),
+		KeyConditionExpression:    expr.KeyCondition(),
+		FilterExpression:          expr.Filter(),
+		ExclusiveStartKey:         startKey,
+		ScanIndexForward:          aws.Bool(false),
+		Limit:                     aws.Int64(int64(limit)),
+	}
+
+	// the limit is applied before the filter, so a page can take more than one query to fill
+	now := time.Now()
+	more := false
+	var last map[string]*dynamodb.AttributeValue
+	for len(lrs) < limit {
+		result, err := svc.Query(input)
+		if err != nil {
+			return lrs, "", bugLog.Errorf("logStorage query: %+v", err)
+		}
+
+		for i, item := range result.Items {
+			lr := LogRecord{}
+			if err := dynamodbattribute.UnmarshalMap(item, &lr); err != nil {
+				return lrs, "", bugLog.Errorf("logStorage query unmarshal: %+v", err)
+			}
+			if lr.TTL > 0 {
+				lr.Expires = time.Unix(lr.TTL, 0)
+			}
+			// expired logs can outlive their TTL for a while before they are removed
+			if !lr.Expires.IsZero() && !lr.Expires.After(now) {
+				continue
+			}
+			if !filter.Matches(lr) {
+				continue
+			}
+
+			lrs = append(lrs, lr)
+			if len(lrs) == limit {
+				more = i < len(result.Items)-1 || len(result.LastEvaluatedKey) != 0
+				last = item
+				break
+			}
+		}
+
+		if len(result.LastEvaluatedKey) == 0 {
+			break
+		}
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+
+	if !more {
+		return lrs, "", nil
+	}
+
+	cursor, err := encodeDynamoLogCursor(last, rangeKey)
+	if err != nil {
+		return lrs, "", bugLog.Errorf("logStorage query encode cursor: %+v", err)
+	}
+
+	return lrs, cursor, nil
+}
+
+// encodeDynamoLogCursor is the key the next page starts after, the table's and the index's
+func encodeDynamoLogCursor(item map[string]*dynamodb.AttributeValue, rangeKey string) (string, error) {
+	key := map[string]string{}
+	for _, name := range []string{"id", "agent_id", rangeKey} {
+		if v, ok := item[name]; ok {
+			key[name] = aws.StringValue(v.S)
+		}
+	}
+	b, err := json.Marshal(key)
+	if err != nil {
+		return "", bugLog.Errorf("encodeDynamoLogCursor marshal: %+v", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func decodeDynamoLogCursor(cursor, rangeKey string) (map[string]*dynamodb.AttributeValue, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, bugLog.Errorf("decodeDynamoLogCursor decode: %+v", err)
+	}
+	key := map[string]string{}
+	if err := json.Unmarshal(b, &key); err != nil {
+		return nil, bugLog.Errorf("decodeDynamoLogCursor unmarshal: %+v", err)
+	}
+	if len(key) != 3 || key["id"] == "" || key["agent_id"] == "" || key[rangeKey] == "" {
+		return nil, bugLog.Errorf("decodeDynamoLogCursor: incomplete key")
+	}
+
+	startKey := map[string]*dynamodb.AttributeValue{}
+	for name, value := range key {
+		startKey[name] = &dynamodb.AttributeValue{
+			S: aws.String(value),
+		}
+	}
+
+	return startKey, nil
+}
+
+// Prune has nothing to do, dynamo removes expired logs itself from the TTL
+func (l DynamoLogStorage) Prune(now time.Time) (int, error) {
+	return 0, nil
+}
@@ -0,0 +1,189 @@
+package database
+
+import (
+	"encoding/base64"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	bugLog "github.com/bugfixes/go-bugfixes/logs"
+)
+
+const (
+	defaultLogLimit = 50
+	maxLogLimit     = 500
+
+	fieldParamPrefix = "field."
+)
+
+type LogFilter struct {
+	AgentID string
+
+	Level       string
+	Environment string
+	File        string
+	From        time.Time
+	To          time.Time
+	Search      string
+	// Received only keeps logs celeste stored at or after it, a tail's place
+	Received time.Time
+	// Fields all have to match the log's parsed fields, they come from field.<key>=<value> in the query
+	Fields map[string]string
+
+	Limit  int
+	Cursor string
+}
+
+// ParseLogFilter builds the filter from the query string of a log request
+func ParseLogFilter(agentID string, q url.Values) (LogFilter, error) {
+	f := LogFilter{
+		AgentID:     agentID,
+		Level:       q.Get("level"),
+		Environment: q.Get("environment"),
+		File:        q.Get("file"),
+		Search:      q.Get("q"),
+		Cursor:      q.Get("cursor"),
+		Limit:       defaultLogLimit,
+	}
+
+	times := map[string]*time.Time{
+		"from": &f.From,
+		"to":   &f.To,
+	}
+	for name, t := range times {
+		if v := q.Get(name); v != "" {
+			pt, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return f, bugLog.Errorf("parseLogFilter %s: %+v", name, err)
+			}
+			*t = pt
+		}
+	}
+
+	for k := range q {
+		if key := strings.TrimPrefix(k, fieldParamPrefix); key != k && key != "" {
+			if f.Fields == nil {
+				f.Fields = map[string]string{}
+			}
+			f.Fields[key] = q.Get(k)
+		}
+	}
+
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil {
+			return f, bugLog.Errorf("parseLogFilter limit: %+v", err)
+		}
+		f.Limit = limit
+	}
+	if f.Limit <= 0 {
+		f.Limit = defaultLogLimit
+	}
+	if f.Limit > maxLogLimit {
+		f.Limit = maxLogLimit
+	}
+
+	return f, nil
+}
+
+// Matches is the whole filter, backends that can't express part of it in their query use this for the rest,
+// the search is case insensitive over the entry
+// nolint: gocyclo
+func (f LogFilter) Matches(r LogRecord) bool {
+	if f.AgentID != "" && r.AgentID != f.AgentID {
+		return false
+	}
+	if f.Level != "" && r.Level != f.Level {
+		return false
+	}
+	if f.Environment != "" && r.Environment != f.Environment {
+		return false
+	}
+	if f.File != "" && r.File != f.File {
+		return false
+	}
+	if !f.From.IsZero() && r.LoggedTime.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && r.LoggedTime.After(f.To) {
+		return false
+	}
+	if !f.Received.IsZero() && r.Received.Before(f.Received) {
+		return false
+	}
+	if f.Search != "" && !strings.Contains(strings.ToLower(r.Entry), strings.ToLower(f.Search)) {
+		return false
+	}
+	for k, v := range f.Fields {
+		if fv, ok := r.Fields[k]; !ok || fv != v {
+			return false
+		}
+	}
+
+	return true
+}
+
+// pageLogs filters, orders and cuts out the page the cursor points at,
+// the cursor returned is empty once there are no more pages
+func pageLogs(lrs []LogRecord, filter LogFilter) ([]LogRecord, string, error) {
+	offset, err := decodeLogCursor(filter.Cursor)
+	if err != nil {
+		return []LogRecord{}, "", bugLog.Errorf("logStorage pageLogs cursor: %+v", err)
+	}
+
+	// expired logs can outlive their TTL for a while before they are removed
+	now := time.Now()
+	matched := []LogRecord{}
+	for _, lr := range lrs {
+		if !lr.Expires.IsZero() && !lr.Expires.After(now) {
+			continue
+		}
+		if filter.Matches(lr) {
+			matched = append(matched, lr)
+		}
+	}
+	sortByLogged(matched)
+
+	if offset >= len(matched) {
+		return []LogRecord{}, "", nil
+	}
+	end := offset + filter.Limit
+	if filter.Limit <= 0 || end >= len(matched) {
+		return matched[offset:], "", nil
+	}
+
+	return matched[offset:end], encodeLogCursor(end), nil
+}
+
+// sortByLogged puts the newest logs first, id keeps the order stable between pages
+func sortByLogged(lrs []LogRecord) {
+	sort.Slice(lrs, func(i, j int) bool {
+		if lrs[i].LoggedTime.Equal(lrs[j].LoggedTime) {
+			return lrs[i].ID < lrs[j].ID
+		}
+		return lrs[i].LoggedTime.After(lrs[j].LoggedTime)
+	})
+}
+
+func encodeLogCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(offset)))
+}
+
+func decodeLogCursor(cursor string) (int, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, bugLog.Errorf("decodeLogCursor decode: %+v", err)
+	}
+	offset, err := strconv.Atoi(string(b))
+	if err != nil {
+		return 0, bugLog.Errorf("decodeLogCursor atoi: %+v", err)
+	}
+
+	return offset, nil
+}
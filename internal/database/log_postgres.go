@@ -0,0 +1,199 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	bugLog "github.com/bugfixes/go-bugfixes/logs"
+)
+
+type PostgresLogStorage struct {
+	Database Database
+}
+
+func NewPostgresLogStorage(d Database) *PostgresLogStorage {
+	return &PostgresLogStorage{
+		Database: d,
+	}
+}
+
+func (l PostgresLogStorage) Store(data LogRecord) error {
+	frames, err := json.Marshal(data.Frames)
+	if err != nil {
+		return bugLog.Errorf("logStorage store frames: %+v", err)
+	}
+	fields, err := json.Marshal(data.Fields)
+	if err != nil {
+		return bugLog.Errorf("logStorage store fields: %+v", err)
+	}
+
+	conn, err := l.Database.getConnection()
+	if err != nil {
+		return bugLog.Errorf("logStorage store connection: %+v", err)
+	}
+	defer l.Database.closeConnection(conn)
+
+	tag, err := conn.Exec(l.Database.Context,
+		"INSERT INTO log (id, agent_id, environment, level, line, file, stack, frames, log_fmt, fields, entry, logged, expires, received) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14) ON CONFLICT (id) DO NOTHING",
+		data.ID,
+		data.AgentID,
+		data.Environment,
+		data.Level,
+		data.Line,
+		data.File,
+		data.Stack,
+		string(frames),
+		data.LogFmt,
+		string(fields),
+		data.Entry,
+		data.LoggedTime,
+		nullTime(data.Expires),
+		data.Received)
+	if err != nil {
+		return bugLog.Errorf("logStorage store exec: %+v", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrLogExists
+	}
+
+	return nil
+}
+
+// likeEscape makes a search match its wildcards and backslashes as they are
+var likeEscape = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// Query pages in SQL, the cursor is the offset the same as the other backends
+func (l PostgresLogStorage) Query(filter LogFilter) ([]LogRecord, string, error) {
+	lrs := []LogRecord{}
+
+	offset, err := decodeLogCursor(filter.Cursor)
+	if err != nil {
+		return lrs, "", bugLog.Errorf("logStorage query cursor: %+v", err)
+	}
+
+	conds := []string{"agent_id = $1", "(expires IS NULL OR expires > now())"}
+	args := []interface{}{filter.AgentID}
+	add := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+	if filter.Level != "" {
+		add("level = $%d", filter.Level)
+	}
+	if filter.Environment != "" {
+		add("environment = $%d", filter.Environment)
+	}
+	if filter.File != "" {
+		add("file = $%d", filter.File)
+	}
+	if !filter.From.IsZero() {
+		add("logged >= $%d", filter.From)
+	}
+	if !filter.To.IsZero() {
+		add("logged <= $%d", filter.To)
+	}
+	if !filter.Received.IsZero() {
+		add("received >= $%d", filter.Received)
+	}
+	if filter.Search != "" {
+		add(`entry ILIKE '%%' || $%d || '%%' ESCAPE '\'`, likeEscape.Replace(filter.Search))
+	}
+	// containment, unlike ->>, is something the GIN index on fields can answer
+	for k, v := range filter.Fields {
+		args = append(args, k, v)
+		conds = append(conds, fmt.Sprintf("fields @> jsonb_build_object($%d::text, $%d::text)", len(args)-1, len(args)))
+	}
+	args = append(args, filter.Limit+1, offset)
+
+	conn, err := l.Database.getConnection()
+	if err != nil {
+		return lrs, "", bugLog.Errorf("logStorage query connection: %+v", err)
+	}
+	defer l.Database.closeConnection(conn)
+
+	rows, err := conn.Query(l.Database.Context, fmt.Sprintf(
+		"SELECT id, agent_id, COALESCE(environment, ''), level, line, file, stack, COALESCE(frames, ''), log_fmt, COALESCE(fields::text, ''), entry, logged, expires, COALESCE(received, logged) FROM log WHERE %s ORDER BY logged DESC, id LIMIT $%d OFFSET $%d",
+		strings.Join(conds, " AND "),
+		len(args)-1,
+		len(args)), args...)
+	if err != nil {
+		return lrs, "", bugLog.Errorf("logStorage query: %+v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		lr := LogRecord{}
+		var expires *time.Time
+		frames, fields := "", ""
+		if err := rows.Scan(
+			&lr.ID,
+			&lr.AgentID,
+			&lr.Environment,
+			&lr.Level,
+			&lr.Line,
+			&lr.File,
+			&lr.Stack,
+			&frames,
+			&lr.LogFmt,
+			&fields,
+			&lr.Entry,
+			&lr.LoggedTime,
+			&expires,
+			&lr.Received); err != nil {
+			return lrs, "", bugLog.Errorf("logStorage query scan: %+v", err)
+		}
+		if expires != nil {
+			lr.Expires = *expires
+		}
+		if frames != "" {
+			if err := json.Unmarshal([]byte(frames), &lr.Frames); err != nil {
+				return lrs, "", bugLog.Errorf("logStorage query frames: %+v", err)
+			}
+		}
+		if fields != "" {
+			if err := json.Unmarshal([]byte(fields), &lr.Fields); err != nil {
+				return lrs, "", bugLog.Errorf("logStorage query fields: %+v", err)
+			}
+		}
+		lr.Logged = lr.LoggedTime.Format(l.Database.Config.DateFormat)
+
+		lrs = append(lrs, lr)
+	}
+	if err := rows.Err(); err != nil {
+		return lrs, "", bugLog.Errorf("logStorage query rows: %+v", err)
+	}
+
+	if len(lrs) > filter.Limit {
+		return lrs[:filter.Limit], encodeLogCursor(offset + filter.Limit), nil
+	}
+
+	return lrs, "", nil
+}
+
+// Prune deletes the logs the account retention has run out on
+func (l PostgresLogStorage) Prune(now time.Time) (int, error) {
+	conn, err := l.Database.getConnection()
+	if err != nil {
+		return 0, bugLog.Errorf("logStorage prune connection: %+v", err)
+	}
+	defer l.Database.closeConnection(conn)
+
+	tag, err := conn.Exec(l.Database.Context,
+		"DELETE FROM log WHERE expires IS NOT NULL AND expires <= $1",
+		now)
+	if err != nil {
+		return 0, bugLog.Errorf("logStorage prune exec: %+v", err)
+	}
+
+	return int(tag.RowsAffected()), nil
+}
+
+// nullTime keeps a zero time out of the table as a null
+func nullTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t
+}
@@ -0,0 +1,199 @@
+package database
+
+import (
+	"sync"
+	"time"
+
+	bugLog "github.com/bugfixes/go-bugfixes/logs"
+)
+
+type memory struct {
+	sync.RWMutex
+
+	logs     []LogRecord
+	accounts map[string]AccountRecord
+	agents   map[string]AgentRecord
+}
+
+// sharedMemory is kept for the life of the process so every memory storage sees the same records
+var sharedMemory = &memory{
+	accounts: map[string]AccountRecord{},
+	agents:   map[string]AgentRecord{},
+}
+
+// ResetMemory empties the logs, accounts and agents every memory storage shares, it is for tests
+func ResetMemory() {
+	sharedMemory.Lock()
+	defer sharedMemory.Unlock()
+
+	sharedMemory.logs = nil
+	sharedMemory.accounts = map[string]AccountRecord{}
+	sharedMemory.agents = map[string]AgentRecord{}
+}
+
+type MemoryLogStorage struct {
+	Database Database
+
+	memory *memory
+}
+
+func NewMemoryLogStorage(d Database) *MemoryLogStorage {
+	return &MemoryLogStorage{
+		Database: d,
+		memory:   sharedMemory,
+	}
+}
+
+func (l MemoryLogStorage) Store(data LogRecord) error {
+	l.memory.Lock()
+	defer l.memory.Unlock()
+
+	for _, lr := range l.memory.logs {
+		if lr.ID == data.ID {
+			return ErrLogExists
+		}
+	}
+	l.memory.logs = append(l.memory.logs, data)
+
+	return nil
+}
+
+func (l MemoryLogStorage) Query(filter LogFilter) ([]LogRecord, string, error) {
+	l.memory.RLock()
+	lrs := make([]LogRecord, len(l.memory.logs))
+	copy(lrs, l.memory.logs)
+	l.memory.RUnlock()
+
+	return pageLogs(lrs, filter)
+}
+
+func (l MemoryLogStorage) Prune(now time.Time) (int, error) {
+	l.memory.Lock()
+	defer l.memory.Unlock()
+
+	kept := l.memory.logs[:0]
+	for _, lr := range l.memory.logs {
+		if !lr.Expires.IsZero() && !lr.Expires.After(now) {
+			continue
+		}
+		kept = append(kept, lr)
+	}
+	pruned := len(l.memory.logs) - len(kept)
+	l.memory.logs = kept
+
+	return pruned, nil
+}
+
+type MemoryAccountStorage struct {
+	Database Database
+
+	memory *memory
+}
+
+func NewMemoryAccountStorage(d Database) *MemoryAccountStorage {
+	return &MemoryAccountStorage{
+		Database: d,
+		memory:   sharedMemory,
+	}
+}
+
+func (a MemoryAccountStorage) Insert(data AccountRecord) error {
+	a.memory.Lock()
+	defer a.memory.Unlock()
+
+	a.memory.accounts[data.ID] = data
+
+	return nil
+}
+
+func (a MemoryAccountStorage) Fetch(id string) (AccountRecord, error) {
+	a.memory.RLock()
+	defer a.memory.RUnlock()
+
+	ar, ok := a.memory.accounts[id]
+	if !ok {
+		return AccountRecord{}, bugLog.Errorf("fetch account: %s not found", id)
+	}
+
+	return ar, nil
+}
+
+func (a MemoryAccountStorage) Delete(id string) error {
+	a.memory.Lock()
+	defer a.memory.Unlock()
+
+	delete(a.memory.accounts, id)
+
+	return nil
+}
+
+func (a MemoryAccountStorage) UpdateRetention(id string, r Retention) error {
+	a.memory.Lock()
+	defer a.memory.Unlock()
+
+	ar, ok := a.memory.accounts[id]
+	if !ok {
+		return bugLog.Errorf("updateRetention account: %s not found", id)
+	}
+	ar.Retention = r
+	a.memory.accounts[id] = ar
+
+	return nil
+}
+
+func (a MemoryAccountStorage) UpdateRules(id string, r Rules) error {
+	a.memory.Lock()
+	defer a.memory.Unlock()
+
+	ar, ok := a.memory.accounts[id]
+	if !ok {
+		return bugLog.Errorf("updateRules account: %s not found", id)
+	}
+	ar.Rules = r
+	a.memory.accounts[id] = ar
+
+	return nil
+}
+
+type MemoryAgentStorage struct {
+	Database Database
+
+	memory *memory
+}
+
+func NewMemoryAgentStorage(d Database) *MemoryAgentStorage {
+	return &MemoryAgentStorage{
+		Database: d,
+		memory:   sharedMemory,
+	}
+}
+
+func (a MemoryAgentStorage) Insert(data AgentRecord) error {
+	a.memory.Lock()
+	defer a.memory.Unlock()
+
+	a.memory.agents[data.ID] = data
+
+	return nil
+}
+
+func (a MemoryAgentStorage) Fetch(id string) (AgentRecord, error) {
+	a.memory.RLock()
+	defer a.memory.RUnlock()
+
+	ar, ok := a.memory.agents[id]
+	if !ok {
+		return AgentRecord{}, bugLog.Errorf("fetch agent: %s not found", id)
+	}
+
+	return ar, nil
+}
+
+func (a MemoryAgentStorage) Delete(id string) error {
+	a.memory.Lock()
+	defer a.memory.Unlock()
+
+	delete(a.memory.agents, id)
+
+	return nil
+}
@@ -0,0 +1,40 @@
+package database
+
+import (
+	"time"
+
+	bugLog "github.com/bugfixes/go-bugfixes/logs"
+)
+
+// RetentionDefault is the retention key for any level that doesn't have its own
+const RetentionDefault = "default"
+
+// Retention is how many days an account keeps its logs for each level,
+// a level with no days, and no default, is kept forever
+type Retention map[string]int
+
+func (r Retention) Validate() error {
+	for level, days := range r {
+		if level == "" {
+			return bugLog.Errorf("retention: empty level")
+		}
+		if days < 0 {
+			return bugLog.Errorf("retention: %s has negative days", level)
+		}
+	}
+
+	return nil
+}
+
+// Expires is when a log at the level, logged at logged, should go, zero when it is kept forever
+func (r Retention) Expires(level string, logged time.Time) time.Time {
+	days, ok := r[level]
+	if !ok {
+		days = r[RetentionDefault]
+	}
+	if days <= 0 {
+		return time.Time{}
+	}
+
+	return logged.AddDate(0, 0, days)
+}
@@ -0,0 +1,100 @@
+package database
+
+import (
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	bugLog "github.com/bugfixes/go-bugfixes/logs"
+)
+
+const (
+	RuleReport = "report"
+	RuleIgnore = "ignore"
+)
+
+// Rule is an account's alert rule, every condition that is set has to hold for it to match,
+// an AgentID only applies it to that agent's bugs
+type Rule struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	AgentID string `json:"agent_id,omitempty" dynamodbav:"agent_id,omitempty"`
+	Action  string `json:"action"`
+
+	Levels       []string `json:"levels,omitempty" dynamodbav:"levels,omitempty"`
+	Environments []string `json:"environments,omitempty" dynamodbav:"environments,omitempty"`
+	FileGlob     string   `json:"file_glob,omitempty" dynamodbav:"file_glob,omitempty"`
+	// MinOccurrences is counted inside Window, or over the bug's life without one
+	MinOccurrences int    `json:"min_occurrences,omitempty" dynamodbav:"min_occurrences,omitempty"`
+	Window         string `json:"window,omitempty" dynamodbav:"window,omitempty"`
+	FirstReport    bool   `json:"first_report,omitempty" dynamodbav:"first_report,omitempty"`
+	Regressed      bool   `json:"regressed,omitempty" dynamodbav:"regressed,omitempty"`
+	// FirstSeenBefore matches bugs that were first seen at least that long ago
+	FirstSeenBefore string `json:"first_seen_before,omitempty" dynamodbav:"first_seen_before,omitempty"`
+
+	// Cooldown is how long a bug the rule reported isn't sent again on a channel, unless its state changes
+	Cooldown string `json:"cooldown,omitempty" dynamodbav:"cooldown,omitempty"`
+}
+
+// Rules are checked in order, the first one that matches decides
+type Rules []Rule
+
+// ParseDuration is time.ParseDuration that also takes whole days, e.g. 7d
+func ParseDuration(s string) (time.Duration, error) {
+	if days := strings.TrimSuffix(s, "d"); days != s {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, bugLog.Errorf("parseDuration days: %+v", err)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+
+	return time.ParseDuration(s)
+}
+
+func (r Rule) Validate() error {
+	if r.Action != RuleReport && r.Action != RuleIgnore {
+		return bugLog.Errorf("rule %s: action has to be %s or %s", r.Name, RuleReport, RuleIgnore)
+	}
+	if r.MinOccurrences < 0 {
+		return bugLog.Errorf("rule %s: negative min_occurrences", r.Name)
+	}
+	for _, d := range []string{r.Window, r.FirstSeenBefore, r.Cooldown} {
+		if d == "" {
+			continue
+		}
+		if _, err := ParseDuration(d); err != nil {
+			return bugLog.Errorf("rule %s: %+v", r.Name, err)
+		}
+	}
+	if r.FileGlob != "" {
+		if _, err := path.Match(r.FileGlob, ""); err != nil {
+			return bugLog.Errorf("rule %s file_glob: %+v", r.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func (rs Rules) Validate() error {
+	for _, r := range rs {
+		if err := r.Validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ForAgent are the rules that apply to the agent's bugs, in order
+func (rs Rules) ForAgent(agentID string) Rules {
+	agentRules := Rules{}
+	for _, r := range rs {
+		if r.AgentID == "" || r.AgentID == agentID {
+			agentRules = append(agentRules, r)
+		}
+	}
+
+	return agentRules
+}
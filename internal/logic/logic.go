@@ -4,6 +4,8 @@ import (
 	"time"
 
 	"github.com/bugfixes/celeste/internal/config"
+	"github.com/bugfixes/celeste/internal/database"
+	bugLog "github.com/bugfixes/go-bugfixes/logs"
 )
 
 type Logic struct {
@@ -14,6 +16,21 @@ type LogicBug struct {
 	LastReported  time.Time
 	FirstReported time.Time
 	TimesReported int
+	// Occurrences are the reports a day, keyed by database day format, rule windows are counted from them
+	Occurrences map[string]int
+	// Minutes and Hours are the reports a minute and an hour, the spike check and windows under a day are worked out from them
+	Minutes map[string]int
+	Hours   map[string]int
+
+	AccountID   string
+	AgentID     string
+	Level       string
+	File        string
+	Environment string
+
+	// Muted bugs, ignored or snoozed, are never reported, Regressed ones always are
+	Muted     bool
+	Regressed bool
 }
 
 func NewLogic(c config.Config) *Logic {
@@ -22,69 +39,45 @@ func NewLogic(c config.Config) *Logic {
 	}
 }
 
-func firstReport(when time.Time) bool {
-	return when == time.Now()
-}
-
-func reportedMoreThanNTimes(times, n int) bool {
-	return times > n
-}
-
-func sinceLessThanDay(when time.Time) bool {
-	return time.Since(when) > 24*time.Hour
-}
-
-func sinceLessThanWeek(when time.Time) bool {
-	return time.Since(when) > 7*24*time.Hour
-}
-
-func sinceLessThanMonth(when time.Time) bool {
-	return time.Since(when) > time.Since(time.Now().AddDate(0, -1, 0))
-}
-
-func sinceLessThanHour(when time.Time) bool {
-	return 1*time.Hour > time.Since(when)
-}
+func (l *Logic) quietEnvironment(environment string) bool {
+	for _, e := range l.Config.Reporting.QuietEnvironments {
+		if environment != "" && e == environment {
+			return true
+		}
+	}
 
-func sinceMoreThanMonth(when time.Time) bool {
-	return time.Since(time.Now().AddDate(0, -1, 0)) > time.Since(when)
+	return false
 }
 
-// ShouldWeReport
-// nolint: gocyclo
-func (l *Logic) ShouldWeReport(lb LogicBug) bool {
-	// no need to report everything is hosted locally
-	if l.Config.KeepLocal {
+// ShouldWeTicket is whether the bug gets a ticket, muted bugs and those from a quiet environment don't
+func (l *Logic) ShouldWeTicket(lb LogicBug) bool {
+	if lb.Muted {
 		return false
 	}
 
-	if reportedMoreThanNTimes(lb.TimesReported, 10) {
-		return true
-	}
-
-	if firstReport(lb.FirstReported) {
-		return true
-	}
-
-	if sinceLessThanHour(lb.LastReported) {
-		return true
-	}
+	return !l.quietEnvironment(lb.Environment)
+}
 
-	if sinceLessThanDay(lb.LastReported) && reportedMoreThanNTimes(lb.TimesReported, 5) {
-		return true
+// Rules are the account's rules that apply to the agent, an account that can't be found has none
+func (l *Logic) Rules(accountID, agentID string) database.Rules {
+	if accountID == "" {
+		return database.Rules{}
 	}
 
-	if sinceLessThanWeek(lb.LastReported) && reportedMoreThanNTimes(lb.TimesReported, 5) {
-		return true
+	ar, err := database.NewAccountStorage(*database.New(l.Config)).Fetch(accountID)
+	if err != nil {
+		bugLog.Debugf("logic rules fetch: %+v", err)
+		return database.Rules{}
 	}
 
-	if sinceLessThanMonth(lb.LastReported) && reportedMoreThanNTimes(5, lb.TimesReported) {
-		return true
-	}
+	return ar.Rules.ForAgent(agentID)
+}
 
-	if sinceMoreThanMonth(lb.FirstReported) {
-		return true
-	}
+// Decision runs the account's rules, then the default ones, over the bug
+func (l *Logic) Decision(lb LogicBug) Decision {
+	return l.Decide(l.Rules(lb.AccountID, lb.AgentID), lb, time.Now())
+}
 
-	return false
+func (l *Logic) ShouldWeReport(lb LogicBug) bool {
+	return l.Decision(lb).Report
 }
@@ -0,0 +1,219 @@
+package logic
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/bugfixes/celeste/internal/database"
+)
+
+// occurrenceDay is the key of LogicBug.Occurrences, the same as the bug's occurrence day format
+const occurrenceDay = "2006-01-02"
+
+// minuteWindow is the longest window the minutes cover, they are kept for two hours
+const minuteWindow = 2 * time.Hour
+
+// DefaultRules run after the account's own, an account that wants none of them ends its rules with
+// an ignore rule that has no conditions
+var DefaultRules = database.Rules{
+	{
+		ID:        "default-regressed",
+		Name:      "regressed",
+		Action:    database.RuleReport,
+		Regressed: true,
+	},
+	{
+		ID:          "default-first-report",
+		Name:        "first report",
+		Action:      database.RuleReport,
+		FirstReport: true,
+	},
+	{
+		ID:             "default-more-than-10",
+		Name:           "more than 10 reports",
+		Action:         database.RuleReport,
+		MinOccurrences: 11,
+		Cooldown:       "24h",
+	},
+	{
+		ID:             "default-more-than-5-a-day",
+		Name:           "more than 5 reports in a day",
+		Action:         database.RuleReport,
+		MinOccurrences: 6,
+		Window:         "24h",
+		Cooldown:       "6h",
+	},
+	{
+		ID:              "default-older-than-a-month",
+		Name:            "first seen over a month ago",
+		Action:          database.RuleReport,
+		FirstSeenBefore: "30d",
+		Cooldown:        "7d",
+	},
+}
+
+// RuleResult is how one rule went, Failed says which of its conditions didn't hold
+type RuleResult struct {
+	Rule    database.Rule `json:"rule"`
+	Default bool          `json:"default"`
+	Matched bool          `json:"matched"`
+	Decided bool          `json:"decided"`
+	Failed  []string      `json:"failed,omitempty"`
+}
+
+// Decision is whether to report the bug, and why, every rule is in Results even after one has decided,
+// Cooldown is the deciding rule's, or the default one
+type Decision struct {
+	Report   bool          `json:"report"`
+	Rule     string        `json:"rule,omitempty"`
+	Reason   string        `json:"reason"`
+	Cooldown time.Duration `json:"-"`
+	Results  []RuleResult  `json:"results"`
+}
+
+func ruleName(r database.Rule) string {
+	if r.Name != "" {
+		return r.Name
+	}
+
+	return r.ID
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// fileMatches takes the glob against the whole file, then the file's name, so *.go matches anywhere
+func fileMatches(glob, file string) bool {
+	if ok, _ := path.Match(glob, file); ok {
+		return true
+	}
+	ok, _ := path.Match(glob, path.Base(file))
+
+	return ok
+}
+
+// occurrencesSince counts the reports from the bucket since falls in, a window under a day is counted from
+// the bug's minutes or hours, a longer one from its days, so it is only as exact as the bucket
+func occurrencesSince(lb LogicBug, since, now time.Time) int {
+	switch window := now.Sub(since); {
+	case window <= minuteWindow:
+		return countFrom(lb.Minutes, since.UTC().Format(rateMinute))
+	case window < 24*time.Hour:
+		return countFrom(lb.Hours, since.UTC().Format(rateHour))
+	}
+
+	return countFrom(lb.Occurrences, since.UTC().Format(occurrenceDay))
+}
+
+func countFrom(buckets map[string]int, from string) int {
+	count := 0
+	for bucket, n := range buckets {
+		if bucket >= from {
+			count += n
+		}
+	}
+
+	return count
+}
+
+// Check runs one rule over the bug
+// nolint: gocyclo
+func Check(r database.Rule, lb LogicBug, now time.Time) RuleResult {
+	res := RuleResult{
+		Rule: r,
+	}
+
+	if len(r.Levels) > 0 && !contains(r.Levels, lb.Level) {
+		res.Failed = append(res.Failed, fmt.Sprintf("level %q is not one of %s", lb.Level, strings.Join(r.Levels, ", ")))
+	}
+	if len(r.Environments) > 0 && !contains(r.Environments, lb.Environment) {
+		res.Failed = append(res.Failed, fmt.Sprintf("environment %q is not one of %s", lb.Environment, strings.Join(r.Environments, ", ")))
+	}
+	if r.FileGlob != "" && !fileMatches(r.FileGlob, lb.File) {
+		res.Failed = append(res.Failed, fmt.Sprintf("file %q doesn't match %s", lb.File, r.FileGlob))
+	}
+	if r.FirstReport && lb.TimesReported != 1 {
+		res.Failed = append(res.Failed, fmt.Sprintf("reported %d times, not the first report", lb.TimesReported))
+	}
+	if r.Regressed && !lb.Regressed {
+		res.Failed = append(res.Failed, "not regressed")
+	}
+	if r.MinOccurrences > 0 {
+		count, within := lb.TimesReported, ""
+		if window, err := database.ParseDuration(r.Window); err == nil && r.Window != "" {
+			count, within = occurrencesSince(lb, now.Add(-window), now), " in "+r.Window
+		}
+		if count < r.MinOccurrences {
+			res.Failed = append(res.Failed, fmt.Sprintf("reported %d times%s, needs %d", count, within, r.MinOccurrences))
+		}
+	}
+	if r.FirstSeenBefore != "" {
+		if age, err := database.ParseDuration(r.FirstSeenBefore); err == nil && (lb.FirstReported.IsZero() || now.Sub(lb.FirstReported) < age) {
+			res.Failed = append(res.Failed, fmt.Sprintf("first seen %s ago, needs %s", now.Sub(lb.FirstReported).Round(time.Minute), r.FirstSeenBefore))
+		}
+	}
+
+	res.Matched = len(res.Failed) == 0
+
+	return res
+}
+
+// Decide runs the rules, then the default ones, the first that matches decides,
+// local only, muted bugs and quiet environments are never reported whatever the rules say
+func (l *Logic) Decide(rules database.Rules, lb LogicBug, now time.Time) Decision {
+	d := Decision{
+		Results: []RuleResult{},
+	}
+
+	switch {
+	case l.Config.KeepLocal:
+		d.Reason = "everything is kept local"
+		return d
+	case lb.Muted:
+		d.Reason = "the bug is muted"
+		return d
+	case l.quietEnvironment(lb.Environment):
+		d.Reason = fmt.Sprintf("%s is a quiet environment", lb.Environment)
+		return d
+	}
+
+	check := func(r database.Rule, isDefault bool) {
+		res := Check(r, lb, now)
+		res.Default = isDefault
+		if res.Matched && d.Rule == "" {
+			res.Decided = true
+			d.Rule = ruleName(r)
+			d.Report = r.Action == database.RuleReport
+			d.Reason = fmt.Sprintf("rule %q matched, %s", d.Rule, r.Action)
+			d.Cooldown = l.Config.Reporting.NotifyCooldown
+			if cooldown, err := database.ParseDuration(r.Cooldown); err == nil && r.Cooldown != "" {
+				d.Cooldown = cooldown
+			}
+			if d.Report && d.Cooldown > 0 {
+				d.Reason += fmt.Sprintf(", at most once every %s on a channel", d.Cooldown)
+			}
+		}
+		d.Results = append(d.Results, res)
+	}
+	for _, r := range rules {
+		check(r, false)
+	}
+	for _, r := range DefaultRules {
+		check(r, true)
+	}
+
+	if d.Rule == "" {
+		d.Reason = "no rule matched"
+	}
+
+	return d
+}
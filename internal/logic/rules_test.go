@@ -0,0 +1,189 @@
+package logic_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bugfixes/celeste/internal/config"
+	"github.com/bugfixes/celeste/internal/database"
+	"github.com/bugfixes/celeste/internal/logic"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecide(t *testing.T) {
+	now := time.Date(2021, 6, 10, 12, 0, 0, 0, time.UTC)
+	today := now.Format("2006-01-02")
+	lastWeek := now.AddDate(0, 0, -7).Format("2006-01-02")
+	minute := func(ago time.Duration) string { return now.Add(-ago).Format("2006-01-02T15:04") }
+	hour := func(ago time.Duration) string { return now.Add(-ago).Format("2006-01-02T15") }
+
+	tests := []struct {
+		name   string
+		config config.Config
+		rules  database.Rules
+		bug    logic.LogicBug
+		report bool
+		rule   string
+	}{
+		{
+			name:   "first report",
+			bug:    logic.LogicBug{TimesReported: 1, FirstReported: now},
+			report: true,
+			rule:   "first report",
+		},
+		{
+			name: "second report",
+			bug:  logic.LogicBug{TimesReported: 2, FirstReported: now},
+		},
+		{
+			name:   "busy day",
+			bug:    logic.LogicBug{TimesReported: 6, FirstReported: now, Occurrences: map[string]int{today: 6}},
+			report: true,
+			rule:   "more than 5 reports in a day",
+		},
+		{
+			name: "busy last week",
+			bug:  logic.LogicBug{TimesReported: 6, FirstReported: now, Occurrences: map[string]int{lastWeek: 6}},
+		},
+		{
+			name:   "old bug",
+			bug:    logic.LogicBug{TimesReported: 2, FirstReported: now.AddDate(0, -2, 0)},
+			report: true,
+			rule:   "first seen over a month ago",
+		},
+		{
+			name:   "regressed",
+			bug:    logic.LogicBug{TimesReported: 2, FirstReported: now, Regressed: true},
+			report: true,
+			rule:   "regressed",
+		},
+		{
+			name: "muted",
+			bug:  logic.LogicBug{TimesReported: 1, FirstReported: now, Muted: true},
+		},
+		{
+			name: "quiet environment",
+			config: config.Config{
+				Reporting: config.Reporting{
+					QuietEnvironments: []string{"staging"},
+				},
+			},
+			bug: logic.LogicBug{TimesReported: 1, FirstReported: now, Environment: "staging"},
+		},
+		{
+			name: "ignore rule first",
+			rules: database.Rules{
+				{Name: "no debug", Action: database.RuleIgnore, Levels: []string{"debug"}},
+			},
+			bug:  logic.LogicBug{TimesReported: 1, FirstReported: now, Level: "debug"},
+			rule: "no debug",
+		},
+		{
+			name: "file glob",
+			rules: database.Rules{
+				{Name: "payments", Action: database.RuleReport, FileGlob: "payments/*.go"},
+			},
+			bug:    logic.LogicBug{TimesReported: 2, FirstReported: now, File: "payments/card.go"},
+			report: true,
+			rule:   "payments",
+		},
+		{
+			name: "file glob on the name",
+			rules: database.Rules{
+				{Name: "handlers", Action: database.RuleReport, FileGlob: "*_handler.go"},
+			},
+			bug:    logic.LogicBug{TimesReported: 2, FirstReported: now, File: "/build/app/card_handler.go"},
+			report: true,
+			rule:   "handlers",
+		},
+		{
+			name: "every condition has to hold",
+			rules: database.Rules{
+				{Name: "prod errors", Action: database.RuleReport, Levels: []string{"error"}, Environments: []string{"production"}},
+			},
+			bug: logic.LogicBug{TimesReported: 2, FirstReported: now, Level: "error", Environment: "staging"},
+		},
+		{
+			name: "occurrences in a window",
+			rules: database.Rules{
+				{Name: "3 this week", Action: database.RuleReport, MinOccurrences: 3, Window: "7d"},
+			},
+			bug:    logic.LogicBug{TimesReported: 3, FirstReported: now, Occurrences: map[string]int{today: 1, lastWeek: 2}},
+			report: true,
+			rule:   "3 this week",
+		},
+		{
+			name: "occurrences in an hour are counted by the minute",
+			rules: database.Rules{
+				{Name: "3 an hour", Action: database.RuleReport, MinOccurrences: 3, Window: "1h"},
+			},
+			bug: logic.LogicBug{
+				TimesReported: 5,
+				FirstReported: now,
+				Occurrences:   map[string]int{today: 5},
+				Minutes:       map[string]int{minute(10 * time.Minute): 1, minute(90 * time.Minute): 4},
+			},
+		},
+		{
+			name: "occurrences in hours are counted by the hour",
+			rules: database.Rules{
+				{Name: "3 in 6 hours", Action: database.RuleReport, MinOccurrences: 3, Window: "6h"},
+			},
+			bug: logic.LogicBug{
+				TimesReported: 7,
+				FirstReported: now,
+				Occurrences:   map[string]int{today: 7},
+				Hours:         map[string]int{hour(time.Hour): 2, hour(3 * time.Hour): 1, hour(10 * time.Hour): 4},
+			},
+			report: true,
+			rule:   "3 in 6 hours",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			d := logic.NewLogic(test.config).Decide(test.rules, test.bug, now)
+			if passed := assert.Equal(t, test.report, d.Report); !passed {
+				t.Errorf("decide report expect: %v, got: %+v", test.report, d)
+			}
+			if passed := assert.Equal(t, test.rule, d.Rule); !passed {
+				t.Errorf("decide rule expect: %v, got: %v", test.rule, d.Rule)
+			}
+		})
+	}
+}
+
+func TestRulesForAgent(t *testing.T) {
+	rules := database.Rules{
+		{ID: "all", Action: database.RuleReport},
+		{ID: "bob", AgentID: "bob", Action: database.RuleIgnore},
+		{ID: "alice", AgentID: "alice", Action: database.RuleIgnore},
+	}
+
+	got := rules.ForAgent("bob")
+	if passed := assert.Equal(t, database.Rules{rules[0], rules[1]}, got); !passed {
+		t.Errorf("forAgent got: %+v", got)
+	}
+}
+
+func TestRuleValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		rule  database.Rule
+		valid bool
+	}{
+		{name: "valid", rule: database.Rule{Action: database.RuleReport, Window: "7d", FileGlob: "*.go"}, valid: true},
+		{name: "no action", rule: database.Rule{}},
+		{name: "bad window", rule: database.Rule{Action: database.RuleReport, Window: "a week"}},
+		{name: "bad glob", rule: database.Rule{Action: database.RuleIgnore, FileGlob: "[*.go"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.rule.Validate()
+			if passed := assert.Equal(t, test.valid, err == nil); !passed {
+				t.Errorf("validate err: %+v", err)
+			}
+		})
+	}
+}
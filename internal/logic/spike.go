@@ -0,0 +1,65 @@
+package logic
+
+import (
+	"fmt"
+	"time"
+)
+
+// rateMinute and rateHour are the keys of LogicBug.Minutes and LogicBug.Hours, the same as the bug's rate formats
+const (
+	rateMinute = "2006-01-02T15:04"
+	rateHour   = "2006-01-02T15"
+)
+
+// Spike is the bug's reports in the last hour against its usual reports an hour
+type Spike struct {
+	Rate     int     `json:"rate"`
+	Baseline float64 `json:"baseline"`
+	Hours    int     `json:"hours"`
+}
+
+func (s Spike) String() string {
+	return fmt.Sprintf("%d reports in the last hour, against %.1f an hour over the %d hours before", s.Rate, s.Baseline, s.Hours)
+}
+
+// Spike checks whether the bug is being reported far more than it usually is,
+// the last hour is counted from the minutes, the baseline from the whole hours before the last one started,
+// a bug younger than an hour of baseline can't spike, and it is held back like comms for muted bugs and quiet environments
+func (l *Logic) Spike(lb LogicBug, now time.Time) (Spike, bool) {
+	spike := Spike{}
+	if l.Config.Spike.Multiplier <= 0 || l.Config.KeepLocal || lb.Muted || l.quietEnvironment(lb.Environment) {
+		return spike, false
+	}
+
+	now = now.UTC()
+	from := now.Add(-59 * time.Minute).Format(rateMinute)
+	for minute, count := range lb.Minutes {
+		if minute >= from {
+			spike.Rate += count
+		}
+	}
+
+	baselineEnd := now.Add(-time.Hour).Truncate(time.Hour)
+	baselineStart := baselineEnd.Add(-time.Duration(l.Config.Spike.BaselineHours) * time.Hour)
+	if first := lb.FirstReported.UTC().Truncate(time.Hour); first.After(baselineStart) {
+		baselineStart = first
+	}
+	spike.Hours = int(baselineEnd.Sub(baselineStart) / time.Hour)
+	if spike.Hours < 1 {
+		return spike, false
+	}
+
+	start, end, reports := baselineStart.Format(rateHour), baselineEnd.Format(rateHour), 0
+	for hour, count := range lb.Hours {
+		if hour >= start && hour < end {
+			reports += count
+		}
+	}
+	spike.Baseline = float64(reports) / float64(spike.Hours)
+
+	if spike.Rate < l.Config.Spike.MinRate || float64(spike.Rate) <= spike.Baseline*l.Config.Spike.Multiplier {
+		return spike, false
+	}
+
+	return spike, true
+}
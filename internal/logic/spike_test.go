@@ -0,0 +1,105 @@
+package logic_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bugfixes/celeste/internal/config"
+	"github.com/bugfixes/celeste/internal/logic"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpike(t *testing.T) {
+	now := time.Date(2021, 6, 10, 12, 30, 0, 0, time.UTC)
+	minute := func(ago int) string {
+		return now.Add(-time.Duration(ago) * time.Minute).Format("2006-01-02T15:04")
+	}
+	hour := func(ago int) string {
+		return now.Add(-time.Duration(ago) * time.Hour).Format("2006-01-02T15")
+	}
+	c := config.Config{
+		Spike: config.Spike{
+			Multiplier:    10,
+			MinRate:       20,
+			BaselineHours: 24,
+		},
+	}
+
+	tests := []struct {
+		name   string
+		config config.Config
+		bug    logic.LogicBug
+		spike  bool
+		expect logic.Spike
+	}{
+		{
+			name:   "slow trickle",
+			config: c,
+			bug: logic.LogicBug{
+				FirstReported: now.Add(-48 * time.Hour),
+				Minutes:       map[string]int{minute(5): 2},
+				Hours:         map[string]int{hour(2): 2, hour(3): 2, hour(4): 2},
+			},
+			expect: logic.Spike{Rate: 2, Baseline: 0.25, Hours: 24},
+		},
+		{
+			name:   "jump",
+			config: c,
+			bug: logic.LogicBug{
+				FirstReported: now.Add(-48 * time.Hour),
+				Minutes:       map[string]int{minute(1): 1500, minute(30): 500, minute(90): 100},
+				Hours:         map[string]int{hour(2): 24, hour(3): 24},
+			},
+			spike:  true,
+			expect: logic.Spike{Rate: 2000, Baseline: 2, Hours: 24},
+		},
+		{
+			name:   "busy but usual",
+			config: c,
+			bug: logic.LogicBug{
+				FirstReported: now.Add(-48 * time.Hour),
+				Minutes:       map[string]int{minute(1): 2000},
+				Hours:         map[string]int{hour(2): 24000, hour(3): 24000},
+			},
+			expect: logic.Spike{Rate: 2000, Baseline: 2000, Hours: 24},
+		},
+		{
+			name:   "baseline since first seen",
+			config: c,
+			bug: logic.LogicBug{
+				FirstReported: now.Add(-4 * time.Hour),
+				Minutes:       map[string]int{minute(1): 80},
+				Hours:         map[string]int{hour(2): 9, hour(3): 9, hour(4): 9},
+			},
+			expect: logic.Spike{Rate: 80, Baseline: 9, Hours: 3},
+		},
+		{
+			name:   "too new",
+			config: c,
+			bug: logic.LogicBug{
+				FirstReported: now.Add(-time.Hour),
+				Minutes:       map[string]int{minute(1): 100},
+			},
+			expect: logic.Spike{Rate: 100},
+		},
+		{
+			name: "turned off",
+			bug: logic.LogicBug{
+				FirstReported: now.Add(-48 * time.Hour),
+				Minutes:       map[string]int{minute(1): 2000},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			spike, ok := logic.NewLogic(test.config).Spike(test.bug, now)
+			if passed := assert.Equal(t, test.spike, ok); !passed {
+				t.Errorf("spike expect: %v, got: %+v", test.spike, spike)
+			}
+			if passed := assert.Equal(t, test.expect, spike); !passed {
+				t.Errorf("spike expect: %+v, got: %+v", test.expect, spike)
+			}
+		})
+	}
+}
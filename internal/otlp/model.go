@@ -0,0 +1,180 @@
+package otlp
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Uint64 takes the string form OTLP/JSON uses for 64 bit numbers, as well as a plain number
+type Uint64 uint64
+
+func (u *Uint64) UnmarshalJSON(b []byte) error {
+	s := strings.Trim(string(b), `"`)
+	if s == "" || s == "null" {
+		return nil
+	}
+
+	v, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return err
+	}
+	*u = Uint64(v)
+
+	return nil
+}
+
+// Int64 is Uint64 for signed values
+type Int64 int64
+
+func (i *Int64) UnmarshalJSON(b []byte) error {
+	s := strings.Trim(string(b), `"`)
+	if s == "" || s == "null" {
+		return nil
+	}
+
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return err
+	}
+	*i = Int64(v)
+
+	return nil
+}
+
+type AnyValue struct {
+	StringValue *string       `json:"stringValue,omitempty"`
+	BoolValue   *bool         `json:"boolValue,omitempty"`
+	IntValue    *Int64        `json:"intValue,omitempty"`
+	DoubleValue *float64      `json:"doubleValue,omitempty"`
+	ArrayValue  *ArrayValue   `json:"arrayValue,omitempty"`
+	KvlistValue *KeyValueList `json:"kvlistValue,omitempty"`
+	BytesValue  []byte        `json:"bytesValue,omitempty"`
+}
+
+type ArrayValue struct {
+	Values []AnyValue `json:"values"`
+}
+
+type KeyValueList struct {
+	Values []KeyValue `json:"values"`
+}
+
+type KeyValue struct {
+	Key   string   `json:"key"`
+	Value AnyValue `json:"value"`
+}
+
+// String flattens any value, lists and maps are written as JSON
+func (v AnyValue) String() string {
+	switch {
+	case v.StringValue != nil:
+		return *v.StringValue
+	case v.BoolValue != nil:
+		return strconv.FormatBool(*v.BoolValue)
+	case v.IntValue != nil:
+		return strconv.FormatInt(int64(*v.IntValue), 10)
+	case v.DoubleValue != nil:
+		return strconv.FormatFloat(*v.DoubleValue, 'f', -1, 64)
+	case v.BytesValue != nil:
+		return fmt.Sprintf("%x", v.BytesValue)
+	case v.ArrayValue != nil:
+		values := make([]string, 0, len(v.ArrayValue.Values))
+		for _, av := range v.ArrayValue.Values {
+			values = append(values, av.String())
+		}
+		b, _ := json.Marshal(values)
+		return string(b)
+	case v.KvlistValue != nil:
+		b, _ := json.Marshal(Attributes(v.KvlistValue.Values))
+		return string(b)
+	}
+
+	return ""
+}
+
+// Attributes flattens a list of key values into a map
+func Attributes(kvs []KeyValue) map[string]string {
+	attrs := map[string]string{}
+	for _, kv := range kvs {
+		attrs[kv.Key] = kv.Value.String()
+	}
+
+	return attrs
+}
+
+type Resource struct {
+	Attributes []KeyValue `json:"attributes"`
+}
+
+type LogRecord struct {
+	TimeUnixNano         Uint64     `json:"timeUnixNano"`
+	ObservedTimeUnixNano Uint64     `json:"observedTimeUnixNano"`
+	SeverityNumber       int        `json:"severityNumber"`
+	SeverityText         string     `json:"severityText"`
+	Body                 AnyValue   `json:"body"`
+	Attributes           []KeyValue `json:"attributes"`
+	TraceID              string     `json:"traceId"`
+	SpanID               string     `json:"spanId"`
+}
+
+type ScopeLogs struct {
+	LogRecords []LogRecord `json:"logRecords"`
+}
+
+type ResourceLogs struct {
+	Resource  Resource    `json:"resource"`
+	ScopeLogs []ScopeLogs `json:"scopeLogs"`
+}
+
+type LogsRequest struct {
+	ResourceLogs []ResourceLogs `json:"resourceLogs"`
+}
+
+type SpanEvent struct {
+	TimeUnixNano Uint64     `json:"timeUnixNano"`
+	Name         string     `json:"name"`
+	Attributes   []KeyValue `json:"attributes"`
+}
+
+type Span struct {
+	TraceID    string      `json:"traceId"`
+	SpanID     string      `json:"spanId"`
+	Name       string      `json:"name"`
+	Attributes []KeyValue  `json:"attributes"`
+	Events     []SpanEvent `json:"events"`
+}
+
+type ScopeSpans struct {
+	Spans []Span `json:"spans"`
+}
+
+type ResourceSpans struct {
+	Resource   Resource     `json:"resource"`
+	ScopeSpans []ScopeSpans `json:"scopeSpans"`
+}
+
+type TracesRequest struct {
+	ResourceSpans []ResourceSpans `json:"resourceSpans"`
+}
+
+// LogsPartialSuccess is sent back when some of the export's records couldn't be stored
+type LogsPartialSuccess struct {
+	RejectedLogRecords int64  `json:"rejectedLogRecords,string"`
+	ErrorMessage       string `json:"errorMessage,omitempty"`
+}
+
+type LogsResponse struct {
+	PartialSuccess *LogsPartialSuccess `json:"partialSuccess,omitempty"`
+}
+
+// TracesPartialSuccess is sent back when some of the export's exceptions couldn't be queued
+type TracesPartialSuccess struct {
+	RejectedSpans int64  `json:"rejectedSpans,string"`
+	ErrorMessage  string `json:"errorMessage,omitempty"`
+}
+
+type TracesResponse struct {
+	PartialSuccess *TracesPartialSuccess `json:"partialSuccess,omitempty"`
+}
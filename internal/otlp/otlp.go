@@ -0,0 +1,418 @@
+package otlp
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bugfixes/celeste/internal/agent"
+	"github.com/bugfixes/celeste/internal/bug"
+	"github.com/bugfixes/celeste/internal/config"
+	bugLog "github.com/bugfixes/go-bugfixes/logs"
+	"github.com/google/uuid"
+)
+
+const (
+	// MaxBodyBytes is the largest export that will be read, after decompression
+	MaxBodyBytes = 5 << 20
+
+	contentTypeProtobuf = "application/x-protobuf"
+	contentTypeJSON     = "application/json"
+
+	// ExceptionEvent is the name OpenTelemetry gives the span event a recorded exception becomes
+	ExceptionEvent = "exception"
+)
+
+// logNamespace keeps the ids of OTLP logs apart from the other name based ids
+var logNamespace = uuid.MustParse("5d1c7b5e-2a3f-4c1e-9f0a-6b8e4d2c1a70")
+
+// ResourceMetadata are the resource attributes that are kept with every bug
+var ResourceMetadata = []string{
+	"service.name",
+	"service.version",
+	"deployment.environment",
+}
+
+type Receiver struct {
+	Config config.Config
+
+	Bugs bug.ProcessBug
+	Logs bug.ProcessLog
+}
+
+func NewReceiver(c config.Config) *Receiver {
+	return &Receiver{
+		Config: c,
+		Bugs:   bug.NewBug(c),
+		Logs:   bug.NewLog(c),
+	}
+}
+
+func errorReport(w http.ResponseWriter, status int, textError string, wrappedError error) {
+	bugLog.Debugf("otlp errorReport: %+v", wrappedError)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(struct {
+		Message string `json:"message"`
+	}{
+		Message: fmt.Sprintf("%s: %+v", textError, wrappedError),
+	}); err != nil {
+		bugLog.Debugf("otlp errorReport json: %+v", err)
+	}
+}
+
+func readBody(r *http.Request) ([]byte, error) {
+	var reader io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return nil, bugLog.Errorf("readBody gzip: %+v", err)
+		}
+		defer func() {
+			if err := gz.Close(); err != nil {
+				bugLog.Debugf("readBody gzip close: %+v", err)
+			}
+		}()
+		reader = gz
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(reader, MaxBodyBytes))
+	if err != nil {
+		return nil, bugLog.Errorf("readBody read: %+v", err)
+	}
+
+	return body, nil
+}
+
+func isJSON(r *http.Request) bool {
+	return strings.HasPrefix(r.Header.Get("Content-Type"), contentTypeJSON)
+}
+
+// respond sends the export response, in the encoding the request came in
+func respond(w http.ResponseWriter, r *http.Request, resp interface{}, encoded []byte) {
+	if isJSON(r) {
+		w.Header().Set("Content-Type", contentTypeJSON)
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			bugLog.Debugf("otlp respond: %+v", err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", contentTypeProtobuf)
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(encoded); err != nil {
+		bugLog.Debugf("otlp respond: %+v", err)
+	}
+}
+
+// Level maps the severity onto the levels celeste understands, the number wins over the text
+// nolint: gocyclo
+func Level(number int, text string) string {
+	switch {
+	case number >= 21:
+		return "fatal"
+	case number >= 17:
+		return "error"
+	case number >= 13:
+		return "warn"
+	case number >= 9:
+		return "info"
+	case number >= 1:
+		return "debug"
+	}
+
+	switch strings.ToLower(text) {
+	case "trace", "debug":
+		return "debug"
+	case "info":
+		return "info"
+	case "warn", "warning":
+		return "warn"
+	case "error":
+		return "error"
+	case "fatal", "critical":
+		return "fatal"
+	}
+
+	return "unknown"
+}
+
+// Environment is the resource's deployment environment, under either of the names the conventions have used
+func Environment(resource map[string]string) string {
+	if v, ok := resource["deployment.environment.name"]; ok {
+		return v
+	}
+
+	return resource["deployment.environment"]
+}
+
+func unixNano(n Uint64) time.Time {
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, int64(n))
+}
+
+// LogID is the same each time the exporter sends the record, a batch sent again after a failure
+// doesn't store, or promote, the records that were already stored
+func LogID(agentID string, lr LogRecord) string {
+	name := strings.Join([]string{
+		agentID,
+		lr.TraceID,
+		lr.SpanID,
+		strconv.FormatUint(uint64(lr.TimeUnixNano), 10),
+		strconv.FormatUint(uint64(lr.ObservedTimeUnixNano), 10),
+		lr.Body.String(),
+	}, "\n")
+
+	return uuid.NewSHA1(logNamespace, []byte(name)).String()
+}
+
+// Log maps an OTel log record onto a log, the code attributes give the file and line,
+// every other attribute, and the resource metadata, are kept as logfmt
+func Log(a agent.Agent, resource map[string]string, lr LogRecord) bug.Log {
+	attrs := Attributes(lr.Attributes)
+	fields := map[string]string{}
+	for _, k := range ResourceMetadata {
+		if v, ok := resource[k]; ok {
+			fields[k] = v
+		}
+	}
+	for k, v := range attrs {
+		switch k {
+		case "code.filepath", "code.lineno", "exception.stacktrace":
+			continue
+		}
+		fields[k] = v
+	}
+	if lr.TraceID != "" {
+		fields["trace_id"] = lr.TraceID
+	}
+	if lr.SpanID != "" {
+		fields["span_id"] = lr.SpanID
+	}
+
+	logged := unixNano(lr.TimeUnixNano)
+	if logged.IsZero() {
+		logged = unixNano(lr.ObservedTimeUnixNano)
+	}
+
+	level := Level(lr.SeverityNumber, lr.SeverityText)
+	return bug.Log{
+		Agent:       a,
+		Identifier:  LogID(a.Key, lr),
+		Environment: Environment(resource),
+		Level:       level,
+		LevelNumber: bug.ConvertLevelFromString(level),
+		Line:        attrs["code.lineno"],
+		File:        attrs["code.filepath"],
+		Stack:       bug.StackTrace(attrs["exception.stacktrace"]),
+		LogFmt:      bug.FormatLogFmt(fields),
+		Log:         lr.Body.String(),
+		Logged:      logged,
+	}
+}
+
+// Bug maps an exception event onto a bug, the file and line come from the code attributes of the span,
+// or the top frame of the stack when the span has none
+func Bug(resource map[string]string, span Span, ev SpanEvent) bug.Bug {
+	attrs := Attributes(ev.Attributes)
+	spanAttrs := Attributes(span.Attributes)
+
+	title := attrs["exception.type"]
+	if msg := attrs["exception.message"]; msg != "" {
+		if title != "" {
+			title = fmt.Sprintf("%s: %s", title, msg)
+		} else {
+			title = msg
+		}
+	}
+
+	b := bug.Bug{
+		Bug:   title,
+		Raw:   attrs["exception.stacktrace"],
+		Level: "error",
+		Tags:  map[string]string{},
+	}
+	if b.Raw == "" {
+		b.Raw = title
+	}
+	if attrs["exception.escaped"] == "true" {
+		b.Level = "crash"
+	}
+
+	for _, k := range ResourceMetadata {
+		if v, ok := resource[k]; ok {
+			b.Tags[k] = v
+		}
+	}
+	if v, ok := resource["service.version"]; ok {
+		b.Release = v
+	}
+	if v, ok := resource["vcs.ref.head.revision"]; ok {
+		b.Commit = v
+	}
+	b.Environment = Environment(resource)
+	b.Tags["span.name"] = span.Name
+	if span.TraceID != "" {
+		b.Tags["trace_id"] = span.TraceID
+	}
+
+	b.File = spanAttrs["code.filepath"]
+	b.Line = spanAttrs["code.lineno"]
+	if b.File == "" {
+		for _, f := range bug.ParseStack(b.Raw) {
+			b.File = f.File
+			b.Line = strconv.Itoa(f.Line)
+			if f.InApp {
+				break
+			}
+		}
+	}
+	b.LineNumber, _ = strconv.Atoi(b.Line)
+
+	return b
+}
+
+func (rc Receiver) LogsHandler(w http.ResponseWriter, r *http.Request) {
+	defer func() {
+		if err := r.Body.Close(); err != nil {
+			bugLog.Debugf("logsHandler body close: %+v", err)
+		}
+	}()
+
+	a, err := bug.RequestAgent(rc.Config, r)
+	if err != nil {
+		errorReport(w, http.StatusUnauthorized, "invalid agent", err)
+		return
+	}
+
+	body, err := readBody(r)
+	if err != nil {
+		errorReport(w, http.StatusBadRequest, "invalid body", err)
+		return
+	}
+
+	req := LogsRequest{}
+	if isJSON(r) {
+		err = json.Unmarshal(body, &req)
+	} else {
+		req, err = DecodeLogsRequest(body)
+	}
+	if err != nil {
+		errorReport(w, http.StatusBadRequest, "invalid export", err)
+		return
+	}
+
+	// a record that fails is reported back rather than failing the export, the exporter would send
+	// the whole batch again, when nothing could be stored it is worth the exporter trying again
+	retention := rc.Logs.Retention(a.Account.ID)
+	total, rejected := 0, 0
+	var storeErr error
+	for _, rl := range req.ResourceLogs {
+		resource := Attributes(rl.Resource.Attributes)
+		for _, sl := range rl.ScopeLogs {
+			for _, lr := range sl.LogRecords {
+				total++
+				log := Log(a, resource, lr)
+				log.Retention = retention
+				log.Verified = true
+				if err := rc.Logs.StoreLog(&log); err != nil {
+					bugLog.Debugf("otlp logsHandler storeLog: %+v", err)
+					rejected++
+					storeErr = err
+				}
+			}
+		}
+	}
+	if rejected > 0 && rejected == total {
+		errorReport(w, http.StatusServiceUnavailable, "storeLog", storeErr)
+		return
+	}
+
+	resp := LogsResponse{}
+	if rejected > 0 {
+		resp.PartialSuccess = &LogsPartialSuccess{
+			RejectedLogRecords: int64(rejected),
+			ErrorMessage:       fmt.Sprintf("%d of %d log records could not be stored", rejected, total),
+		}
+	}
+	respond(w, r, resp, EncodeLogsResponse(resp))
+}
+
+// TracesHandler only keeps the exceptions recorded on spans, the spans themselves are dropped
+func (rc Receiver) TracesHandler(w http.ResponseWriter, r *http.Request) {
+	defer func() {
+		if err := r.Body.Close(); err != nil {
+			bugLog.Debugf("tracesHandler body close: %+v", err)
+		}
+	}()
+
+	a, err := bug.RequestAgent(rc.Config, r)
+	if err != nil {
+		errorReport(w, http.StatusUnauthorized, "invalid agent", err)
+		return
+	}
+
+	body, err := readBody(r)
+	if err != nil {
+		errorReport(w, http.StatusBadRequest, "invalid body", err)
+		return
+	}
+
+	req := TracesRequest{}
+	if isJSON(r) {
+		err = json.Unmarshal(body, &req)
+	} else {
+		req, err = DecodeTracesRequest(body)
+	}
+	if err != nil {
+		errorReport(w, http.StatusBadRequest, "invalid export", err)
+		return
+	}
+
+	// same as logs, failing the export after some exceptions were queued would queue them again on retry
+	total, rejected := 0, 0
+	var enqueueErr error
+	for _, rs := range req.ResourceSpans {
+		resource := Attributes(rs.Resource.Attributes)
+		for _, ss := range rs.ScopeSpans {
+			for _, span := range ss.Spans {
+				for _, ev := range span.Events {
+					if ev.Name != ExceptionEvent {
+						continue
+					}
+
+					total++
+					b := Bug(resource, span, ev)
+					b.Agent.Key = a.Key
+					if err := rc.Bugs.Enqueue(b); err != nil {
+						bugLog.Debugf("otlp tracesHandler enqueue: %+v", err)
+						rejected++
+						enqueueErr = err
+					}
+				}
+			}
+		}
+	}
+	if rejected > 0 && rejected == total {
+		errorReport(w, http.StatusServiceUnavailable, "enqueue", enqueueErr)
+		return
+	}
+
+	resp := TracesResponse{}
+	if rejected > 0 {
+		resp.PartialSuccess = &TracesPartialSuccess{
+			RejectedSpans: int64(rejected),
+			ErrorMessage:  fmt.Sprintf("%d of %d exceptions could not be queued", rejected, total),
+		}
+	}
+	respond(w, r, resp, EncodeTracesResponse(resp))
+}
@@ -0,0 +1,330 @@
+package otlp_test
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bugfixes/celeste/internal/agent"
+	"github.com/bugfixes/celeste/internal/config"
+	"github.com/bugfixes/celeste/internal/database"
+	"github.com/bugfixes/celeste/internal/otlp"
+	"github.com/bugfixes/celeste/internal/queue/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// pb is just enough of a protobuf encoder to build export requests by hand
+type pb []byte
+
+func (p pb) varint(v uint64) pb {
+	buf := make([]byte, binary.MaxVarintLen64)
+	return append(p, buf[:binary.PutUvarint(buf, v)]...)
+}
+
+func (p pb) bytes(field int, b []byte) pb {
+	return append(p.varint(uint64(field<<3|2)).varint(uint64(len(b))), b...)
+}
+
+func (p pb) str(field int, s string) pb {
+	return p.bytes(field, []byte(s))
+}
+
+func (p pb) uint(field int, v uint64) pb {
+	return p.varint(uint64(field << 3)).varint(v)
+}
+
+func (p pb) fixed(field int, v uint64) pb {
+	buf := make([]byte, 8)
+	binary.LittleEndian.PutUint64(buf, v)
+	return append(p.varint(uint64(field<<3|1)), buf...)
+}
+
+func attr(key, value string) []byte {
+	return pb{}.str(1, key).bytes(2, pb{}.str(1, value))
+}
+
+func intAttr(key string, value uint64) []byte {
+	return pb{}.str(1, key).bytes(2, pb{}.uint(3, value))
+}
+
+func TestDecodeLogsRequest(t *testing.T) {
+	record := pb{}.
+		fixed(1, 1617271200000000000).
+		uint(2, 17).
+		str(3, "ERROR").
+		bytes(5, pb{}.str(1, "disk full")).
+		bytes(6, attr("code.filepath", "/app/disk.go")).
+		bytes(6, intAttr("code.lineno", 42)).
+		bytes(9, []byte{0x0a, 0xf7, 0x65, 0x11})
+	resource := pb{}.bytes(1, attr("service.name", "tester"))
+	body := pb{}.bytes(1, pb{}.
+		bytes(1, resource).
+		bytes(2, pb{}.bytes(1, pb{}.str(1, "scope")).bytes(2, record)))
+
+	req, err := otlp.DecodeLogsRequest(body)
+	if passed := assert.Nil(t, err); !passed {
+		t.Fatalf("decodeLogsRequest err: %+v", err)
+	}
+
+	expect := otlp.LogsRequest{
+		ResourceLogs: []otlp.ResourceLogs{{
+			Resource: otlp.Resource{
+				Attributes: []otlp.KeyValue{{Key: "service.name", Value: otlp.AnyValue{StringValue: strPtr("tester")}}},
+			},
+			ScopeLogs: []otlp.ScopeLogs{{
+				LogRecords: []otlp.LogRecord{{
+					TimeUnixNano:   1617271200000000000,
+					SeverityNumber: 17,
+					SeverityText:   "ERROR",
+					Body:           otlp.AnyValue{StringValue: strPtr("disk full")},
+					Attributes: []otlp.KeyValue{
+						{Key: "code.filepath", Value: otlp.AnyValue{StringValue: strPtr("/app/disk.go")}},
+						{Key: "code.lineno", Value: otlp.AnyValue{IntValue: int64Ptr(42)}},
+					},
+					TraceID: "0af76511",
+				}},
+			}},
+		}},
+	}
+	if passed := assert.Equal(t, expect, req); !passed {
+		t.Errorf("decodeLogsRequest expect: %+v, got: %+v", expect, req)
+	}
+}
+
+func TestReceiver_LogsHandlerRetry(t *testing.T) {
+	t.Cleanup(func() {
+		agent.ResetMemory()
+		database.ResetMemory()
+	})
+
+	c := config.Config{
+		Storage: config.Storage{
+			StorageBackend: config.StorageMemory,
+		},
+	}
+	if err := agent.NewAgent(c).Store(agent.Agent{
+		Credentials: agent.Credentials{
+			Key:    "otlp-key",
+			Secret: "otlp-secret",
+		},
+	}); err != nil {
+		t.Fatalf("store agent err: %+v", err)
+	}
+
+	body := `{"resourceLogs": [{"scopeLogs": [{"logRecords": [
+  {"timeUnixNano": "1617271200000000000", "severityText": "INFO", "body": {"stringValue": "started"}},
+  {"timeUnixNano": "1617271201000000000", "severityText": "INFO", "body": {"stringValue": "listening"}}
+]}]}]}`
+
+	rc := otlp.NewReceiver(c)
+	for i := 0; i < 2; i++ {
+		r := httptest.NewRequest(http.MethodPost, "/v1/logs", strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set("X-API-KEY", "otlp-key")
+		r.Header.Set("X-API-SECRET", "otlp-secret")
+		w := httptest.NewRecorder()
+		rc.LogsHandler(w, r)
+		if passed := assert.Equal(t, http.StatusOK, w.Code); !passed {
+			t.Errorf("logsHandler status: %d, body: %s", w.Code, w.Body.String())
+		}
+		if passed := assert.JSONEq(t, "{}", w.Body.String()); !passed {
+			t.Errorf("logsHandler body: %s", w.Body.String())
+		}
+	}
+
+	lrs, _, err := database.NewLogStorage(*database.New(c)).Query(database.LogFilter{AgentID: "otlp-key"})
+	if passed := assert.Nil(t, err); !passed {
+		t.Fatalf("query err: %+v", err)
+	}
+	if passed := assert.Equal(t, 2, len(lrs)); !passed {
+		t.Errorf("logs stored expect: 2, got: %d", len(lrs))
+	}
+}
+
+func TestReceiver_TracesHandlerPartial(t *testing.T) {
+	t.Cleanup(agent.ResetMemory)
+
+	c := config.Config{
+		Storage: config.Storage{
+			StorageBackend: config.StorageMemory,
+		},
+	}
+	if err := agent.NewAgent(c).Store(agent.Agent{
+		Credentials: agent.Credentials{
+			Key:    "otlp-key",
+			Secret: "otlp-secret",
+		},
+	}); err != nil {
+		t.Fatalf("store agent err: %+v", err)
+	}
+
+	q := &mocks.Queue{}
+	q.On("Send", mock.Anything).Return(errors.New("queue full")).Once()
+	q.On("Send", mock.Anything).Return(nil).Once()
+
+	body := `{"resourceSpans": [{"scopeSpans": [{"spans": [{"name": "GET /users", "events": [
+  {"name": "exception", "attributes": [{"key": "exception.type", "value": {"stringValue": "KeyError"}}]},
+  {"name": "exception", "attributes": [{"key": "exception.type", "value": {"stringValue": "ValueError"}}]}
+]}]}]}]}`
+
+	rc := otlp.NewReceiver(c)
+	rc.Bugs.Queue = q
+	r := httptest.NewRequest(http.MethodPost, "/v1/traces", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("X-API-KEY", "otlp-key")
+	r.Header.Set("X-API-SECRET", "otlp-secret")
+	w := httptest.NewRecorder()
+	rc.TracesHandler(w, r)
+	q.AssertExpectations(t)
+
+	if passed := assert.Equal(t, http.StatusOK, w.Code); !passed {
+		t.Errorf("tracesHandler status: %d, body: %s", w.Code, w.Body.String())
+	}
+	expect := `{"partialSuccess": {"rejectedSpans": "1", "errorMessage": "1 of 2 exceptions could not be queued"}}`
+	if passed := assert.JSONEq(t, expect, w.Body.String()); !passed {
+		t.Errorf("tracesHandler body: %s", w.Body.String())
+	}
+}
+
+func TestEncodeLogsResponse(t *testing.T) {
+	tests := []struct {
+		name   string
+		resp   otlp.LogsResponse
+		expect []byte
+	}{
+		{
+			name:   "everything stored",
+			resp:   otlp.LogsResponse{},
+			expect: []byte{},
+		},
+		{
+			name: "partial success",
+			resp: otlp.LogsResponse{
+				PartialSuccess: &otlp.LogsPartialSuccess{
+					RejectedLogRecords: 2,
+					ErrorMessage:       "no space",
+				},
+			},
+			expect: pb{}.bytes(1, pb{}.uint(1, 2).str(2, "no space")),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := otlp.EncodeLogsResponse(test.resp)
+			if passed := assert.Equal(t, test.expect, got); !passed {
+				t.Errorf("encodeLogsResponse expect: %v, got: %v", test.expect, got)
+			}
+		})
+	}
+}
+
+func TestLogID(t *testing.T) {
+	lr := otlp.LogRecord{
+		TimeUnixNano: 1617271200000000000,
+		Body:         otlp.AnyValue{StringValue: strPtr("disk full")},
+		TraceID:      "0af76511",
+		SpanID:       "b7ad6b71",
+	}
+	other := lr
+	other.Body = otlp.AnyValue{StringValue: strPtr("disk fine")}
+
+	if passed := assert.Equal(t, otlp.LogID("agent", lr), otlp.LogID("agent", lr)); !passed {
+		t.Errorf("logID should be the same for the same record")
+	}
+	if passed := assert.NotEqual(t, otlp.LogID("agent", lr), otlp.LogID("agent", other)); !passed {
+		t.Errorf("logID should differ when the body does")
+	}
+	if passed := assert.NotEqual(t, otlp.LogID("agent", lr), otlp.LogID("other", lr)); !passed {
+		t.Errorf("logID should differ between agents")
+	}
+}
+
+func TestTracesRequestJSON(t *testing.T) {
+	body := `{"resourceSpans": [{
+  "resource": {"attributes": [
+    {"key": "service.name", "value": {"stringValue": "tester"}},
+    {"key": "deployment.environment", "value": {"stringValue": "production"}}
+  ]},
+  "scopeSpans": [{"spans": [{
+    "traceId": "5b8efff798038103d269b633813fc60c",
+    "spanId": "eee19b7ec3c1b174",
+    "name": "GET /users",
+    "events": [
+      {"name": "log", "timeUnixNano": "1617271200000000000"},
+      {"name": "exception", "timeUnixNano": "1617271200000000000", "attributes": [
+        {"key": "exception.type", "value": {"stringValue": "KeyError"}},
+        {"key": "exception.message", "value": {"stringValue": "42"}},
+        {"key": "exception.stacktrace", "value": {"stringValue": "Traceback (most recent call last):\n  File \"/app/views.py\", line 12, in index\n    return load(42)\n  File \"/app/loader.py\", line 7, in load\n    raise KeyError(id)\nKeyError: 42"}}
+      ]}
+    ]
+  }]}]
+}]}`
+
+	req := otlp.TracesRequest{}
+	if err := json.Unmarshal([]byte(body), &req); err != nil {
+		t.Fatalf("unmarshal err: %+v", err)
+	}
+
+	rs := req.ResourceSpans[0]
+	span := rs.ScopeSpans[0].Spans[0]
+	b := otlp.Bug(otlp.Attributes(rs.Resource.Attributes), span, span.Events[1])
+
+	if passed := assert.Equal(t, "KeyError: 42", b.Bug); !passed {
+		t.Errorf("bug title got: %v", b.Bug)
+	}
+	if passed := assert.Equal(t, "/app/loader.py", b.File); !passed {
+		t.Errorf("bug file got: %v", b.File)
+	}
+	if passed := assert.Equal(t, 7, b.LineNumber); !passed {
+		t.Errorf("bug line got: %v", b.LineNumber)
+	}
+	expectTags := map[string]string{
+		"service.name":           "tester",
+		"deployment.environment": "production",
+		"span.name":              "GET /users",
+		"trace_id":               "5b8efff798038103d269b633813fc60c",
+	}
+	if passed := assert.Equal(t, expectTags, b.Tags); !passed {
+		t.Errorf("bug tags got: %v", b.Tags)
+	}
+}
+
+func TestLevel(t *testing.T) {
+	tests := []struct {
+		name   string
+		number int
+		text   string
+		expect string
+	}{
+		{name: "debug", number: 5, expect: "debug"},
+		{name: "info", number: 9, expect: "info"},
+		{name: "warn", number: 13, text: "INFO", expect: "warn"},
+		{name: "error", number: 17, expect: "error"},
+		{name: "fatal", number: 21, expect: "fatal"},
+		{name: "text only", text: "Warning", expect: "warn"},
+		{name: "nothing", expect: "unknown"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp := otlp.Level(test.number, test.text)
+			if passed := assert.Equal(t, test.expect, resp); !passed {
+				t.Errorf("level expect: %v, got: %v", test.expect, resp)
+			}
+		})
+	}
+}
+
+func strPtr(s string) *string {
+	return &s
+}
+
+func int64Ptr(i otlp.Int64) *otlp.Int64 {
+	return &i
+}
@@ -0,0 +1,494 @@
+package otlp
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"math"
+)
+
+// The OTLP protobuf messages are decoded by hand, only the fields celeste maps are read,
+// everything else is skipped by its wire type
+
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var errTruncated = errors.New("protobuf: message is truncated")
+
+type protoReader struct {
+	b []byte
+	i int
+}
+
+func (r *protoReader) done() bool {
+	return r.i >= len(r.b)
+}
+
+func (r *protoReader) varint() (uint64, error) {
+	v, n := binary.Uvarint(r.b[r.i:])
+	if n <= 0 {
+		return 0, errTruncated
+	}
+	r.i += n
+
+	return v, nil
+}
+
+func (r *protoReader) key() (int, int, error) {
+	k, err := r.varint()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return int(k >> 3), int(k & 7), nil
+}
+
+func (r *protoReader) bytes() ([]byte, error) {
+	l, err := r.varint()
+	if err != nil {
+		return nil, err
+	}
+	if l > uint64(len(r.b)-r.i) {
+		return nil, errTruncated
+	}
+	b := r.b[r.i : r.i+int(l)]
+	r.i += int(l)
+
+	return b, nil
+}
+
+func (r *protoReader) fixed64() (uint64, error) {
+	if len(r.b)-r.i < 8 {
+		return 0, errTruncated
+	}
+	v := binary.LittleEndian.Uint64(r.b[r.i:])
+	r.i += 8
+
+	return v, nil
+}
+
+func (r *protoReader) skip(wire int) error {
+	switch wire {
+	case wireVarint:
+		_, err := r.varint()
+		return err
+	case wireFixed64:
+		_, err := r.fixed64()
+		return err
+	case wireBytes:
+		_, err := r.bytes()
+		return err
+	case wireFixed32:
+		if len(r.b)-r.i < 4 {
+			return errTruncated
+		}
+		r.i += 4
+		return nil
+	}
+
+	return errors.New("protobuf: unknown wire type")
+}
+
+// fields calls fn for every field of the message, fn reads the value or returns handled false to skip it
+func fields(b []byte, fn func(r *protoReader, field, wire int) (bool, error)) error {
+	r := &protoReader{b: b}
+	for !r.done() {
+		field, wire, err := r.key()
+		if err != nil {
+			return err
+		}
+
+		handled, err := fn(r, field, wire)
+		if err != nil {
+			return err
+		}
+		if !handled {
+			if err := r.skip(wire); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// messages collects a repeated message field
+func messages(r *protoReader, wire int, decode func([]byte) error) (bool, error) {
+	if wire != wireBytes {
+		return false, nil
+	}
+	b, err := r.bytes()
+	if err != nil {
+		return true, err
+	}
+
+	return true, decode(b)
+}
+
+func stringField(r *protoReader, wire int, s *string) (bool, error) {
+	if wire != wireBytes {
+		return false, nil
+	}
+	b, err := r.bytes()
+	if err != nil {
+		return true, err
+	}
+	*s = string(b)
+
+	return true, nil
+}
+
+func hexField(r *protoReader, wire int, s *string) (bool, error) {
+	if wire != wireBytes {
+		return false, nil
+	}
+	b, err := r.bytes()
+	if err != nil {
+		return true, err
+	}
+	*s = hex.EncodeToString(b)
+
+	return true, nil
+}
+
+func timeField(r *protoReader, wire int, t *Uint64) (bool, error) {
+	if wire != wireFixed64 {
+		return false, nil
+	}
+	v, err := r.fixed64()
+	if err != nil {
+		return true, err
+	}
+	*t = Uint64(v)
+
+	return true, nil
+}
+
+func decodeAnyValue(b []byte) (AnyValue, error) {
+	v := AnyValue{}
+	err := fields(b, func(r *protoReader, field, wire int) (bool, error) {
+		switch field {
+		case 1:
+			s := ""
+			v.StringValue = &s
+			return stringField(r, wire, v.StringValue)
+		case 2, 3:
+			if wire != wireVarint {
+				return false, nil
+			}
+			n, err := r.varint()
+			if err != nil {
+				return true, err
+			}
+			if field == 2 {
+				bv := n != 0
+				v.BoolValue = &bv
+			} else {
+				iv := Int64(int64(n))
+				v.IntValue = &iv
+			}
+			return true, nil
+		case 4:
+			if wire != wireFixed64 {
+				return false, nil
+			}
+			n, err := r.fixed64()
+			if err != nil {
+				return true, err
+			}
+			f := math.Float64frombits(n)
+			v.DoubleValue = &f
+			return true, nil
+		case 5:
+			v.ArrayValue = &ArrayValue{}
+			return messages(r, wire, func(b []byte) error {
+				return fields(b, func(r *protoReader, field, wire int) (bool, error) {
+					if field != 1 {
+						return false, nil
+					}
+					return messages(r, wire, func(b []byte) error {
+						av, err := decodeAnyValue(b)
+						v.ArrayValue.Values = append(v.ArrayValue.Values, av)
+						return err
+					})
+				})
+			})
+		case 6:
+			v.KvlistValue = &KeyValueList{}
+			return messages(r, wire, func(b []byte) error {
+				return fields(b, func(r *protoReader, field, wire int) (bool, error) {
+					if field != 1 {
+						return false, nil
+					}
+					return messages(r, wire, func(b []byte) error {
+						kv, err := decodeKeyValue(b)
+						v.KvlistValue.Values = append(v.KvlistValue.Values, kv)
+						return err
+					})
+				})
+			})
+		case 7:
+			if wire != wireBytes {
+				return false, nil
+			}
+			bv, err := r.bytes()
+			v.BytesValue = append([]byte{}, bv...)
+			return true, err
+		}
+		return false, nil
+	})
+
+	return v, err
+}
+
+func decodeKeyValue(b []byte) (KeyValue, error) {
+	kv := KeyValue{}
+	err := fields(b, func(r *protoReader, field, wire int) (bool, error) {
+		switch field {
+		case 1:
+			return stringField(r, wire, &kv.Key)
+		case 2:
+			return messages(r, wire, func(b []byte) error {
+				v, err := decodeAnyValue(b)
+				kv.Value = v
+				return err
+			})
+		}
+		return false, nil
+	})
+
+	return kv, err
+}
+
+// attributesField appends one entry of a repeated KeyValue field
+func attributesField(r *protoReader, wire int, kvs *[]KeyValue) (bool, error) {
+	return messages(r, wire, func(b []byte) error {
+		kv, err := decodeKeyValue(b)
+		*kvs = append(*kvs, kv)
+		return err
+	})
+}
+
+func decodeResource(b []byte) (Resource, error) {
+	res := Resource{}
+	err := fields(b, func(r *protoReader, field, wire int) (bool, error) {
+		if field == 1 {
+			return attributesField(r, wire, &res.Attributes)
+		}
+		return false, nil
+	})
+
+	return res, err
+}
+
+func decodeLogRecord(b []byte) (LogRecord, error) {
+	lr := LogRecord{}
+	err := fields(b, func(r *protoReader, field, wire int) (bool, error) {
+		switch field {
+		case 1:
+			return timeField(r, wire, &lr.TimeUnixNano)
+		case 11:
+			return timeField(r, wire, &lr.ObservedTimeUnixNano)
+		case 2:
+			if wire != wireVarint {
+				return false, nil
+			}
+			n, err := r.varint()
+			lr.SeverityNumber = int(n)
+			return true, err
+		case 3:
+			return stringField(r, wire, &lr.SeverityText)
+		case 5:
+			return messages(r, wire, func(b []byte) error {
+				v, err := decodeAnyValue(b)
+				lr.Body = v
+				return err
+			})
+		case 6:
+			return attributesField(r, wire, &lr.Attributes)
+		case 9:
+			return hexField(r, wire, &lr.TraceID)
+		case 10:
+			return hexField(r, wire, &lr.SpanID)
+		}
+		return false, nil
+	})
+
+	return lr, err
+}
+
+// DecodeLogsRequest reads an ExportLogsServiceRequest
+func DecodeLogsRequest(b []byte) (LogsRequest, error) {
+	req := LogsRequest{}
+	err := fields(b, func(r *protoReader, field, wire int) (bool, error) {
+		if field != 1 {
+			return false, nil
+		}
+		return messages(r, wire, func(b []byte) error {
+			rl := ResourceLogs{}
+			err := fields(b, func(r *protoReader, field, wire int) (bool, error) {
+				switch field {
+				case 1:
+					return messages(r, wire, func(b []byte) error {
+						res, err := decodeResource(b)
+						rl.Resource = res
+						return err
+					})
+				// 1000 is instrumentation_library_logs, from before scopes
+				case 2, 1000:
+					return messages(r, wire, func(b []byte) error {
+						sl := ScopeLogs{}
+						err := fields(b, func(r *protoReader, field, wire int) (bool, error) {
+							if field != 2 {
+								return false, nil
+							}
+							return messages(r, wire, func(b []byte) error {
+								lr, err := decodeLogRecord(b)
+								sl.LogRecords = append(sl.LogRecords, lr)
+								return err
+							})
+						})
+						rl.ScopeLogs = append(rl.ScopeLogs, sl)
+						return err
+					})
+				}
+				return false, nil
+			})
+			req.ResourceLogs = append(req.ResourceLogs, rl)
+			return err
+		})
+	})
+
+	return req, err
+}
+
+func decodeSpan(b []byte) (Span, error) {
+	span := Span{}
+	err := fields(b, func(r *protoReader, field, wire int) (bool, error) {
+		switch field {
+		case 1:
+			return hexField(r, wire, &span.TraceID)
+		case 2:
+			return hexField(r, wire, &span.SpanID)
+		case 5:
+			return stringField(r, wire, &span.Name)
+		case 9:
+			return attributesField(r, wire, &span.Attributes)
+		case 11:
+			return messages(r, wire, func(b []byte) error {
+				ev := SpanEvent{}
+				err := fields(b, func(r *protoReader, field, wire int) (bool, error) {
+					switch field {
+					case 1:
+						return timeField(r, wire, &ev.TimeUnixNano)
+					case 2:
+						return stringField(r, wire, &ev.Name)
+					case 3:
+						return attributesField(r, wire, &ev.Attributes)
+					}
+					return false, nil
+				})
+				span.Events = append(span.Events, ev)
+				return err
+			})
+		}
+		return false, nil
+	})
+
+	return span, err
+}
+
+// DecodeTracesRequest reads an ExportTraceServiceRequest
+func DecodeTracesRequest(b []byte) (TracesRequest, error) {
+	req := TracesRequest{}
+	err := fields(b, func(r *protoReader, field, wire int) (bool, error) {
+		if field != 1 {
+			return false, nil
+		}
+		return messages(r, wire, func(b []byte) error {
+			rs := ResourceSpans{}
+			err := fields(b, func(r *protoReader, field, wire int) (bool, error) {
+				switch field {
+				case 1:
+					return messages(r, wire, func(b []byte) error {
+						res, err := decodeResource(b)
+						rs.Resource = res
+						return err
+					})
+				// 1000 is instrumentation_library_spans, from before scopes
+				case 2, 1000:
+					return messages(r, wire, func(b []byte) error {
+						ss := ScopeSpans{}
+						err := fields(b, func(r *protoReader, field, wire int) (bool, error) {
+							if field != 2 {
+								return false, nil
+							}
+							return messages(r, wire, func(b []byte) error {
+								span, err := decodeSpan(b)
+								ss.Spans = append(ss.Spans, span)
+								return err
+							})
+						})
+						rs.ScopeSpans = append(rs.ScopeSpans, ss)
+						return err
+					})
+				}
+				return false, nil
+			})
+			req.ResourceSpans = append(req.ResourceSpans, rs)
+			return err
+		})
+	})
+
+	return req, err
+}
+
+func appendVarint(b []byte, v uint64) []byte {
+	buf := make([]byte, binary.MaxVarintLen64)
+	return append(b, buf[:binary.PutUvarint(buf, v)]...)
+}
+
+func appendKey(b []byte, field, wire int) []byte {
+	return appendVarint(b, uint64(field<<3|wire))
+}
+
+func appendBytes(b []byte, field int, v []byte) []byte {
+	b = appendKey(b, field, wireBytes)
+	b = appendVarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+// EncodeLogsResponse writes an ExportLogsServiceResponse, the partial success is the only field
+func EncodeLogsResponse(resp LogsResponse) []byte {
+	if resp.PartialSuccess == nil {
+		return []byte{}
+	}
+
+	return encodePartialSuccess(resp.PartialSuccess.RejectedLogRecords, resp.PartialSuccess.ErrorMessage)
+}
+
+// EncodeTracesResponse writes an ExportTraceServiceResponse, laid out the same as the logs one
+func EncodeTracesResponse(resp TracesResponse) []byte {
+	if resp.PartialSuccess == nil {
+		return []byte{}
+	}
+
+	return encodePartialSuccess(resp.PartialSuccess.RejectedSpans, resp.PartialSuccess.ErrorMessage)
+}
+
+func encodePartialSuccess(rejected int64, message string) []byte {
+	ps := []byte{}
+	if rejected != 0 {
+		ps = appendKey(ps, 1, wireVarint)
+		ps = appendVarint(ps, uint64(rejected))
+	}
+	if message != "" {
+		ps = appendBytes(ps, 2, []byte(message))
+	}
+
+	return appendBytes([]byte{}, 1, ps)
+}
@@ -0,0 +1,144 @@
+package permissions
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bugfixes/celeste/internal/config"
+	bugLog "github.com/bugfixes/go-bugfixes/logs"
+	"github.com/jackc/pgx/v4"
+)
+
+//go:generate mockery --name=PermissionStorage
+type PermissionStorage interface {
+	StoreGroup(perm Perm) error
+	StoreUser(perm Perm) error
+	GroupCanDo(perm Perm) (bool, error)
+	AccountCanDo(perm Perm) (bool, error)
+}
+
+type PostgresPermissionStorage struct {
+	Context context.Context
+	Config  config.Config
+}
+
+func NewPermissionStorage(c config.Config) PermissionStorage {
+	if c.StorageBackend == config.StorageMemory {
+		return NewMemoryPermissionStorage(c)
+	}
+
+	return NewPostgresPermissionStorage(c)
+}
+
+func NewPostgresPermissionStorage(c config.Config) *PostgresPermissionStorage {
+	return &PostgresPermissionStorage{
+		Context: context.Background(),
+		Config:  c,
+	}
+}
+
+func (p PostgresPermissionStorage) getConnection() (*pgx.Conn, error) {
+	conn, err := pgx.Connect(
+		p.Context,
+		fmt.Sprintf(
+			"postgres://%s:%s@%s:%s/%s",
+			p.Config.RDS.Username,
+			p.Config.RDS.Password,
+			p.Config.RDS.Hostname,
+			p.Config.RDS.Port,
+			p.Config.RDS.Database))
+	if err != nil {
+		return nil, bugLog.Errorf("getConnection: %+v", err)
+	}
+
+	return conn, nil
+}
+
+func (p PostgresPermissionStorage) closeConnection(conn *pgx.Conn) {
+	if err := conn.Close(p.Context); err != nil {
+		bugLog.Debugf("close getConnection: %+v", err)
+	}
+}
+
+func (p PostgresPermissionStorage) StoreGroup(perm Perm) error {
+	conn, err := p.getConnection()
+	if err != nil {
+		return bugLog.Errorf("storeGroup Connection: %+v", err)
+	}
+	defer p.closeConnection(conn)
+
+	if _, err := conn.Exec(p.Context,
+		"INSERT INTO permission (key, action, permission_group) VALUES($1, $2, $3)",
+		perm.Key,
+		perm.Action,
+		perm.Group); err != nil {
+		return bugLog.Errorf("exec: %+v", err)
+	}
+
+	return nil
+}
+
+func (p PostgresPermissionStorage) StoreUser(perm Perm) error {
+	conn, err := p.getConnection()
+	if err != nil {
+		return bugLog.Errorf("storeUser Connection: %+v", err)
+	}
+	defer p.closeConnection(conn)
+
+	if _, err := conn.Exec(p.Context,
+		"INSERT INTO account_permission (key, action, account_id) VALUES ($1, $2, $3)",
+		perm.Key,
+		perm.Action,
+		perm.AccountID); err != nil {
+		return bugLog.Errorf("exec: %+v", err)
+	}
+
+	return nil
+}
+
+func (p PostgresPermissionStorage) GroupCanDo(perm Perm) (bool, error) {
+	conn, err := p.getConnection()
+	if err != nil {
+		return false, bugLog.Errorf("groupCanDo Connection: %+v", err)
+	}
+	defer p.closeConnection(conn)
+
+	var canDo = false
+	if perm.Action == "*" {
+		if err := conn.QueryRow(p.Context,
+			"SELECT TRUE FROM permission WHERE key = $1 AND permission_group = $2 LIMIT 1",
+			perm.Key,
+			perm.Group).Scan(&canDo); err != nil {
+			return false, bugLog.Errorf("* action: %+v", err)
+		}
+	}
+
+	if err := conn.QueryRow(p.Context,
+		"SELECT TRUE FROM permission WHERE key = $1 AND `action` = $2 AND permission_group = $3 LIMIT 1",
+		perm.Key,
+		perm.Action,
+		perm.Group).Scan(&canDo); err != nil {
+		return false, bugLog.Errorf("* action: %+v", err)
+	}
+
+	return canDo, nil
+}
+
+func (p PostgresPermissionStorage) AccountCanDo(perm Perm) (bool, error) {
+	conn, err := p.getConnection()
+	if err != nil {
+		return false, bugLog.Errorf("accountCanDo Connection: %+v", err)
+	}
+	defer p.closeConnection(conn)
+
+	var canDo = false
+	if err := conn.QueryRow(p.Context,
+		"SELECT TRUE FROM account_permission WHERE key = $1 AND action = $2 AND account_id = $3 LIMIT 1",
+		perm.Key,
+		perm.Action,
+		perm.AccountID).Scan(&canDo); err != nil {
+		return false, bugLog.Errorf("* action: %+v", err)
+	}
+
+	return canDo, nil
+}
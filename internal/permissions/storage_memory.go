@@ -0,0 +1,83 @@
+package permissions
+
+import (
+	"sync"
+
+	"github.com/bugfixes/celeste/internal/config"
+)
+
+type permissionMemory struct {
+	sync.RWMutex
+
+	groups   []Perm
+	accounts []Perm
+}
+
+// sharedPermissionMemory is kept for the life of the process so every MemoryPermissionStorage sees the same permissions
+var sharedPermissionMemory = &permissionMemory{}
+
+// ResetMemory empties the permissions every MemoryPermissionStorage shares
+func ResetMemory() {
+	sharedPermissionMemory.Lock()
+	defer sharedPermissionMemory.Unlock()
+
+	sharedPermissionMemory.groups = nil
+	sharedPermissionMemory.accounts = nil
+}
+
+type MemoryPermissionStorage struct {
+	Config config.Config
+
+	memory *permissionMemory
+}
+
+func NewMemoryPermissionStorage(c config.Config) *MemoryPermissionStorage {
+	return &MemoryPermissionStorage{
+		Config: c,
+		memory: sharedPermissionMemory,
+	}
+}
+
+func (p MemoryPermissionStorage) StoreGroup(perm Perm) error {
+	p.memory.Lock()
+	defer p.memory.Unlock()
+
+	p.memory.groups = append(p.memory.groups, perm)
+
+	return nil
+}
+
+func (p MemoryPermissionStorage) StoreUser(perm Perm) error {
+	p.memory.Lock()
+	defer p.memory.Unlock()
+
+	p.memory.accounts = append(p.memory.accounts, perm)
+
+	return nil
+}
+
+func (p MemoryPermissionStorage) GroupCanDo(perm Perm) (bool, error) {
+	p.memory.RLock()
+	defer p.memory.RUnlock()
+
+	for _, g := range p.memory.groups {
+		if g.Key == perm.Key && g.Group == perm.Group && (perm.Action == "*" || g.Action == perm.Action) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (p MemoryPermissionStorage) AccountCanDo(perm Perm) (bool, error) {
+	p.memory.RLock()
+	defer p.memory.RUnlock()
+
+	for _, a := range p.memory.accounts {
+		if a.Key == perm.Key && a.AccountID == perm.AccountID && a.Action == perm.Action {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
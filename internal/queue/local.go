@@ -0,0 +1,114 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/bugfixes/celeste/internal/config"
+	bugLog "github.com/bugfixes/go-bugfixes/logs"
+)
+
+// localCapacity is how many messages the local queue holds before Send starts failing
+const localCapacity = 1000
+
+type localMemory struct {
+	sync.Mutex
+
+	messages    chan Message
+	deadLetters []Message
+	sent        int
+}
+
+// sharedLocalMemory is kept for the life of the process so the handlers and the worker share a queue
+var sharedLocalMemory = &localMemory{
+	messages: make(chan Message, localCapacity),
+}
+
+// LocalQueue runs the queue inside the process, it is for development and loses everything on restart
+type LocalQueue struct {
+	Config config.Config
+
+	memory *localMemory
+}
+
+func NewLocalQueue(c config.Config) *LocalQueue {
+	return &LocalQueue{
+		Config: c,
+		memory: sharedLocalMemory,
+	}
+}
+
+func (q LocalQueue) Send(m Message) error {
+	if m.ID == "" {
+		q.memory.Lock()
+		q.memory.sent++
+		m.ID = fmt.Sprintf("local-%d", q.memory.sent)
+		q.memory.Unlock()
+	}
+
+	select {
+	case q.memory.messages <- m:
+		return nil
+	default:
+		return bugLog.Errorf("local send: queue is full")
+	}
+}
+
+// Receive waits up to a second for a message, then takes whatever else is already waiting
+func (q LocalQueue) Receive(ctx context.Context) ([]Message, error) {
+	msgs := []Message{}
+
+	select {
+	case <-ctx.Done():
+		return msgs, ctx.Err()
+	case <-time.After(time.Second):
+		return msgs, nil
+	case m := <-q.memory.messages:
+		msgs = append(msgs, m)
+	}
+
+	for len(msgs) < 10 {
+		select {
+		case m := <-q.memory.messages:
+			msgs = append(msgs, m)
+		default:
+			return msgs, nil
+		}
+	}
+
+	return msgs, nil
+}
+
+func (q LocalQueue) Delete(m Message) error {
+	return nil
+}
+
+func (q LocalQueue) Retry(m Message, delay time.Duration) error {
+	time.AfterFunc(delay, func() {
+		if err := q.Send(m); err != nil {
+			bugLog.Debugf("local retry: %+v", err)
+		}
+	})
+
+	return nil
+}
+
+func (q LocalQueue) DeadLetter(m Message, reason error) error {
+	q.memory.Lock()
+	defer q.memory.Unlock()
+
+	bugLog.Infof("local deadLetter %s: %+v", m.ID, reason)
+	q.memory.deadLetters = append(q.memory.deadLetters, m)
+
+	return nil
+}
+
+// DeadLetters is everything that has run out of attempts
+func (q LocalQueue) DeadLetters() []Message {
+	q.memory.Lock()
+	defer q.memory.Unlock()
+
+	return append([]Message{}, q.memory.deadLetters...)
+}
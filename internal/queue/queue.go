@@ -0,0 +1,59 @@
+package queue
+
+import (
+	"context"
+	"time"
+
+	"github.com/bugfixes/celeste/internal/config"
+	bugLog "github.com/bugfixes/go-bugfixes/logs"
+)
+
+// MaxBackoff is the longest a retry waits, it is also the longest delay SQS allows on a message
+const MaxBackoff = 15 * time.Minute
+
+type Message struct {
+	ID       string
+	Body     []byte
+	Attempts int
+
+	receipt string
+}
+
+//go:generate mockery --name=Queue
+type Queue interface {
+	Send(m Message) error
+	Receive(ctx context.Context) ([]Message, error)
+	Delete(m Message) error
+	Retry(m Message, delay time.Duration) error
+	DeadLetter(m Message, reason error) error
+}
+
+func NewQueue(c config.Config) (Queue, error) {
+	if c.QueueBackend == config.QueueLocal {
+		return NewLocalQueue(c), nil
+	}
+
+	q, err := NewSQSQueue(c)
+	if err != nil {
+		return nil, bugLog.Errorf("newQueue: %+v", err)
+	}
+
+	return q, nil
+}
+
+// Backoff doubles the wait with every attempt, starting at two seconds
+func Backoff(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	if attempt > 10 {
+		return MaxBackoff
+	}
+
+	delay := time.Second << uint(attempt)
+	if delay > MaxBackoff {
+		return MaxBackoff
+	}
+
+	return delay
+}
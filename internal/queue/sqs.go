@@ -0,0 +1,157 @@
+package queue
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/bugfixes/celeste/internal/config"
+	bugLog "github.com/bugfixes/go-bugfixes/logs"
+)
+
+const (
+	attemptsAttribute = "attempts"
+	errorAttribute    = "error"
+)
+
+type SQSQueue struct {
+	Config config.Config
+
+	svc           *sqs.SQS
+	url           string
+	deadLetterURL string
+}
+
+func NewSQSQueue(c config.Config) (*SQSQueue, error) {
+	sess, err := config.BuildSession(c)
+	if err != nil {
+		return nil, bugLog.Errorf("sqsQueue session: %+v", err)
+	}
+
+	q := &SQSQueue{
+		Config: c,
+		svc:    sqs.New(sess),
+	}
+
+	if q.url, err = q.queueURL(c.Queues.Name); err != nil {
+		return nil, bugLog.Errorf("sqsQueue queue: %+v", err)
+	}
+	if q.deadLetterURL, err = q.queueURL(c.Queues.DeadLetter); err != nil {
+		return nil, bugLog.Errorf("sqsQueue deadletter: %+v", err)
+	}
+
+	return q, nil
+}
+
+func (q SQSQueue) queueURL(name string) (string, error) {
+	result, err := q.svc.GetQueueUrl(&sqs.GetQueueUrlInput{
+		QueueName: aws.String(name),
+	})
+	if err != nil {
+		return "", bugLog.Errorf("queueURL %s: %+v", name, err)
+	}
+
+	return aws.StringValue(result.QueueUrl), nil
+}
+
+func (q SQSQueue) send(url string, m Message, delay time.Duration, attributes map[string]*sqs.MessageAttributeValue) error {
+	if attributes == nil {
+		attributes = map[string]*sqs.MessageAttributeValue{}
+	}
+	attributes[attemptsAttribute] = &sqs.MessageAttributeValue{
+		DataType:    aws.String("Number"),
+		StringValue: aws.String(strconv.Itoa(m.Attempts)),
+	}
+
+	if delay > MaxBackoff {
+		delay = MaxBackoff
+	}
+
+	if _, err := q.svc.SendMessage(&sqs.SendMessageInput{
+		QueueUrl:          aws.String(url),
+		MessageBody:       aws.String(string(m.Body)),
+		DelaySeconds:      aws.Int64(int64(delay / time.Second)),
+		MessageAttributes: attributes,
+	}); err != nil {
+		return bugLog.Errorf("sqs send: %+v", err)
+	}
+
+	return nil
+}
+
+func (q SQSQueue) Send(m Message) error {
+	return q.send(q.url, m, 0, nil)
+}
+
+// Receive long polls, so an empty queue costs one request every twenty seconds
+func (q SQSQueue) Receive(ctx context.Context) ([]Message, error) {
+	msgs := []Message{}
+
+	result, err := q.svc.ReceiveMessageWithContext(ctx, &sqs.ReceiveMessageInput{
+		QueueUrl:              aws.String(q.url),
+		MaxNumberOfMessages:   aws.Int64(10),
+		WaitTimeSeconds:       aws.Int64(20),
+		MessageAttributeNames: []*string{aws.String(attemptsAttribute)},
+	})
+	if err != nil {
+		return msgs, bugLog.Errorf("sqs receive: %+v", err)
+	}
+
+	for _, sm := range result.Messages {
+		m := Message{
+			ID:      aws.StringValue(sm.MessageId),
+			Body:    []byte(aws.StringValue(sm.Body)),
+			receipt: aws.StringValue(sm.ReceiptHandle),
+		}
+		if a, ok := sm.MessageAttributes[attemptsAttribute]; ok {
+			m.Attempts, _ = strconv.Atoi(aws.StringValue(a.StringValue))
+		}
+		msgs = append(msgs, m)
+	}
+
+	return msgs, nil
+}
+
+func (q SQSQueue) Delete(m Message) error {
+	if _, err := q.svc.DeleteMessage(&sqs.DeleteMessageInput{
+		QueueUrl:      aws.String(q.url),
+		ReceiptHandle: aws.String(m.receipt),
+	}); err != nil {
+		return bugLog.Errorf("sqs delete: %+v", err)
+	}
+
+	return nil
+}
+
+// Retry sends the message again with its progress and attempt count, then drops the one that failed
+func (q SQSQueue) Retry(m Message, delay time.Duration) error {
+	if err := q.send(q.url, m, delay, nil); err != nil {
+		return bugLog.Errorf("sqs retry send: %+v", err)
+	}
+	if err := q.Delete(m); err != nil {
+		return bugLog.Errorf("sqs retry delete: %+v", err)
+	}
+
+	return nil
+}
+
+func (q SQSQueue) DeadLetter(m Message, reason error) error {
+	attributes := map[string]*sqs.MessageAttributeValue{}
+	if reason != nil {
+		attributes[errorAttribute] = &sqs.MessageAttributeValue{
+			DataType:    aws.String("String"),
+			StringValue: aws.String(reason.Error()),
+		}
+	}
+
+	if err := q.send(q.deadLetterURL, m, 0, attributes); err != nil {
+		return bugLog.Errorf("sqs deadLetter send: %+v", err)
+	}
+	if err := q.Delete(m); err != nil {
+		return bugLog.Errorf("sqs deadLetter delete: %+v", err)
+	}
+
+	return nil
+}
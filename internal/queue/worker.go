@@ -0,0 +1,79 @@
+package queue
+
+import (
+	"context"
+	"time"
+
+	"github.com/bugfixes/celeste/internal/config"
+	bugLog "github.com/bugfixes/go-bugfixes/logs"
+)
+
+// Handler does the work for a message, on error it can change the body so the retry carries its progress
+type Handler func(m *Message) error
+
+type Worker struct {
+	Queue       Queue
+	Handler     Handler
+	MaxAttempts int
+	Backoff     func(attempt int) time.Duration
+}
+
+func NewWorker(c config.Config, q Queue, h Handler) *Worker {
+	return &Worker{
+		Queue:       q,
+		Handler:     h,
+		MaxAttempts: c.MaxAttempts,
+		Backoff:     Backoff,
+	}
+}
+
+// Run takes messages off the queue until the context is done
+func (w Worker) Run(ctx context.Context) {
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+
+		msgs, err := w.Queue.Receive(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			bugLog.Debugf("worker receive: %+v", err)
+			time.Sleep(time.Second)
+			continue
+		}
+
+		for _, m := range msgs {
+			if err := w.Process(m); err != nil {
+				bugLog.Debugf("worker process: %+v", err)
+			}
+		}
+	}
+}
+
+// Process runs the handler once, failures are retried after a backoff until they run out of attempts
+func (w Worker) Process(m Message) error {
+	m.Attempts++
+
+	err := w.Handler(&m)
+	if err == nil {
+		if err := w.Queue.Delete(m); err != nil {
+			return bugLog.Errorf("worker delete: %+v", err)
+		}
+		return nil
+	}
+
+	if m.Attempts >= w.MaxAttempts {
+		if err := w.Queue.DeadLetter(m, err); err != nil {
+			return bugLog.Errorf("worker deadLetter: %+v", err)
+		}
+		return nil
+	}
+
+	if err := w.Queue.Retry(m, w.Backoff(m.Attempts)); err != nil {
+		return bugLog.Errorf("worker retry: %+v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,96 @@
+package queue_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bugfixes/celeste/internal/queue"
+	"github.com/bugfixes/celeste/internal/queue/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestWorker_Process(t *testing.T) {
+	failed := errors.New("tester")
+
+	tests := []struct {
+		name     string
+		attempts int
+		handler  queue.Handler
+		setup    func(q *mocks.Queue)
+	}{
+		{
+			name: "handled",
+			handler: func(m *queue.Message) error {
+				return nil
+			},
+			setup: func(q *mocks.Queue) {
+				q.On("Delete", mock.MatchedBy(func(m queue.Message) bool {
+					return m.Attempts == 1
+				})).Return(nil)
+			},
+		},
+		{
+			name: "retried with progress",
+			handler: func(m *queue.Message) error {
+				m.Body = []byte("progress")
+				return failed
+			},
+			setup: func(q *mocks.Queue) {
+				q.On("Retry", mock.MatchedBy(func(m queue.Message) bool {
+					return m.Attempts == 1 && string(m.Body) == "progress"
+				}), time.Second*2).Return(nil)
+			},
+		},
+		{
+			name:     "dead lettered",
+			attempts: 2,
+			handler: func(m *queue.Message) error {
+				return failed
+			},
+			setup: func(q *mocks.Queue) {
+				q.On("DeadLetter", mock.MatchedBy(func(m queue.Message) bool {
+					return m.Attempts == 3
+				}), failed).Return(nil)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			q := &mocks.Queue{}
+			test.setup(q)
+
+			w := queue.Worker{
+				Queue:       q,
+				Handler:     test.handler,
+				MaxAttempts: 3,
+				Backoff:     queue.Backoff,
+			}
+			err := w.Process(queue.Message{ID: "tester", Attempts: test.attempts})
+			if passed := assert.Nil(t, err); !passed {
+				t.Errorf("process err: %+v", err)
+			}
+			q.AssertExpectations(t)
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempt int
+		expect  time.Duration
+	}{
+		{attempt: 1, expect: 2 * time.Second},
+		{attempt: 3, expect: 8 * time.Second},
+		{attempt: 20, expect: queue.MaxBackoff},
+	}
+
+	for _, test := range tests {
+		resp := queue.Backoff(test.attempt)
+		if passed := assert.Equal(t, test.expect, resp); !passed {
+			t.Errorf("backoff expect: %v, got: %v", test.expect, resp)
+		}
+	}
+}
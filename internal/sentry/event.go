@@ -0,0 +1,284 @@
+package sentry
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/bugfixes/celeste/internal/bug"
+)
+
+type Frame struct {
+	Filename string `json:"filename"`
+	AbsPath  string `json:"abs_path"`
+	Function string `json:"function"`
+	Module   string `json:"module"`
+	Lineno   int    `json:"lineno"`
+	InApp    *bool  `json:"in_app"`
+}
+
+// Stacktrace frames are oldest first, the frame that raised is the last one
+type Stacktrace struct {
+	Frames []Frame `json:"frames"`
+}
+
+type Exception struct {
+	Type       string      `json:"type"`
+	Value      string      `json:"value"`
+	Module     string      `json:"module"`
+	Stacktrace *Stacktrace `json:"stacktrace"`
+}
+
+type LogEntry struct {
+	Message   string `json:"message"`
+	Formatted string `json:"formatted"`
+}
+
+// Event is the part of a sentry event celeste uses, message, exception and tags
+// each come in more than one shape depending on the SDK
+type Event struct {
+	EventID     string          `json:"event_id"`
+	Level       string          `json:"level"`
+	Logger      string          `json:"logger"`
+	Platform    string          `json:"platform"`
+	Culprit     string          `json:"culprit"`
+	Release     string          `json:"release"`
+	Environment string          `json:"environment"`
+	Message     json.RawMessage `json:"message"`
+	LogEntry    *LogEntry       `json:"logentry"`
+	Exception   json.RawMessage `json:"exception"`
+	Stacktrace  *Stacktrace     `json:"stacktrace"`
+	Tags        json.RawMessage `json:"tags"`
+}
+
+func (e Event) message() string {
+	if e.LogEntry != nil {
+		if e.LogEntry.Formatted != "" {
+			return e.LogEntry.Formatted
+		}
+		if e.LogEntry.Message != "" {
+			return e.LogEntry.Message
+		}
+	}
+
+	if len(e.Message) == 0 {
+		return ""
+	}
+	var s string
+	if err := json.Unmarshal(e.Message, &s); err == nil {
+		return s
+	}
+	le := LogEntry{}
+	if err := json.Unmarshal(e.Message, &le); err == nil {
+		if le.Formatted != "" {
+			return le.Formatted
+		}
+		return le.Message
+	}
+
+	return ""
+}
+
+func (e Event) exceptions() []Exception {
+	if len(e.Exception) == 0 {
+		return nil
+	}
+
+	values := struct {
+		Values []Exception `json:"values"`
+	}{}
+	if err := json.Unmarshal(e.Exception, &values); err == nil && len(values.Values) > 0 {
+		return values.Values
+	}
+
+	list := []Exception{}
+	if err := json.Unmarshal(e.Exception, &list); err == nil {
+		return list
+	}
+
+	return nil
+}
+
+// tags takes both the object and the list of pairs form, the release and environment are kept as tags too
+func (e Event) tags() map[string]string {
+	tags := map[string]string{}
+
+	if len(e.Tags) > 0 {
+		obj := map[string]interface{}{}
+		if err := json.Unmarshal(e.Tags, &obj); err == nil {
+			for k, v := range obj {
+				tags[k] = fmt.Sprint(v)
+			}
+		} else {
+			pairs := [][]interface{}{}
+			if err := json.Unmarshal(e.Tags, &pairs); err == nil {
+				for _, pair := range pairs {
+					if len(pair) == 2 {
+						tags[fmt.Sprint(pair[0])] = fmt.Sprint(pair[1])
+					}
+				}
+			}
+		}
+	}
+
+	if e.Release != "" {
+		tags["release"] = e.Release
+	}
+	if e.Environment != "" {
+		tags["environment"] = e.Environment
+	}
+	if e.Logger != "" {
+		tags["logger"] = e.Logger
+	}
+
+	return tags
+}
+
+// IsBug is true for anything thrown, and for messages at error or above
+func (e Event) IsBug() bool {
+	if len(e.exceptions()) > 0 {
+		return true
+	}
+
+	switch e.Level {
+	case "error", "fatal":
+		return true
+	}
+
+	return false
+}
+
+// ConvertLevel maps the sentry levels onto the ones celeste understands
+func ConvertLevel(level string) string {
+	switch level {
+	case "fatal":
+		return "fatal"
+	case "error", "":
+		return "error"
+	case "warning":
+		return "warn"
+	case "info":
+		return "info"
+	case "debug":
+		return "debug"
+	}
+
+	return "unknown"
+}
+
+func frameFile(f Frame) string {
+	if f.AbsPath != "" {
+		return f.AbsPath
+	}
+	return f.Filename
+}
+
+func frameFunction(f Frame) string {
+	if f.Function != "" {
+		return f.Function
+	}
+	return f.Module
+}
+
+// culpritFrame is the most recent frame in the app, or the most recent frame if none are marked
+func culpritFrame(frames []Frame) (Frame, bool) {
+	for i := len(frames) - 1; i >= 0; i-- {
+		if frames[i].InApp != nil && *frames[i].InApp {
+			return frames[i], true
+		}
+	}
+	if len(frames) > 0 {
+		return frames[len(frames)-1], true
+	}
+
+	return Frame{}, false
+}
+
+func (e Event) frames() []Frame {
+	exceptions := e.exceptions()
+	for i := len(exceptions) - 1; i >= 0; i-- {
+		if exceptions[i].Stacktrace != nil && len(exceptions[i].Stacktrace.Frames) > 0 {
+			return exceptions[i].Stacktrace.Frames
+		}
+	}
+	if e.Stacktrace != nil {
+		return e.Stacktrace.Frames
+	}
+
+	return nil
+}
+
+// title is the exception that was raised last, or the message when nothing was
+func (e Event) title() string {
+	exceptions := e.exceptions()
+	if len(exceptions) == 0 {
+		return e.message()
+	}
+
+	ex := exceptions[len(exceptions)-1]
+	switch {
+	case ex.Type != "" && ex.Value != "":
+		return fmt.Sprintf("%s: %s", ex.Type, ex.Value)
+	case ex.Type != "":
+		return ex.Type
+	default:
+		return ex.Value
+	}
+}
+
+// raw writes the stack in the python traceback layout, sentry frames are already in that order,
+// so bug.ParseStack reads it back the same way it reads any other trace
+func (e Event) raw() string {
+	frames := e.frames()
+	if len(frames) == 0 {
+		return e.title()
+	}
+
+	lines := []string{"Traceback (most recent call last):"}
+	for _, f := range frames {
+		lines = append(lines, fmt.Sprintf("  File \"%s\", line %d, in %s", frameFile(f), f.Lineno, frameFunction(f)))
+	}
+	lines = append(lines, e.title())
+
+	return strings.Join(lines, "\n")
+}
+
+func (e Event) Bug() bug.Bug {
+	b := bug.Bug{
+		Bug:         e.title(),
+		Raw:         e.raw(),
+		Level:       ConvertLevel(e.Level),
+		Release:     e.Release,
+		Environment: e.Environment,
+		Tags:        e.tags(),
+	}
+
+	if f, ok := culpritFrame(e.frames()); ok {
+		b.File = frameFile(f)
+		b.LineNumber = f.Lineno
+		b.Line = strconv.Itoa(f.Lineno)
+	}
+
+	return b
+}
+
+// Log keeps the tags as logfmt, so they stay with the entry
+func (e Event) Log() bug.Log {
+	l := bug.Log{
+		Level:       ConvertLevel(e.Level),
+		Environment: e.Environment,
+		Log:         e.message(),
+		LogFmt:      bug.FormatLogFmt(e.tags()),
+	}
+
+	if frames := e.frames(); len(frames) > 0 {
+		l.Stack = []byte(e.raw())
+		if f, ok := culpritFrame(frames); ok {
+			l.File = frameFile(f)
+			l.Line = strconv.Itoa(f.Lineno)
+		}
+	}
+
+	return l
+}
@@ -0,0 +1,399 @@
+package sentry
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/bugfixes/celeste/internal/agent"
+	"github.com/bugfixes/celeste/internal/bug"
+	"github.com/bugfixes/celeste/internal/config"
+	bugLog "github.com/bugfixes/go-bugfixes/logs"
+)
+
+// MaxBodyBytes is the largest event or envelope that will be read, after decompression
+const MaxBodyBytes = 5 << 20
+
+type Sentry struct {
+	Config config.Config
+
+	Bugs bug.ProcessBug
+	Logs bug.ProcessLog
+}
+
+func NewSentry(c config.Config) *Sentry {
+	return &Sentry{
+		Config: c,
+		Bugs:   bug.NewBug(c),
+		Logs:   bug.NewLog(c),
+	}
+}
+
+func errorReport(w http.ResponseWriter, status int, textError string, wrappedError error) {
+	bugLog.Debugf("sentry errorReport: %+v", wrappedError)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(struct {
+		Detail string `json:"detail"`
+	}{
+		Detail: fmt.Sprintf("%s: %+v", textError, wrappedError),
+	}); err != nil {
+		bugLog.Debugf("sentry errorReport json: %+v", err)
+	}
+}
+
+// parseAuth reads the key and secret out of the X-Sentry-Auth header, or the query string older SDKs use
+func parseAuth(r *http.Request) agent.Credentials {
+	creds := agent.Credentials{
+		Key:    r.URL.Query().Get("sentry_key"),
+		Secret: r.URL.Query().Get("sentry_secret"),
+	}
+
+	header := r.Header.Get("X-Sentry-Auth")
+	if header == "" {
+		header = r.Header.Get("Authorization")
+	}
+	header = strings.TrimSpace(header)
+	if !strings.HasPrefix(strings.ToLower(header), "sentry ") {
+		return creds
+	}
+
+	for _, part := range strings.Split(header[len("sentry "):], ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "sentry_key":
+			creds.Key = kv[1]
+		case "sentry_secret":
+			creds.Secret = kv[1]
+		}
+	}
+
+	return creds
+}
+
+// ParseDSN takes the agent key, and the secret of an old style DSN, out of a DSN, https://key@host/project
+func ParseDSN(dsn string) (agent.Credentials, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return agent.Credentials{}, bugLog.Errorf("parseDSN: %+v", err)
+	}
+	if u.User == nil {
+		return agent.Credentials{}, bugLog.Errorf("parseDSN: no key in dsn")
+	}
+
+	secret, _ := u.User.Password()
+	return agent.Credentials{
+		Key:    u.User.Username(),
+		Secret: secret,
+	}, nil
+}
+
+// findAgent finds the agent from the DSN's public key, the agent key, a secret is only checked when the SDK sends one,
+// current SDKs send the key alone
+func (s Sentry) findAgent(creds agent.Credentials) (agent.Agent, error) {
+	a := agent.Agent{
+		Credentials: creds,
+	}
+	if a.Key == "" {
+		return a, bugLog.Errorf("findAgent: the dsn needs the agent key")
+	}
+
+	if a.Secret != "" {
+		if err := agent.NewAgent(s.Config).Find(&a); err != nil {
+			return a, bugLog.Errorf("findAgent: %+v", err)
+		}
+		return a, nil
+	}
+
+	if err := agent.NewAgent(s.Config).FindByKey(&a); err != nil {
+		return a, bugLog.Errorf("findAgent findByKey: %+v", err)
+	}
+
+	return a, nil
+}
+
+// readBody undoes the compression SDKs use, including the base64 zlib bodies of the old store endpoint
+func readBody(r *http.Request) ([]byte, error) {
+	var reader io.Reader = r.Body
+
+	switch r.Header.Get("Content-Encoding") {
+	case "gzip":
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return nil, bugLog.Errorf("readBody gzip: %+v", err)
+		}
+		defer func() {
+			if err := gz.Close(); err != nil {
+				bugLog.Debugf("readBody gzip close: %+v", err)
+			}
+		}()
+		reader = gz
+	case "deflate":
+		zr, err := zlib.NewReader(r.Body)
+		if err != nil {
+			return nil, bugLog.Errorf("readBody deflate: %+v", err)
+		}
+		defer func() {
+			if err := zr.Close(); err != nil {
+				bugLog.Debugf("readBody deflate close: %+v", err)
+			}
+		}()
+		reader = zr
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(reader, MaxBodyBytes))
+	if err != nil {
+		return nil, bugLog.Errorf("readBody read: %+v", err)
+	}
+
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] != '{' {
+		decoded, err := base64.StdEncoding.DecodeString(string(body))
+		if err != nil {
+			return body, nil
+		}
+		zr, err := zlib.NewReader(bytes.NewReader(decoded))
+		if err != nil {
+			return body, nil
+		}
+		inflated, err := ioutil.ReadAll(io.LimitReader(zr, MaxBodyBytes))
+		if err != nil {
+			return nil, bugLog.Errorf("readBody inflate: %+v", err)
+		}
+		return inflated, nil
+	}
+
+	return body, nil
+}
+
+// Ingest turns the event into a queued bug or a stored log for the agent
+func (s Sentry) Ingest(a agent.Agent, e Event) error {
+	if e.IsBug() {
+		b := e.Bug()
+		b.Agent.Key = a.Key
+		if err := s.Bugs.Enqueue(b); err != nil {
+			return bugLog.Errorf("sentry ingest enqueue: %+v", err)
+		}
+		return nil
+	}
+
+	l := e.Log()
+	l.Agent.Credentials = a.Credentials
+	l.Agent.Account = a.Account
+	l.Agent.Identifier = a.Identifier
+	l.Verified = true
+	if err := s.Logs.GenerateLogInfo(&l, a.Key); err != nil {
+		return bugLog.Errorf("sentry ingest generateLogInfo: %+v", err)
+	}
+	if err := s.Logs.StoreLog(&l); err != nil {
+		return bugLog.Errorf("sentry ingest storeLog: %+v", err)
+	}
+
+	return nil
+}
+
+func respond(w http.ResponseWriter, id string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(struct {
+		ID string `json:"id"`
+	}{
+		ID: id,
+	}); err != nil {
+		bugLog.Debugf("sentry respond json: %+v", err)
+	}
+}
+
+// StoreHandler takes the single event the store endpoint is sent
+func (s Sentry) StoreHandler(w http.ResponseWriter, r *http.Request) {
+	defer func() {
+		if err := r.Body.Close(); err != nil {
+			bugLog.Debugf("storeHandler body close: %+v", err)
+		}
+	}()
+
+	a, err := s.findAgent(parseAuth(r))
+	if err != nil {
+		errorReport(w, http.StatusUnauthorized, "invalid dsn", err)
+		return
+	}
+
+	body, err := readBody(r)
+	if err != nil {
+		errorReport(w, http.StatusBadRequest, "invalid body", err)
+		return
+	}
+
+	e := Event{}
+	if err := json.Unmarshal(body, &e); err != nil {
+		errorReport(w, http.StatusBadRequest, "invalid event", err)
+		return
+	}
+
+	if err := s.Ingest(a, e); err != nil {
+		errorReport(w, http.StatusInternalServerError, "ingest", err)
+		return
+	}
+
+	respond(w, e.EventID)
+}
+
+type envelopeHeader struct {
+	EventID string `json:"event_id"`
+	DSN     string `json:"dsn"`
+}
+
+type itemHeader struct {
+	Type   string `json:"type"`
+	Length *int   `json:"length"`
+}
+
+// envelopeDSN is the DSN in the envelope's header, only the first line is read so the agent is known before the items are
+func envelopeDSN(body []byte) (string, error) {
+	line := body
+	if i := bytes.IndexByte(body, '\n'); i >= 0 {
+		line = body[:i]
+	}
+
+	header := envelopeHeader{}
+	if err := json.Unmarshal(bytes.TrimRight(line, "\r"), &header); err != nil {
+		return "", bugLog.Errorf("envelopeDSN unmarshal: %+v", err)
+	}
+
+	return header.DSN, nil
+}
+
+// ParseEnvelope pulls the events out of an envelope, every other kind of item is skipped
+func ParseEnvelope(body []byte) (envelopeHeader, []Event, error) {
+	header := envelopeHeader{}
+	events := []Event{}
+
+	reader := bufio.NewReader(bytes.NewReader(body))
+	line, err := readLine(reader)
+	if err != nil {
+		return header, events, bugLog.Errorf("parseEnvelope header: %+v", err)
+	}
+	if err := json.Unmarshal(line, &header); err != nil {
+		return header, events, bugLog.Errorf("parseEnvelope header unmarshal: %+v", err)
+	}
+
+	for {
+		line, err := readLine(reader)
+		if errors.Is(err, io.EOF) && len(line) == 0 {
+			break
+		}
+		if len(bytes.TrimSpace(line)) == 0 {
+			if err != nil {
+				break
+			}
+			continue
+		}
+
+		item := itemHeader{}
+		if err := json.Unmarshal(line, &item); err != nil {
+			return header, events, bugLog.Errorf("parseEnvelope item header: %+v", err)
+		}
+
+		var payload []byte
+		if item.Length != nil {
+			// the length is the client's, it can't be more than the body holds
+			if *item.Length < 0 || *item.Length > len(body) {
+				return header, events, bugLog.Errorf("parseEnvelope item length: %d", *item.Length)
+			}
+			payload = make([]byte, *item.Length)
+			if _, err := io.ReadFull(reader, payload); err != nil {
+				return header, events, bugLog.Errorf("parseEnvelope item payload: %+v", err)
+			}
+			// the newline after a payload with a length is optional
+			if next, err := reader.Peek(1); err == nil && next[0] == '\n' {
+				_, _ = reader.ReadByte()
+			}
+		} else {
+			if payload, err = readLine(reader); err != nil && !errors.Is(err, io.EOF) {
+				return header, events, bugLog.Errorf("parseEnvelope item line: %+v", err)
+			}
+		}
+
+		if item.Type != "event" {
+			continue
+		}
+		e := Event{}
+		if err := json.Unmarshal(payload, &e); err != nil {
+			return header, events, bugLog.Errorf("parseEnvelope event: %+v", err)
+		}
+		if e.EventID == "" {
+			e.EventID = header.EventID
+		}
+		events = append(events, e)
+	}
+
+	return header, events, nil
+}
+
+func readLine(reader *bufio.Reader) ([]byte, error) {
+	line, err := reader.ReadBytes('\n')
+	return bytes.TrimRight(line, "\r\n"), err
+}
+
+// EnvelopeHandler takes an envelope, the DSN can be in its header instead of the request,
+// the agent is found before the items are parsed
+func (s Sentry) EnvelopeHandler(w http.ResponseWriter, r *http.Request) {
+	defer func() {
+		if err := r.Body.Close(); err != nil {
+			bugLog.Debugf("envelopeHandler body close: %+v", err)
+		}
+	}()
+
+	body, err := readBody(r)
+	if err != nil {
+		errorReport(w, http.StatusBadRequest, "invalid body", err)
+		return
+	}
+
+	creds := parseAuth(r)
+	if creds.Key == "" {
+		dsn, err := envelopeDSN(body)
+		if err != nil {
+			errorReport(w, http.StatusBadRequest, "invalid envelope", err)
+			return
+		}
+		if creds, err = ParseDSN(dsn); err != nil {
+			errorReport(w, http.StatusUnauthorized, "invalid dsn", err)
+			return
+		}
+	}
+	a, err := s.findAgent(creds)
+	if err != nil {
+		errorReport(w, http.StatusUnauthorized, "invalid dsn", err)
+		return
+	}
+
+	header, events, err := ParseEnvelope(body)
+	if err != nil {
+		errorReport(w, http.StatusBadRequest, "invalid envelope", err)
+		return
+	}
+
+	for i, e := range events {
+		if err := s.Ingest(a, e); err != nil {
+			errorReport(w, http.StatusInternalServerError, "ingest event "+strconv.Itoa(i), err)
+			return
+		}
+	}
+
+	respond(w, header.EventID)
+}
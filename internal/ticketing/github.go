@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/bugfixes/celeste/internal/agent"
@@ -100,21 +99,37 @@ func (g *Github) ParseCredentials(creds interface{}) error {
 }
 
 func (g *Github) GenerateTemplate(ticket *Ticket) (TicketTemplate, error) {
-	projectFile := ticket.File
-	if strings.Index(projectFile, g.Credentials.Repo) != 0 {
-		projectIndex := strings.Index(ticket.File, g.Credentials.Repo)
-		if projectIndex != 0 {
-			projectFile = ticket.File[(projectIndex + len(g.Credentials.Repo) + 1):]
-		}
+	projectFile := repoPath(ticket.File, g.Credentials.Repo)
+
+	stack := ""
+	if len(ticket.Frames) > 0 {
+		stack = fmt.Sprintf("## Stack\n%s\n", FramesMarkdown(ticket.Frames))
+	}
+	if ticket.Source != nil {
+		stack += fmt.Sprintf("## Source\n%s", SourceMarkdown(ticket.Source))
+	}
+
+	release := ""
+	if ticket.Release != "" {
+		release = fmt.Sprintf("### Release\n%s\n", ticket.Release)
+	}
+
+	// the link is to the commit that crashed when the agent sent it, main otherwise
+	ref := ticket.Ref
+	if ref == "" {
+		ref = "main"
 	}
 
 	title := fmt.Sprintf("File: %s, Line: %s", projectFile, ticket.Line)
 	body := fmt.Sprintf(
-		"## Bug\n```\n%s\n```\n## Raw\n```\n%s\n```\n### Report number\n%d\n### Link\n[%s](../blob/main/%s#L%s)\n### Latest Report Date\n%s\n",
+		"## Bug\n```\n%s\n```\n## Raw\n```\n%s\n```\n%s### Report number\n%d\n%s### Link\n[%s](../blob/%s/%s#L%s)\n### Latest Report Date\n%s\n",
 		ticket.Bug,
 		ticket.Raw,
+		stack,
 		ticket.TimesReported,
+		release,
 		projectFile,
+		ref,
 		projectFile,
 		ticket.Line,
 		time.Now().Format("2006-01-02 15:04:05"))
@@ -127,6 +142,9 @@ func (g *Github) GenerateTemplate(ticket *Ticket) (TicketTemplate, error) {
 	} else {
 		labels = append(labels, multiReport)
 	}
+	if ticket.NewInRelease {
+		labels = append(labels, newInRelease)
+	}
 
 	return TicketTemplate{
 		Title:  title,
@@ -265,3 +283,44 @@ func (g *Github) TicketExists(ticket *Ticket) (bool, TicketDetails, error) {
 
 	return ticketExists, td, nil
 }
+
+// Transition closes or reopens the issue, the assignee is looked up by their public email,
+// when GitHub doesn't know it they are left a comment instead
+func (g *Github) Transition(ticket *Ticket) error {
+	number, err := strconv.Atoi(remoteKey(ticket.RemoteLink))
+	if err != nil {
+		return bugLog.Errorf("github transition issue number: %+v", err)
+	}
+
+	if ticket.State != "" {
+		state := ticket.State
+		if _, _, err := g.Client.Issues.Edit(g.Context, g.Credentials.Owner, g.Credentials.Repo, number, &github.IssueRequest{
+			State: &state,
+		}); err != nil {
+			return bugLog.Errorf("github transition edit: %+v", err)
+		}
+	}
+
+	if ticket.Assignee == "" {
+		return nil
+	}
+	users, _, err := g.Client.Search.Users(g.Context, fmt.Sprintf("%s in:email", ticket.Assignee), nil)
+	if err != nil {
+		return bugLog.Errorf("github transition search users: %+v", err)
+	}
+	if len(users.Users) == 1 {
+		if _, _, err := g.Client.Issues.AddAssignees(g.Context, g.Credentials.Owner, g.Credentials.Repo, number, []string{users.Users[0].GetLogin()}); err != nil {
+			return bugLog.Errorf("github transition addAssignees: %+v", err)
+		}
+		return nil
+	}
+
+	body := fmt.Sprintf("Assigned to %s from BugFix.es", ticket.Assignee)
+	if _, _, err := g.Client.Issues.CreateComment(g.Context, g.Credentials.Owner, g.Credentials.Repo, number, &github.IssueComment{
+		Body: &body,
+	}); err != nil {
+		return bugLog.Errorf("github transition comment: %+v", err)
+	}
+
+	return nil
+}
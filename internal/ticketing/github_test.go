@@ -0,0 +1,70 @@
+package ticketing_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bugfixes/celeste/internal/config"
+	"github.com/bugfixes/celeste/internal/ticketing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGithub_GenerateTemplate(t *testing.T) {
+	tests := []struct {
+		name   string
+		ticket ticketing.Ticket
+		link   string
+		labels []string
+	}{
+		{
+			name: "no commit",
+			ticket: ticketing.Ticket{
+				Level:         "error",
+				File:          "/build/celeste/internal/bug/bug.go",
+				Line:          "12",
+				TimesReported: 1,
+			},
+			link:   "[internal/bug/bug.go](../blob/main/internal/bug/bug.go#L12)",
+			labels: []string{"error", "first report"},
+		},
+		{
+			name: "commit and new release",
+			ticket: ticketing.Ticket{
+				Level:         "error",
+				File:          "/build/celeste/internal/bug/bug.go",
+				Line:          "12",
+				TimesReported: 3,
+				Ref:           "0123456789abcdef",
+				Release:       "1.2.0",
+				NewInRelease:  true,
+			},
+			link:   "[internal/bug/bug.go](../blob/0123456789abcdef/internal/bug/bug.go#L12)",
+			labels: []string{"error", "multiple reports", "new in release"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g := ticketing.NewGithub(config.Config{})
+			g.Credentials.Repo = "celeste"
+
+			template, err := g.GenerateTemplate(&test.ticket)
+			if passed := assert.Nil(t, err); !passed {
+				t.Fatalf("generateTemplate err: %+v", err)
+			}
+
+			body, _ := template.Body.(string)
+			if passed := assert.True(t, strings.Contains(body, test.link)); !passed {
+				t.Errorf("generateTemplate link expect: %v, body: %v", test.link, body)
+			}
+			if test.ticket.Release != "" {
+				if passed := assert.True(t, strings.Contains(body, "### Release\n"+test.ticket.Release)); !passed {
+					t.Errorf("generateTemplate release missing, body: %v", body)
+				}
+			}
+			if passed := assert.Equal(t, test.labels, template.Labels); !passed {
+				t.Errorf("generateTemplate labels expect: %v, got: %v", test.labels, template.Labels)
+			}
+		})
+	}
+}
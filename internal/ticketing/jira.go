@@ -98,16 +98,23 @@ func (j Jira) generateUpdateTemplate(ticket Ticket) TicketTemplate {
 	reportLabel := strings.ReplaceAll(multiReport, " ", "_")
 	oldReportLabel := strings.ReplaceAll(firstReport, " ", "_")
 
+	labels := []interface{}{
+		map[string]interface{}{
+			"add": reportLabel,
+		},
+		map[string]interface{}{
+			"remove": oldReportLabel,
+		},
+	}
+	if ticket.NewInRelease {
+		labels = append(labels, map[string]interface{}{
+			"add": strings.ReplaceAll(newInRelease, " ", "_"),
+		})
+	}
+
 	body := map[string]interface{}{
 		"update": map[string]interface{}{
-			"labels": []interface{}{
-				map[string]interface{}{
-					"add": reportLabel,
-				},
-				map[string]interface{}{
-					"remove": oldReportLabel,
-				},
-			},
+			"labels": labels,
 		},
 		"fields": map[string]interface{}{
 			"status": map[string]interface{}{
@@ -209,6 +216,9 @@ func (j Jira) generateUpdateTemplate(ticket Ticket) TicketTemplate {
 		},
 	}
 
+	jiraSource(body, ticket.Source)
+	jiraStack(body, ticket.Frames)
+
 	return TicketTemplate{
 		Title: title,
 		Body:  body,
@@ -222,13 +232,17 @@ func (j Jira) generateCreateTemplate(ticket Ticket) TicketTemplate {
 	if ticket.TimesReported > 1 {
 		reportLabel = strings.ReplaceAll(multiReport, " ", "_")
 	}
+	labels := []interface{}{
+		ticket.Level,
+		reportLabel,
+	}
+	if ticket.NewInRelease {
+		labels = append(labels, strings.ReplaceAll(newInRelease, " ", "_"))
+	}
 
 	body := map[string]interface{}{
 		"fields": map[string]interface{}{
-			"labels": []interface{}{
-				ticket.Level,
-				reportLabel,
-			},
+			"labels": labels,
 			"project": map[string]interface{}{
 				"key": j.Credentials.JiraProject.Key,
 			},
@@ -329,6 +343,9 @@ func (j Jira) generateCreateTemplate(ticket Ticket) TicketTemplate {
 		},
 	}
 
+	jiraSource(body, ticket.Source)
+	jiraStack(body, ticket.Frames)
+
 	return TicketTemplate{
 		Title: title,
 		Body:  body,
@@ -509,3 +526,50 @@ func (j Jira) Fetch(ticket *Ticket) error {
 
 	return nil
 }
+
+// Transition takes the first of the issue's transitions into the done category to close it, or the to do one to open it,
+// the assignee is looked up by email
+func (j *Jira) Transition(ticket *Ticket) error {
+	key := remoteKey(ticket.RemoteLink)
+
+	if ticket.State != "" {
+		category := jira.StatusCategoryToDo
+		if ticket.State == TicketClosed {
+			category = jira.StatusCategoryComplete
+		}
+
+		transitions, _, err := j.Client.Issue.GetTransitionsWithContext(j.Context, key)
+		if err != nil {
+			return bugLog.Errorf("jira transition getTransitions: %+v", err)
+		}
+		transitionID := ""
+		for _, t := range transitions {
+			if t.To.StatusCategory.Key == category {
+				transitionID = t.ID
+				break
+			}
+		}
+		if transitionID == "" {
+			return bugLog.Errorf("jira transition: %s has no transition to %s", key, category)
+		}
+		if _, err := j.Client.Issue.DoTransitionWithContext(j.Context, key, transitionID); err != nil {
+			return bugLog.Errorf("jira transition doTransition: %+v", err)
+		}
+	}
+
+	if ticket.Assignee == "" {
+		return nil
+	}
+	users, _, err := j.Client.User.FindWithContext(j.Context, ticket.Assignee)
+	if err != nil {
+		return bugLog.Errorf("jira transition find user: %+v", err)
+	}
+	if len(users) == 0 {
+		return bugLog.Errorf("jira transition: no user with the email %s", ticket.Assignee)
+	}
+	if _, err := j.Client.Issue.UpdateAssigneeWithContext(j.Context, key, &users[0]); err != nil {
+		return bugLog.Errorf("jira transition updateAssignee: %+v", err)
+	}
+
+	return nil
+}
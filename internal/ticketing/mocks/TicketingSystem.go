@@ -138,6 +138,20 @@ func (_m *TicketingSystem) TicketExists(_a0 *ticketing.Ticket) (bool, ticketing.
 	return r0, r1, r2
 }
 
+// Transition provides a mock function with given fields: _a0
+func (_m *TicketingSystem) Transition(_a0 *ticketing.Ticket) error {
+	ret := _m.Called(_a0)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(*ticketing.Ticket) error); ok {
+		r0 = rf(_a0)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // Update provides a mock function with given fields: _a0
 func (_m *TicketingSystem) Update(_a0 *ticketing.Ticket) error {
 	ret := _m.Called(_a0)
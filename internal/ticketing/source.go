@@ -0,0 +1,191 @@
+package ticketing
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	bugLog "github.com/bugfixes/go-bugfixes/logs"
+)
+
+var ErrLineOutOfRange = errors.New("line is not in the file")
+
+// SourceContext is the code around the line a bug was reported on, and who last changed that line
+type SourceContext struct {
+	File      string   `json:"file"`
+	Ref       string   `json:"ref"`
+	Line      int      `json:"line"`
+	StartLine int      `json:"start_line"`
+	Lines     []string `json:"lines"`
+	Blame     Blame    `json:"blame"`
+}
+
+type Blame struct {
+	Commit string `json:"commit"`
+	Author string `json:"author"`
+	Email  string `json:"email"`
+	Login  string `json:"login"`
+}
+
+// Assignee is who to suggest for the ticket, the GitHub login when there is one
+func (b Blame) Assignee() string {
+	switch {
+	case b.Login != "":
+		return "@" + b.Login
+	case b.Author != "" && b.Email != "":
+		return fmt.Sprintf("%s <%s>", b.Author, b.Email)
+	}
+
+	return b.Author
+}
+
+//go:generate mockery --name=SourceProvider
+type SourceProvider interface {
+	// Path turns the file from the report into a path in the repo
+	Path(file string) string
+	Lines(path, ref string) ([]string, error)
+	Blame(path, ref string, line int) (Blame, error)
+}
+
+// SourceExcerpt reads the lines either side of line, a failed blame still gives the excerpt
+func SourceExcerpt(sp SourceProvider, file, ref string, line, context int) (*SourceContext, error) {
+	path := sp.Path(file)
+	lines, err := sp.Lines(path, ref)
+	if err != nil {
+		return nil, bugLog.Errorf("source excerpt lines: %+v", err)
+	}
+	if line < 1 || line > len(lines) {
+		return nil, ErrLineOutOfRange
+	}
+
+	start := line - context
+	if start < 1 {
+		start = 1
+	}
+	end := line + context
+	if end > len(lines) {
+		end = len(lines)
+	}
+
+	src := &SourceContext{
+		File:      path,
+		Ref:       ref,
+		Line:      line,
+		StartLine: start,
+		Lines:     lines[start-1 : end],
+	}
+
+	blame, err := sp.Blame(path, ref, line)
+	if err != nil {
+		bugLog.Infof("source excerpt blame: %+v", err)
+		return src, nil
+	}
+	src.Blame = blame
+
+	return src, nil
+}
+
+// SourceText numbers the lines of the excerpt, the reported line is marked
+func SourceText(src *SourceContext) string {
+	width := len(strconv.Itoa(src.StartLine + len(src.Lines) - 1))
+	lines := make([]string, 0, len(src.Lines))
+	for i, l := range src.Lines {
+		marker := " "
+		if src.StartLine+i == src.Line {
+			marker = ">"
+		}
+		lines = append(lines, fmt.Sprintf("%s%*d | %s", marker, width, src.StartLine+i, l))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// SourceMarkdown is the excerpt as a fenced block, followed by who to suggest for the ticket
+func SourceMarkdown(src *SourceContext) string {
+	md := fmt.Sprintf("```%s\n%s\n```\n", strings.TrimPrefix(filepath.Ext(src.File), "."), SourceText(src))
+	if assignee := src.Blame.Assignee(); assignee != "" {
+		md += fmt.Sprintf("Suggested assignee: %s, last changed the line in %s\n", assignee, shortCommit(src.Blame.Commit))
+	}
+
+	return md
+}
+
+func shortCommit(commit string) string {
+	if len(commit) > 7 {
+		return commit[:7]
+	}
+
+	return commit
+}
+
+// repoPath cuts the file down to its path inside the repo, files sent from a build have the checkout in front
+func repoPath(file, repo string) string {
+	if repo == "" {
+		return strings.TrimPrefix(file, "/")
+	}
+	if strings.HasPrefix(file, repo+"/") {
+		return file[len(repo)+1:]
+	}
+	if i := strings.Index(file, "/"+repo+"/"); i != -1 {
+		return file[i+len(repo)+2:]
+	}
+
+	return strings.TrimPrefix(file, "/")
+}
+
+// sourceProvider is the local checkout when there is one, otherwise the connected GitHub repo, nil for neither
+func (t Ticketing) sourceProvider(system TicketingSystem) SourceProvider {
+	if t.Config.Source.Checkout != "" {
+		return NewLocalSource(t.Config)
+	}
+	if g, ok := system.(*Github); ok && g.Client != nil {
+		return &GithubSource{
+			Github: g,
+		}
+	}
+
+	return nil
+}
+
+// AddSource puts the code around the bug's line on the ticket, the ticket is still made without it
+func (t Ticketing) AddSource(system TicketingSystem, ticket *Ticket) {
+	sp := t.sourceProvider(system)
+	if sp == nil || ticket.File == "" {
+		return
+	}
+	line, err := strconv.Atoi(ticket.Line)
+	if err != nil {
+		return
+	}
+
+	src, err := SourceExcerpt(sp, ticket.File, ticket.Ref, line, t.Config.Source.ContextLines)
+	if err != nil {
+		bugLog.Infof("ticket addSource: %+v", err)
+		return
+	}
+	ticket.Source = src
+}
+
+// jiraSource adds the excerpt to a jira description, with the suggested assignee under it
+func jiraSource(body map[string]interface{}, src *SourceContext) {
+	if src == nil {
+		return
+	}
+
+	after := []interface{}{}
+	if assignee := src.Blame.Assignee(); assignee != "" {
+		after = append(after, map[string]interface{}{
+			"type": "paragraph",
+			"content": []interface{}{
+				map[string]interface{}{
+					"type": "text",
+					"text": fmt.Sprintf("Suggested assignee: %s, last changed the line in %s", assignee, shortCommit(src.Blame.Commit)),
+				},
+			},
+		})
+	}
+
+	jiraSection(body, fmt.Sprintf("Source %s:%d", src.File, src.Line), SourceText(src), after...)
+}
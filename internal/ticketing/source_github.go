@@ -0,0 +1,133 @@
+package ticketing
+
+import (
+	"net/http"
+	"strings"
+
+	bugLog "github.com/bugfixes/go-bugfixes/logs"
+	"github.com/google/go-github/v35/github"
+)
+
+// GithubSource reads the code through the installation client the ticket is made with
+type GithubSource struct {
+	Github *Github
+}
+
+// blame is only in the GraphQL API
+const githubBlameQuery = `query($owner: String!, $repo: String!, $ref: String!, $path: String!) {
+  repository(owner: $owner, name: $repo) {
+    object(expression: $ref) {
+      ... on Commit {
+        blame(path: $path) {
+          ranges {
+            startingLine
+            endingLine
+            commit {
+              oid
+              author {
+                name
+                email
+                user {
+                  login
+                }
+              }
+            }
+          }
+        }
+      }
+    }
+  }
+}`
+
+type githubBlameResponse struct {
+	Data struct {
+		Repository struct {
+			Object struct {
+				Blame struct {
+					Ranges []struct {
+						StartingLine int `json:"startingLine"`
+						EndingLine   int `json:"endingLine"`
+						Commit       struct {
+							OID    string `json:"oid"`
+							Author struct {
+								Name  string `json:"name"`
+								Email string `json:"email"`
+								User  struct {
+									Login string `json:"login"`
+								} `json:"user"`
+							} `json:"author"`
+						} `json:"commit"`
+					} `json:"ranges"`
+				} `json:"blame"`
+			} `json:"object"`
+		} `json:"repository"`
+	} `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+func (gs *GithubSource) Path(file string) string {
+	return repoPath(file, gs.Github.Credentials.Repo)
+}
+
+func (gs *GithubSource) Lines(path, ref string) ([]string, error) {
+	fc, _, _, err := gs.Github.Client.Repositories.GetContents(gs.Github.Context, gs.Github.Credentials.Owner, gs.Github.Credentials.Repo, path, &github.RepositoryContentGetOptions{
+		Ref: ref,
+	})
+	if err != nil {
+		return nil, bugLog.Errorf("github source contents: %+v", err)
+	}
+	if fc == nil {
+		return nil, bugLog.Errorf("github source contents: %s is a directory", path)
+	}
+
+	content, err := fc.GetContent()
+	if err != nil {
+		return nil, bugLog.Errorf("github source decode: %+v", err)
+	}
+
+	return strings.Split(strings.TrimSuffix(content, "\n"), "\n"), nil
+}
+
+func (gs *GithubSource) Blame(path, ref string, line int) (Blame, error) {
+	if ref == "" {
+		ref = "HEAD"
+	}
+
+	req, err := gs.Github.Client.NewRequest(http.MethodPost, "graphql", map[string]interface{}{
+		"query": githubBlameQuery,
+		"variables": map[string]string{
+			"owner": gs.Github.Credentials.Owner,
+			"repo":  gs.Github.Credentials.Repo,
+			"ref":   ref,
+			"path":  path,
+		},
+	})
+	if err != nil {
+		return Blame{}, bugLog.Errorf("github source blame request: %+v", err)
+	}
+
+	resp := githubBlameResponse{}
+	if _, err := gs.Github.Client.Do(gs.Github.Context, req, &resp); err != nil {
+		return Blame{}, bugLog.Errorf("github source blame: %+v", err)
+	}
+	if len(resp.Errors) > 0 {
+		return Blame{}, bugLog.Errorf("github source blame: %s", resp.Errors[0].Message)
+	}
+
+	for _, r := range resp.Data.Repository.Object.Blame.Ranges {
+		if line < r.StartingLine || line > r.EndingLine {
+			continue
+		}
+
+		return Blame{
+			Commit: r.Commit.OID,
+			Author: r.Commit.Author.Name,
+			Email:  r.Commit.Author.Email,
+			Login:  r.Commit.Author.User.Login,
+		}, nil
+	}
+
+	return Blame{}, ErrLineOutOfRange
+}
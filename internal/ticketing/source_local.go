@@ -0,0 +1,151 @@
+package ticketing
+
+import (
+	"bytes"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/bugfixes/celeste/internal/config"
+	bugLog "github.com/bugfixes/go-bugfixes/logs"
+)
+
+var (
+	// commitRef is an abbreviated or full commit hash
+	commitRef = regexp.MustCompile(`^[0-9a-fA-F]{7,40}$`)
+	// namedRef is a branch or tag, it can't start with a dash so git can't take it for an option
+	namedRef = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9._/-]*$`)
+)
+
+// checkRef only lets through refs that are a commit, a branch or a tag, the ref comes from what the agent reported
+func checkRef(ref string) error {
+	if commitRef.MatchString(ref) {
+		return nil
+	}
+	if !namedRef.MatchString(ref) || strings.Contains(ref, "..") || strings.HasSuffix(ref, "/") || strings.HasSuffix(ref, ".lock") {
+		return bugLog.Errorf("checkRef: invalid ref %q", ref)
+	}
+
+	return nil
+}
+
+// checkPath keeps the path inside the checkout
+func checkPath(path string) error {
+	if path == "" || strings.HasPrefix(path, "/") || strings.HasPrefix(path, "-") || strings.Contains(path, "..") {
+		return bugLog.Errorf("checkPath: invalid path %q", path)
+	}
+
+	return nil
+}
+
+// LocalSource reads the code from a git checkout, for self-hosters whose code isn't on GitHub
+type LocalSource struct {
+	Checkout string
+}
+
+func NewLocalSource(c config.Config) *LocalSource {
+	return &LocalSource{
+		Checkout: c.Source.Checkout,
+	}
+}
+
+func (ls *LocalSource) git(args ...string) ([]byte, error) {
+	cmd := exec.Command("git", append([]string{"-C", ls.Checkout}, args...)...)
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, bugLog.Errorf("git %s: %+v, %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+
+	return out, nil
+}
+
+// commit resolves the checked ref to its commit, blame doesn't take --end-of-options so it is given the hash
+func (ls *LocalSource) commit(ref string) (string, error) {
+	if ref == "" {
+		ref = "HEAD"
+	}
+	if err := checkRef(ref); err != nil {
+		return "", bugLog.Errorf("local source commit: %+v", err)
+	}
+
+	out, err := ls.git("rev-parse", "--verify", "--end-of-options", ref+"^{commit}")
+	if err != nil {
+		return "", bugLog.Errorf("local source commit: %+v", err)
+	}
+
+	return strings.TrimSpace(string(out)), nil
+}
+
+// Path takes off the checkout, or the part of the path up to a directory named like it
+func (ls *LocalSource) Path(file string) string {
+	checkout := filepath.Clean(ls.Checkout)
+	if strings.HasPrefix(file, checkout+"/") {
+		return file[len(checkout)+1:]
+	}
+
+	return repoPath(file, filepath.Base(checkout))
+}
+
+func (ls *LocalSource) Lines(path, ref string) ([]string, error) {
+	if err := checkPath(path); err != nil {
+		return nil, bugLog.Errorf("local source lines: %+v", err)
+	}
+	commit, err := ls.commit(ref)
+	if err != nil {
+		return nil, bugLog.Errorf("local source lines: %+v", err)
+	}
+
+	out, err := ls.git("show", "--end-of-options", commit+":"+path)
+	if err != nil {
+		return nil, bugLog.Errorf("local source lines: %+v", err)
+	}
+
+	return strings.Split(strings.TrimSuffix(string(out), "\n"), "\n"), nil
+}
+
+func (ls *LocalSource) Blame(path, ref string, line int) (Blame, error) {
+	if err := checkPath(path); err != nil {
+		return Blame{}, bugLog.Errorf("local source blame: %+v", err)
+	}
+	commit, err := ls.commit(ref)
+	if err != nil {
+		return Blame{}, bugLog.Errorf("local source blame: %+v", err)
+	}
+
+	l := strconv.Itoa(line)
+	out, err := ls.git("blame", "--porcelain", "-L", l+","+l, commit, "--", path)
+	if err != nil {
+		return Blame{}, bugLog.Errorf("local source blame: %+v", err)
+	}
+
+	return parsePorcelainBlame(string(out)), nil
+}
+
+// parsePorcelainBlame reads the header of git blame --porcelain, the first line starts with the commit
+func parsePorcelainBlame(out string) Blame {
+	b := Blame{}
+	for i, line := range strings.Split(out, "\n") {
+		if i == 0 {
+			if fields := strings.Fields(line); len(fields) > 0 {
+				b.Commit = fields[0]
+			}
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "author "):
+			b.Author = strings.TrimPrefix(line, "author ")
+		case strings.HasPrefix(line, "author-mail "):
+			b.Email = strings.Trim(strings.TrimPrefix(line, "author-mail "), "<>")
+		case strings.HasPrefix(line, "\t"):
+			return b
+		}
+	}
+
+	return b
+}
@@ -0,0 +1,192 @@
+package ticketing_test
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bugfixes/celeste/internal/config"
+	"github.com/bugfixes/celeste/internal/ticketing"
+	"github.com/bugfixes/celeste/internal/ticketing/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSourceExcerpt(t *testing.T) {
+	file := []string{"package main", "", "func main() {", "\tpanic(\"boom\")", "}"}
+	blame := ticketing.Blame{
+		Commit: "0123456789abcdef",
+		Author: "Bob",
+		Email:  "bob@bugfix.es",
+		Login:  "bob",
+	}
+
+	tests := []struct {
+		name     string
+		line     int
+		blameErr error
+		expect   *ticketing.SourceContext
+		err      error
+	}{
+		{
+			name: "middle of the file",
+			line: 4,
+			expect: &ticketing.SourceContext{
+				File:      "main.go",
+				Ref:       "abc",
+				Line:      4,
+				StartLine: 3,
+				Lines:     []string{"func main() {", "\tpanic(\"boom\")", "}"},
+				Blame:     blame,
+			},
+		},
+		{
+			name: "top of the file",
+			line: 1,
+			expect: &ticketing.SourceContext{
+				File:      "main.go",
+				Ref:       "abc",
+				Line:      1,
+				StartLine: 1,
+				Lines:     []string{"package main", ""},
+				Blame:     blame,
+			},
+		},
+		{
+			name:     "blame failed",
+			line:     4,
+			blameErr: errors.New("no blame"),
+			expect: &ticketing.SourceContext{
+				File:      "main.go",
+				Ref:       "abc",
+				Line:      4,
+				StartLine: 3,
+				Lines:     []string{"func main() {", "\tpanic(\"boom\")", "}"},
+			},
+		},
+		{
+			name: "past the end",
+			line: 9,
+			err:  ticketing.ErrLineOutOfRange,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sp := &mocks.SourceProvider{}
+			sp.On("Path", "/build/app/main.go").Return("main.go")
+			sp.On("Lines", "main.go", "abc").Return(file, nil)
+			sp.On("Blame", "main.go", "abc", test.line).Return(blame, test.blameErr)
+
+			src, err := ticketing.SourceExcerpt(sp, "/build/app/main.go", "abc", test.line, 1)
+			if passed := assert.Equal(t, test.err, err); !passed {
+				t.Errorf("source excerpt err: %+v", err)
+			}
+			if test.expect != nil && test.blameErr != nil {
+				test.expect.Blame = ticketing.Blame{}
+			}
+			if passed := assert.Equal(t, test.expect, src); !passed {
+				t.Errorf("source excerpt expect: %+v, got: %+v", test.expect, src)
+			}
+		})
+	}
+}
+
+func TestSourceMarkdown(t *testing.T) {
+	md := ticketing.SourceMarkdown(&ticketing.SourceContext{
+		File:      "main.go",
+		Line:      10,
+		StartLine: 9,
+		Lines:     []string{"func main() {", "\tpanic(\"boom\")", "}"},
+		Blame: ticketing.Blame{
+			Commit: "0123456789abcdef",
+			Login:  "bob",
+		},
+	})
+
+	expect := "```go\n  9 | func main() {\n>10 | \tpanic(\"boom\")\n 11 | }\n```\nSuggested assignee: @bob, last changed the line in 0123456\n"
+	if passed := assert.Equal(t, expect, md); !passed {
+		t.Errorf("source markdown expect: %q, got: %q", expect, md)
+	}
+}
+
+func TestLocalSource(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	dir := filepath.Join(t.TempDir(), "app")
+	if err := os.MkdirAll(filepath.Join(dir, "cmd"), 0755); err != nil {
+		t.Fatalf("mkdir err: %+v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "cmd", "main.go"), []byte("package main\n\nfunc main() {}\n"), 0600); err != nil {
+		t.Fatalf("write err: %+v", err)
+	}
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "."},
+		{"-c", "user.name=Bob", "-c", "user.email=bob@bugfix.es", "commit", "-q", "-m", "first"},
+	} {
+		if out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %s err: %+v, %s", args[0], err, out)
+		}
+	}
+
+	ls := ticketing.NewLocalSource(config.Config{
+		Source: config.Source{
+			Checkout: dir,
+		},
+	})
+
+	path := ls.Path("/home/ci/app/cmd/main.go")
+	if passed := assert.Equal(t, "cmd/main.go", path); !passed {
+		t.Errorf("local path got: %v", path)
+	}
+
+	lines, err := ls.Lines(path, "")
+	if passed := assert.Nil(t, err); !passed {
+		t.Fatalf("local lines err: %+v", err)
+	}
+	if passed := assert.Equal(t, "func main() {}", lines[2]); !passed {
+		t.Errorf("local lines got: %v", lines)
+	}
+
+	blame, err := ls.Blame(path, "", 3)
+	if passed := assert.Nil(t, err); !passed {
+		t.Fatalf("local blame err: %+v", err)
+	}
+	if passed := assert.Equal(t, "Bob <bob@bugfix.es>", blame.Assignee()); !passed {
+		t.Errorf("local blame got: %+v", blame)
+	}
+	if passed := assert.Len(t, strings.TrimSpace(blame.Commit), 40); !passed {
+		t.Errorf("local blame commit got: %v", blame.Commit)
+	}
+
+	if _, err := ls.Lines(path, blame.Commit[:7]); err != nil {
+		t.Errorf("local lines short commit err: %+v", err)
+	}
+
+	rejected := []struct {
+		name string
+		path string
+		ref  string
+	}{
+		{name: "option as ref", path: path, ref: "--output=/tmp/celeste"},
+		{name: "range as ref", path: path, ref: "HEAD..main"},
+		{name: "reflog as ref", path: path, ref: "HEAD@{1}"},
+		{name: "path out of the checkout", path: "../../etc/passwd", ref: "HEAD"},
+		{name: "absolute path", path: "/etc/passwd", ref: "HEAD"},
+	}
+	for _, test := range rejected {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := ls.Lines(test.path, test.ref); err == nil {
+				t.Errorf("local lines should reject %s %s", test.ref, test.path)
+			}
+			if _, err := ls.Blame(test.path, test.ref, 1); err == nil {
+				t.Errorf("local blame should reject %s %s", test.ref, test.path)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package ticketing
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bugfixes/celeste/internal/database"
+)
+
+// FramesMarkdown lists the frames for a markdown body, the app's own frames are in bold
+func FramesMarkdown(frames []database.StackFrame) string {
+	lines := make([]string, 0, len(frames))
+	for i, f := range frames {
+		function := f.Function
+		if function == "" {
+			function = "?"
+		}
+		if f.InApp {
+			function = fmt.Sprintf("**%s**", function)
+		}
+		lines = append(lines, fmt.Sprintf("%d. %s `%s:%d`", i+1, function, f.File, f.Line))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// FramesText lists the frames one per line for bodies without markdown, the app's own frames are marked
+func FramesText(frames []database.StackFrame) string {
+	lines := make([]string, 0, len(frames))
+	for _, f := range frames {
+		marker := " "
+		if f.InApp {
+			marker = ">"
+		}
+		lines = append(lines, fmt.Sprintf("%s %s (%s:%d)", marker, f.Function, f.File, f.Line))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// jiraSection adds a heading and a code block to the description straight after the raw bug's code block,
+// a section added later comes before the ones added earlier, without a raw heading it goes on the end
+func jiraSection(body map[string]interface{}, heading, code string, after ...interface{}) {
+	fields, ok := body["fields"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	description, ok := fields["description"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	content, ok := description["content"].([]interface{})
+	if !ok {
+		return
+	}
+
+	section := []interface{}{
+		map[string]interface{}{
+			"type": "heading",
+			"attrs": map[string]interface{}{
+				"level": 2,
+			},
+			"content": []interface{}{
+				map[string]interface{}{
+					"type": "text",
+					"text": heading,
+				},
+			},
+		},
+		map[string]interface{}{
+			"type": "codeBlock",
+			"content": []interface{}{
+				map[string]interface{}{
+					"type": "text",
+					"text": code,
+				},
+			},
+		},
+	}
+
+	section = append(section, after...)
+
+	at := jiraAfterHeading(content, "Raw")
+	description["content"] = append(content[:at:at], append(section, content[at:]...)...)
+}
+
+// jiraAfterHeading is the index of the block after the one following the heading, the heading's code block,
+// or the end of the content when the heading isn't there
+func jiraAfterHeading(content []interface{}, heading string) int {
+	for i, block := range content {
+		b, ok := block.(map[string]interface{})
+		if !ok || b["type"] != "heading" {
+			continue
+		}
+		texts, ok := b["content"].([]interface{})
+		if !ok || len(texts) == 0 {
+			continue
+		}
+		if text, ok := texts[0].(map[string]interface{}); ok && text["text"] == heading {
+			if i+2 > len(content) {
+				return len(content)
+			}
+			return i + 2
+		}
+	}
+
+	return len(content)
+}
+
+func jiraStack(body map[string]interface{}, frames []database.StackFrame) {
+	if len(frames) == 0 {
+		return
+	}
+
+	jiraSection(body, "Stack", FramesText(frames))
+}
@@ -0,0 +1,76 @@
+package ticketing_test
+
+import (
+	"testing"
+
+	"github.com/bugfixes/celeste/internal/database"
+	"github.com/bugfixes/celeste/internal/ticketing"
+	"github.com/stretchr/testify/assert"
+)
+
+func jiraHeadings(t *testing.T, body interface{}) []string {
+	t.Helper()
+
+	fields, _ := body.(map[string]interface{})["fields"].(map[string]interface{})
+	description, _ := fields["description"].(map[string]interface{})
+	content, _ := description["content"].([]interface{})
+
+	headings := []string{}
+	for _, block := range content {
+		b := block.(map[string]interface{})
+		if b["type"] != "heading" {
+			continue
+		}
+		text := b["content"].([]interface{})[0].(map[string]interface{})
+		headings = append(headings, text["text"].(string))
+	}
+
+	return headings
+}
+
+func TestJira_GenerateTemplateSections(t *testing.T) {
+	tests := []struct {
+		name   string
+		ticket ticketing.Ticket
+		expect []string
+	}{
+		{
+			name: "no sections",
+			ticket: ticketing.Ticket{
+				Bug: "it broke",
+				Raw: "it broke",
+			},
+			expect: []string{"Bug", "Raw", "Report Number", "Latest Report Date"},
+		},
+		{
+			name: "stack and source",
+			ticket: ticketing.Ticket{
+				Bug: "it broke",
+				Raw: "it broke",
+				Frames: []database.StackFrame{
+					{Function: "main.write", File: "/app/main.go", Line: 12, InApp: true},
+				},
+				Source: &ticketing.SourceContext{
+					File:      "main.go",
+					Line:      12,
+					StartLine: 12,
+					Lines:     []string{"panic(err)"},
+				},
+			},
+			expect: []string{"Bug", "Raw", "Stack", "Source main.go:12", "Report Number", "Latest Report Date"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			template, err := (&ticketing.Jira{}).GenerateTemplate(&test.ticket)
+			if passed := assert.Nil(t, err); !passed {
+				t.Fatalf("generateTemplate err: %+v", err)
+			}
+			headings := jiraHeadings(t, template.Body)
+			if passed := assert.Equal(t, test.expect, headings); !passed {
+				t.Errorf("generateTemplate headings expect: %v, got: %v", test.expect, headings)
+			}
+		})
+	}
+}
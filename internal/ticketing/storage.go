@@ -12,7 +12,16 @@ import (
 	bugLog "github.com/bugfixes/go-bugfixes/logs"
 )
 
-type TicketingStorage struct {
+//go:generate mockery --name=TicketingStorage
+type TicketingStorage interface {
+	StoreCredentials(credentials TicketingCredentials) error
+	FetchCredentials(a agent.Agent) (TicketingCredentials, error)
+	StoreTicketDetails(details TicketDetails) error
+	FindTicket(details TicketDetails) (TicketDetails, error)
+	TicketExists(details TicketDetails) (bool, error)
+}
+
+type PostgresTicketingStorage struct {
 	Config  config.Config
 	Context context.Context
 }
@@ -33,14 +42,22 @@ type TicketDetails struct {
 	FileLineHash string `json:"file_line_hash"`
 }
 
-func NewTicketingStorage(c config.Config) *TicketingStorage {
-	return &TicketingStorage{
+func NewTicketingStorage(c config.Config) TicketingStorage {
+	if c.StorageBackend == config.StorageMemory {
+		return NewMemoryTicketingStorage(c)
+	}
+
+	return NewPostgresTicketingStorage(c)
+}
+
+func NewPostgresTicketingStorage(c config.Config) *PostgresTicketingStorage {
+	return &PostgresTicketingStorage{
 		Config:  c,
 		Context: context.Background(),
 	}
 }
 
-func (t TicketingStorage) getConnection() (*pgx.Conn, error) {
+func (t PostgresTicketingStorage) getConnection() (*pgx.Conn, error) {
 	conn, err := pgx.Connect(
 		t.Context,
 		fmt.Sprintf(
@@ -57,7 +74,7 @@ func (t TicketingStorage) getConnection() (*pgx.Conn, error) {
 	return conn, nil
 }
 
-func (t TicketingStorage) StoreCredentials(credentials TicketingCredentials) error {
+func (t PostgresTicketingStorage) StoreCredentials(credentials TicketingCredentials) error {
 	conn, err := t.getConnection()
 	if err != nil {
 		return bugLog.Errorf("storeCredentials: %+v", err)
@@ -84,7 +101,7 @@ func (t TicketingStorage) StoreCredentials(credentials TicketingCredentials) err
 	return nil
 }
 
-func (t TicketingStorage) FetchCredentials(a agent.Agent) (TicketingCredentials, error) {
+func (t PostgresTicketingStorage) FetchCredentials(a agent.Agent) (TicketingCredentials, error) {
 	tc := TicketingCredentials{}
 	var details string
 
@@ -112,7 +129,7 @@ func (t TicketingStorage) FetchCredentials(a agent.Agent) (TicketingCredentials,
 	return tc, nil
 }
 
-func (t TicketingStorage) StoreTicketDetails(details TicketDetails) error {
+func (t PostgresTicketingStorage) StoreTicketDetails(details TicketDetails) error {
 	conn, err := t.getConnection()
 	if err != nil {
 		return bugLog.Errorf("storeTicketDetails: %+v", err)
@@ -136,7 +153,7 @@ func (t TicketingStorage) StoreTicketDetails(details TicketDetails) error {
 	return nil
 }
 
-func (t TicketingStorage) FindTicket(details TicketDetails) (TicketDetails, error) {
+func (t PostgresTicketingStorage) FindTicket(details TicketDetails) (TicketDetails, error) {
 	td := TicketDetails{}
 
 	conn, err := t.getConnection()
@@ -174,7 +191,7 @@ func (t TicketingStorage) FindTicket(details TicketDetails) (TicketDetails, erro
 	return td, nil
 }
 
-func (t TicketingStorage) TicketExists(details TicketDetails) (bool, error) {
+func (t PostgresTicketingStorage) TicketExists(details TicketDetails) (bool, error) {
 	var exists bool
 
 	conn, err := t.getConnection()
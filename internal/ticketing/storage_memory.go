@@ -0,0 +1,108 @@
+package ticketing
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/bugfixes/celeste/internal/agent"
+	"github.com/bugfixes/celeste/internal/config"
+	bugLog "github.com/bugfixes/go-bugfixes/logs"
+)
+
+type ticketingMemory struct {
+	sync.RWMutex
+
+	credentials map[string]TicketingCredentials
+	tickets     []TicketDetails
+}
+
+// sharedTicketingMemory is kept for the life of the process so every MemoryTicketingStorage sees the same tickets
+var sharedTicketingMemory = &ticketingMemory{
+	credentials: map[string]TicketingCredentials{},
+}
+
+// ResetMemory empties the credentials and tickets every MemoryTicketingStorage shares
+func ResetMemory() {
+	sharedTicketingMemory.Lock()
+	defer sharedTicketingMemory.Unlock()
+
+	sharedTicketingMemory.credentials = map[string]TicketingCredentials{}
+	sharedTicketingMemory.tickets = nil
+}
+
+type MemoryTicketingStorage struct {
+	Config config.Config
+
+	memory *ticketingMemory
+}
+
+func NewMemoryTicketingStorage(c config.Config) *MemoryTicketingStorage {
+	return &MemoryTicketingStorage{
+		Config: c,
+		memory: sharedTicketingMemory,
+	}
+}
+
+func credentialsKey(a agent.Agent) string {
+	return fmt.Sprintf("%s:%s", a.Credentials.Key, a.Credentials.Secret)
+}
+
+func (t MemoryTicketingStorage) StoreCredentials(credentials TicketingCredentials) error {
+	t.memory.Lock()
+	defer t.memory.Unlock()
+
+	t.memory.credentials[credentialsKey(credentials.Agent)] = credentials
+
+	return nil
+}
+
+func (t MemoryTicketingStorage) FetchCredentials(a agent.Agent) (TicketingCredentials, error) {
+	t.memory.RLock()
+	defer t.memory.RUnlock()
+
+	tc, ok := t.memory.credentials[credentialsKey(a)]
+	if !ok {
+		return TicketingCredentials{}, bugLog.Errorf("fetchCredentials: no credentials for agent")
+	}
+
+	return tc, nil
+}
+
+func (t MemoryTicketingStorage) StoreTicketDetails(details TicketDetails) error {
+	t.memory.Lock()
+	defer t.memory.Unlock()
+
+	details.ID = fmt.Sprint(len(t.memory.tickets) + 1)
+	t.memory.tickets = append(t.memory.tickets, details)
+
+	return nil
+}
+
+func (t MemoryTicketingStorage) FindTicket(details TicketDetails) (TicketDetails, error) {
+	t.memory.RLock()
+	defer t.memory.RUnlock()
+
+	for _, td := range t.memory.tickets {
+		if td.Agent.ID != details.Agent.ID {
+			continue
+		}
+		if td.Hash == details.Hash || (details.FileLineHash != "" && td.FileLineHash == details.FileLineHash) {
+			return td, nil
+		}
+	}
+
+	return TicketDetails{}, bugLog.Errorf("findTicket: no rows in result set")
+}
+
+func (t MemoryTicketingStorage) TicketExists(details TicketDetails) (bool, error) {
+	t.memory.RLock()
+	defer t.memory.RUnlock()
+
+	for _, td := range t.memory.tickets {
+		if td.Hash == details.Hash || (details.FileLineHash != "" && td.FileLineHash == details.FileLineHash) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
@@ -3,9 +3,12 @@ package ticketing
 import (
 	"crypto/sha256"
 	"fmt"
+	"path"
+	"strings"
 
 	"github.com/bugfixes/celeste/internal/agent"
 	"github.com/bugfixes/celeste/internal/config"
+	"github.com/bugfixes/celeste/internal/database"
 	bugLog "github.com/bugfixes/go-bugfixes/logs"
 )
 
@@ -21,8 +24,15 @@ type TicketTemplate struct {
 }
 
 const (
-	firstReport = "first report"
-	multiReport = "multiple reports"
+	firstReport  = "first report"
+	multiReport  = "multiple reports"
+	newInRelease = "new in release"
+)
+
+// TicketOpen and TicketClosed are the states Transition moves a remote ticket to
+const (
+	TicketOpen   = "open"
+	TicketClosed = "closed"
 )
 
 //go:generate mockery --name=TicketingSystem
@@ -38,6 +48,9 @@ type TicketingSystem interface {
 
 	GenerateTemplate(*Ticket) (TicketTemplate, error)
 	TicketExists(*Ticket) (bool, TicketDetails, error)
+
+	// Transition moves the remote ticket at RemoteLink to its State, when set, and to its Assignee, when set
+	Transition(*Ticket) error
 }
 
 type Ticketing struct {
@@ -60,6 +73,15 @@ type Ticket struct {
 	File          string `json:"file"`
 	TimesReported int    `json:"times_reported" default:"1"`
 
+	Frames []database.StackFrame `json:"frames"`
+
+	// Ref is the commit or branch to read the source at, the default branch when empty
+	Ref    string         `json:"ref"`
+	Source *SourceContext `json:"source,omitempty"`
+
+	Release      string `json:"release"`
+	NewInRelease bool   `json:"new_in_release"`
+
 	RemoteID      string      `json:"remote_id"`
 	RemoteDetails interface{} `json:"remote_details"`
 	Hash          Hash        `json:"hash"`
@@ -67,6 +89,9 @@ type Ticket struct {
 	State         string      `json:"state"`
 	RemoteLink    string      `json:"remote_link"`
 	RemoteSystem  string      `json:"remote_system"`
+
+	// Assignee is the email of who the ticket is given to
+	Assignee string `json:"assignee"`
 }
 
 func (t Ticketing) fetchTicketingCredentials(a agent.Agent) (TicketingCredentials, error) {
@@ -114,6 +139,7 @@ func (t Ticketing) TicketCreate(system TicketingSystem, creds TicketingCredentia
 	if err := system.Connect(); err != nil {
 		return bugLog.Errorf("ticketCreate connect: %+v", err)
 	}
+	t.AddSource(system, ticket)
 	if err := system.Create(ticket); err != nil {
 		return bugLog.Errorf("ticketCreate create: %+v", err)
 	}
@@ -143,6 +169,35 @@ func (t Ticketing) CreateTicket(ticket *Ticket) error {
 	return nil
 }
 
+// TransitionTicket moves the agent's remote ticket, it is found from its link, not its hash, so the bug's raw isn't needed
+func (t Ticketing) TransitionTicket(ticket *Ticket) error {
+	creds, err := t.fetchTicketingCredentials(ticket.Agent)
+	if err != nil {
+		return bugLog.Errorf("transitionTicket fetchTicketingCredentials: %+v", err)
+	}
+
+	system, err := t.fetchTicketSystem(creds)
+	if err != nil {
+		return bugLog.Errorf("transitionTicket fetchTicketSystem: %+v", err)
+	}
+	if err := system.ParseCredentials(creds); err != nil {
+		return bugLog.Errorf("transitionTicket parseCredentials: %+v", err)
+	}
+	if err := system.Connect(); err != nil {
+		return bugLog.Errorf("transitionTicket connect: %+v", err)
+	}
+	if err := system.Transition(ticket); err != nil {
+		return bugLog.Errorf("transitionTicket transition: %+v", err)
+	}
+
+	return nil
+}
+
+// remoteKey is the last part of the ticket's link, the issue number on GitHub and the issue key on Jira
+func remoteKey(link string) string {
+	return path.Base(strings.TrimSuffix(link, "/"))
+}
+
 func GenerateHash(data string) string {
 	return fmt.Sprintf("%x", sha256.Sum256([]byte(data)))
 }